@@ -0,0 +1,216 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+// Archiver saves the message history of temp channels before they are deleted.
+type Archiver struct {
+	session Session
+	servers state.ServerStore
+	store   state.TranscriptStore
+}
+
+// NewArchiver initializes a new instance of Archiver.
+func NewArchiver(session Session, servers state.ServerStore, store state.TranscriptStore) *Archiver {
+	return &Archiver{session: session, servers: servers, store: store}
+}
+
+// serverData returns the data of the temp channel's server, if the server archives its temp channels.
+func (a *Archiver) serverData(tempChannel *TempChannel) (state.ServerData, bool) {
+	if a == nil {
+		return nil, false
+	}
+
+	serverData, found := a.servers.Server(tempChannel.serverID)
+	if !found || !serverData.ArchiveEnabled() {
+		return nil, false
+	}
+
+	return serverData, true
+}
+
+// Archive fetches the full message history of the temp channel, and saves it in the server's archive format.
+// The transcript is posted to the server's archive channel, or kept in the database if there's no archive channel or posting fails.
+func (a *Archiver) Archive(tempChannel *TempChannel, serverData state.ServerData) error {
+	messages, err := fetchHistory(a.session, tempChannel.channel.ID)
+	if err != nil {
+		return fmt.Errorf("Failed fetching the history of %v: %v", tempChannel.channelID, err)
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	format := serverData.ArchiveFormat()
+	content, err := formatTranscript(format, tempChannel.channel.Name, messages)
+	if err != nil {
+		return err
+	}
+
+	if serverData.HasArchiveChannelID() {
+		err = a.postTranscript(serverData.ArchiveChannelID(), tempChannel, format, content)
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("Failed posting the transcript of %v, keeping it in the database instead: %v", tempChannel.channelID, err)
+	}
+
+	return a.store.AddTranscript(&state.TranscriptData{
+		ServerID:       tempChannel.serverID,
+		ChannelID:      tempChannel.channelID,
+		VoiceChannelID: tempChannel.voiceChannelID,
+		ChannelName:    tempChannel.channel.Name,
+		Format:         format,
+		Content:        content,
+		CreatedAt:      time.Now().UTC(),
+	})
+}
+
+func (a *Archiver) postTranscript(archiveChannelID state.DiscordID, tempChannel *TempChannel, format string, content string) error {
+	_, err := a.session.ChannelMessageSendComplex(archiveChannelID.RESTAPIFormat(), &discordgo.MessageSend{
+		Content: fmt.Sprintf("Transcript of #%v, the temp channel of <#%v>", tempChannel.channel.Name, tempChannel.voiceChannelID),
+		Files: []*discordgo.File{
+			{
+				Name:        fmt.Sprintf("%v-%v.%v", tempChannel.channel.Name, time.Now().UTC().Format("2006-01-02"), transcriptExtension(format)),
+				ContentType: transcriptContentType(format),
+				Reader:      strings.NewReader(content),
+			},
+		},
+	})
+	return err
+}
+
+// fetchHistory pages through the channel's messages, and returns them from oldest to newest.
+func fetchHistory(session Session, channelID string) ([]*discordgo.Message, error) {
+	messages := []*discordgo.Message{}
+	beforeID := ""
+
+	for {
+		page, err := session.ChannelMessages(channelID, consts.MaxMessagesPerRequest, beforeID, "", "")
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, page...)
+		if len(page) < consts.MaxMessagesPerRequest {
+			break
+		}
+
+		// Pages are ordered from newest to oldest
+		beforeID = page[len(page)-1].ID
+	}
+
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	return messages, nil
+}
+
+type transcriptMessage struct {
+	ID          string    `json:"id"`
+	AuthorID    string    `json:"author_id"`
+	Author      string    `json:"author"`
+	Timestamp   time.Time `json:"timestamp"`
+	Content     string    `json:"content"`
+	Attachments []string  `json:"attachments,omitempty"`
+}
+
+func newTranscriptMessage(message *discordgo.Message) transcriptMessage {
+	result := transcriptMessage{
+		ID:        message.ID,
+		Timestamp: message.Timestamp.UTC(),
+		Content:   message.ContentWithMentionsReplaced(),
+	}
+
+	if message.Author != nil {
+		result.AuthorID = message.Author.ID
+		result.Author = message.Author.Username
+	}
+
+	for _, attachment := range message.Attachments {
+		result.Attachments = append(result.Attachments, attachment.URL)
+	}
+
+	return result
+}
+
+func formatTranscript(format string, channelName string, messages []*discordgo.Message) (string, error) {
+	transcriptMessages := make([]transcriptMessage, 0, len(messages))
+	for _, message := range messages {
+		transcriptMessages = append(transcriptMessages, newTranscriptMessage(message))
+	}
+
+	switch format {
+	case consts.ArchiveFormatText:
+		return formatTextTranscript(channelName, transcriptMessages), nil
+	case consts.ArchiveFormatJSON:
+		content, err := json.MarshalIndent(transcriptMessages, "", "  ")
+		return string(content), err
+	case consts.ArchiveFormatHTML:
+		return formatHTMLTranscript(channelName, transcriptMessages), nil
+	default:
+		return "", fmt.Errorf("Unknown archive format %q", format)
+	}
+}
+
+func formatTextTranscript(channelName string, messages []transcriptMessage) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "#%v\n\n", channelName)
+
+	for _, message := range messages {
+		fmt.Fprintf(&builder, "[%v] %v: %v\n", message.Timestamp.Format("2006-01-02 15:04:05"), message.Author, message.Content)
+		for _, attachment := range message.Attachments {
+			fmt.Fprintf(&builder, "    %v\n", attachment)
+		}
+	}
+
+	return builder.String()
+}
+
+func formatHTMLTranscript(channelName string, messages []transcriptMessage) string {
+	var builder strings.Builder
+	title := html.EscapeString("#" + channelName)
+	fmt.Fprintf(&builder, "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>%v</title></head>\n<body>\n<h1>%v</h1>\n", title, title)
+
+	for _, message := range messages {
+		fmt.Fprintf(&builder, "<p><small>%v</small> <b>%v</b>: %v", message.Timestamp.Format("2006-01-02 15:04:05"), html.EscapeString(message.Author), html.EscapeString(message.Content))
+		for _, attachment := range message.Attachments {
+			fmt.Fprintf(&builder, "<br><a href=\"%v\">%v</a>", html.EscapeString(attachment), html.EscapeString(attachment))
+		}
+		builder.WriteString("</p>\n")
+	}
+
+	builder.WriteString("</body>\n</html>\n")
+	return builder.String()
+}
+
+func transcriptExtension(format string) string {
+	if format == consts.ArchiveFormatText {
+		return "txt"
+	}
+
+	return format
+}
+
+func transcriptContentType(format string) string {
+	switch format {
+	case consts.ArchiveFormatJSON:
+		return "application/json"
+	case consts.ArchiveFormatHTML:
+		return "text/html"
+	default:
+		return "text/plain"
+	}
+}
@@ -0,0 +1,158 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+// archivedTempChannel creates a temp channel with the given messages, and deletes it with the server archiving in the given format.
+// Returns the deleted channel once it's archived.
+func (s *BotTestSuite) archivedTempChannel(serverData state.ServerData, format string, messages ...string) *discordgo.Channel {
+	s.Require().NoError(serverData.SetArchiveFormat(format))
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+
+	for _, content := range messages {
+		_, err := s.session.SendMessage(tempChannel.ID, testUser1ID, content)
+		s.Require().NoError(err)
+	}
+
+	s.joinVoice(testUser1ID, nil)
+	s.waitForDeletion(tempChannel)
+	return tempChannel
+}
+
+// waitForDeletion waits for the archive of the temp channel to finish, the channel is deleted once it's archived.
+func (s *BotTestSuite) waitForDeletion(tempChannel *discordgo.Channel) {
+	deleted := func() bool {
+		_, err := s.session.Channel(tempChannel.ID)
+		return isNotFound(err)
+	}
+	s.Require().Eventually(deleted, time.Second, 5*time.Millisecond, "The temp channel wasn't deleted")
+}
+
+// lastTranscript returns the last transcript kept in the database.
+func (s *BotTestSuite) lastTranscript() *state.TranscriptData {
+	transcripts := s.provider.Transcripts()
+	s.Require().NotEmpty(transcripts, "The transcript wasn't kept in the database")
+	return transcripts[len(transcripts)-1]
+}
+
+func (s *BotTestSuite) TestArchiveFetchesWholeHistory() {
+	serverData := s.setupServer()
+
+	// More than two pages of messages, the last one partial
+	messages := []string{}
+	for i := 0; i < 2*consts.MaxMessagesPerRequest+50; i++ {
+		messages = append(messages, fmt.Sprintf("message %v", i))
+	}
+	tempChannel := s.archivedTempChannel(serverData, consts.ArchiveFormatJSON, messages...)
+
+	transcript := s.lastTranscript()
+	s.Equal(tempChannel.Name, transcript.ChannelName)
+	var archived []transcriptMessage
+	s.Require().NoError(json.Unmarshal([]byte(transcript.Content), &archived))
+
+	userMessages := []string{}
+	for _, message := range archived {
+		if message.AuthorID == testUser1ID {
+			userMessages = append(userMessages, message.Content)
+		}
+	}
+	s.Equal(messages, userMessages, "The transcript is missing messages or has them out of order")
+}
+
+func (s *BotTestSuite) TestArchiveFormats() {
+	serverData := s.setupServer()
+	const content = `<b>hi</b> & "bye"`
+
+	s.archivedTempChannel(serverData, consts.ArchiveFormatText, content)
+	transcript := s.lastTranscript()
+	s.Equal(consts.ArchiveFormatText, transcript.Format)
+	s.Contains(transcript.Content, "user-"+testUser1ID+": "+content)
+
+	s.archivedTempChannel(serverData, consts.ArchiveFormatJSON, content)
+	transcript = s.lastTranscript()
+	s.Equal(consts.ArchiveFormatJSON, transcript.Format)
+	var archived []transcriptMessage
+	s.Require().NoError(json.Unmarshal([]byte(transcript.Content), &archived))
+	s.Require().NotEmpty(archived)
+	last := archived[len(archived)-1]
+	s.Equal(content, last.Content)
+	s.Equal(testUser1ID, last.AuthorID)
+
+	s.archivedTempChannel(serverData, consts.ArchiveFormatHTML, content)
+	transcript = s.lastTranscript()
+	s.Equal(consts.ArchiveFormatHTML, transcript.Format)
+	s.Contains(transcript.Content, "&lt;b&gt;hi&lt;/b&gt; &amp; &#34;bye&#34;")
+	s.NotContains(transcript.Content, content, "The HTML transcript wasn't escaped")
+}
+
+func (s *BotTestSuite) TestArchivePostsToArchiveChannel() {
+	serverData := s.setupServer()
+	archiveChannel := s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildText, "archive", "")
+	s.Require().NoError(serverData.SetArchiveChannelID(s.parseID(archiveChannel.ID)))
+
+	tempChannel := s.archivedTempChannel(serverData, consts.ArchiveFormatText, "hello")
+	s.Empty(s.provider.Transcripts(), "A posted transcript was also kept in the database")
+
+	posted := s.session.Messages(archiveChannel.ID)
+	s.Require().Len(posted, 1)
+	s.Contains(posted[0].Content, "Transcript of #"+tempChannel.Name)
+	s.Require().Len(posted[0].Attachments, 1)
+	attachment, err := s.session.AttachmentContent(posted[0].Attachments[0])
+	s.Require().NoError(err)
+	s.Contains(string(attachment), "hello")
+}
+
+func (s *BotTestSuite) TestArchiveFallsBackToDatabase() {
+	serverData := s.setupServer()
+	archiveChannel := s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildText, "archive", "")
+	s.Require().NoError(serverData.SetArchiveChannelID(s.parseID(archiveChannel.ID)))
+	_, err := s.session.ChannelDelete(archiveChannel.ID)
+	s.Require().NoError(err)
+
+	s.archivedTempChannel(serverData, consts.ArchiveFormatText, "hello")
+	s.Contains(s.lastTranscript().Content, "hello", "The transcript that couldn't be posted was lost")
+}
+
+// blockingHistorySession blocks the fetch of message history until it's released.
+type blockingHistorySession struct {
+	Session
+	fetching chan struct{}
+	release  chan struct{}
+}
+
+func (s *blockingHistorySession) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	s.fetching <- struct{}{}
+	<-s.release
+	return s.Session.ChannelMessages(channelID, limit, beforeID, afterID, aroundID, options...)
+}
+
+func (s *BotTestSuite) TestArchivedChannelStaysSavedUntilDeleted() {
+	serverData := s.setupServer()
+	s.Require().NoError(serverData.SetArchiveFormat(consts.ArchiveFormatText))
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+
+	session := &blockingHistorySession{Session: s.session, fetching: make(chan struct{}), release: make(chan struct{})}
+	s.bot.tempChannels.archiver.session = session
+	s.joinVoice(testUser1ID, nil)
+	<-session.fetching
+
+	saved := s.savedTempChannels()
+	s.Require().Len(saved, 1, "The temp channel was removed from the store before it was archived")
+	s.Equal(tempChannel.ID, saved[0].ChannelID.RESTAPIFormat())
+
+	close(session.release)
+	s.waitForDeletion(tempChannel)
+	removed := func() bool { return len(s.savedTempChannels()) == 0 }
+	s.Eventually(removed, time.Second, 5*time.Millisecond, "The deleted temp channel is still saved")
+}
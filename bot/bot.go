@@ -2,6 +2,7 @@ package bot
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/jonathroth/temp-chat/state"
@@ -13,16 +14,31 @@ type TempChannelBot struct {
 	// Used for running the tests
 	AllowBots bool
 
-	store     state.ServerStore
-	botUserID state.DiscordID
+	session       Session
+	store         state.ServerStore
+	configChanges state.ConfigChangeStore
+	botUserID     state.DiscordID
 
 	tempChannels *TempChannelList
 
-	commands map[string]*Command
+	commands             map[string]*Command
+	registerCommandsOnce sync.Once
 }
 
+// Intents are the gateway intents the bot requires.
+// Message content is required for prefix commands, it's privileged and has to be enabled in the developer portal.
+// The members intent isn't needed, the authors of commands are taken from the messages and interactions.
+const Intents = discordgo.IntentsGuilds |
+	discordgo.IntentsGuildMessages |
+	discordgo.IntentsGuildVoiceStates |
+	discordgo.IntentsDirectMessages |
+	discordgo.IntentMessageContent
+
 // NewTempChannelBot initializes a new instance of TempChannelBot.
-func NewTempChannelBot(session *discordgo.Session, store state.ServerStore) (*TempChannelBot, error) {
+// The temp channels saved by a previous run of the bot are re-adopted.
+// A real Discord connection is wrapped with NewDiscordSession, the bot's event handlers use it rather than the session they're called with.
+// Changes made to the settings of servers are recorded in configChanges.
+func NewTempChannelBot(session Session, store state.ServerStore, tempChannelStore state.TempChannelStore, transcriptStore state.TranscriptStore, configChanges state.ConfigChangeStore) (*TempChannelBot, error) {
 	user, err := session.User("@me")
 	if err != nil {
 		return nil, err
@@ -34,16 +50,30 @@ func NewTempChannelBot(session *discordgo.Session, store state.ServerStore) (*Te
 	}
 
 	bot := &TempChannelBot{
-		store:        store,
-		botUserID:    userID,
-		tempChannels: NewTempChannelList(session),
+		session:       session,
+		store:         store,
+		configChanges: configChanges,
+		botUserID:     userID,
+		tempChannels:  NewTempChannelList(session, store, tempChannelStore, NewArchiver(session, store, transcriptStore)),
 	}
 	bot.commands = bot.initCommands()
 
+	err = bot.tempChannels.Restore()
+	if err != nil {
+		return nil, fmt.Errorf("Failed restoring temp channels: %v", err)
+	}
+
 	return bot, nil
 }
 
 // CleanChannels deletes all the temp channels created by the bot.
+// The bot doesn't call it on shutdown, temp channels are kept and re-adopted on the next run.
 func (b *TempChannelBot) CleanChannels() {
 	b.tempChannels.DeleteAllChannels()
 }
+
+// Close stops the bot's background timers.
+// Temp channels are kept, so they can be re-adopted on the next run.
+func (b *TempChannelBot) Close() {
+	b.tempChannels.StopTimers()
+}
@@ -0,0 +1,166 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/bot/fakediscord"
+	"github.com/jonathroth/temp-chat/state"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	testBotUserID = "100"
+	testOwnerID   = "200"
+	testUser1ID   = "201"
+	testUser2ID   = "202"
+	testUser3ID   = "203"
+)
+
+// BotTestSuite runs the bot's handlers against a fake guild with a temp category, a text channel and two voice channels.
+// The guild is owned by testOwnerID, the test users are regular members.
+type BotTestSuite struct {
+	suite.Suite
+
+	session  *fakediscord.Session
+	provider *state.MemoryServersProvider
+	store    *state.SyncServerStore
+	bot      *TempChannelBot
+
+	guild         *discordgo.Guild
+	serverID      state.DiscordID
+	category      *discordgo.Channel
+	textChannel   *discordgo.Channel
+	voiceChannel1 *discordgo.Channel
+	voiceChannel2 *discordgo.Channel
+}
+
+func TestBotTestSuite(t *testing.T) {
+	suite.Run(t, &BotTestSuite{})
+}
+
+func (s *BotTestSuite) SetupTest() {
+	s.session = fakediscord.NewSession(testBotUserID)
+	s.guild = s.session.AddGuild(testOwnerID)
+	s.serverID = s.parseID(s.guild.ID)
+
+	for _, userID := range []string{testOwnerID, testUser1ID, testUser2ID, testUser3ID} {
+		s.session.AddMember(s.guild.ID, userID)
+	}
+
+	s.category = s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildCategory, "temp", "",
+		&discordgo.PermissionOverwrite{ID: s.guild.ID, Type: discordgo.PermissionOverwriteTypeRole, Deny: discordgo.PermissionViewChannel},
+		&discordgo.PermissionOverwrite{ID: testBotUserID, Type: discordgo.PermissionOverwriteTypeMember, Allow: discordgo.PermissionViewChannel | discordgo.PermissionManageChannels},
+	)
+	s.textChannel = s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildText, "general", "")
+	s.voiceChannel1 = s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildVoice, "voice-1", "")
+	s.voiceChannel2 = s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildVoice, "voice-2", "")
+
+	s.provider = state.NewMemoryServersProvider()
+
+	var err error
+	s.store, err = state.NewSyncServerStore(s.provider)
+	s.Require().NoError(err)
+	s.bot, err = NewTempChannelBot(s.session, s.store, s.provider, s.provider, s.provider)
+	s.Require().NoError(err)
+}
+
+func (s *BotTestSuite) TearDownTest() {
+	s.bot.Close()
+}
+
+func (s *BotTestSuite) parseID(id string) state.DiscordID {
+	discordID, err := state.ParseDiscordID(id)
+	s.Require().NoError(err)
+	return discordID
+}
+
+// setupServer sets up the server the way the setup command does.
+func (s *BotTestSuite) setupServer() state.ServerData {
+	s.Require().NoError(s.store.AddServer(s.serverID, s.parseID(s.category.ID)))
+	serverData, found := s.store.Server(s.serverID)
+	s.Require().True(found)
+	return serverData
+}
+
+// updateConfig changes the server's settings through ServerData.Update, for the settings without their own setters.
+func (s *BotTestSuite) updateConfig(serverData state.ServerData, update func(config *state.ServerConfig)) {
+	s.Require().NoError(serverData.Update(func(config *state.ServerConfig) error {
+		update(config)
+		return nil
+	}))
+}
+
+// savedTempChannels returns the temp channels kept in the store.
+func (s *BotTestSuite) savedTempChannels() []*state.TempChannelData {
+	tempChannels, err := s.provider.TempChannels()
+	s.Require().NoError(err)
+	return tempChannels
+}
+
+func splitLines(reply string) []string {
+	return strings.Split(strings.Trim(reply, "`"), "\n")
+}
+
+func formatChangeID(changeID int64) string {
+	return strconv.FormatInt(changeID, 10)
+}
+
+// runCommand sends a message as the user to the text channel, and returns the bot's reply.
+func (s *BotTestSuite) runCommand(userID string, content string) string {
+	return s.runCommandWithFiles(userID, content)
+}
+
+// runCommandWithFiles sends a message with attached files as the user to the text channel, and returns the bot's reply.
+func (s *BotTestSuite) runCommandWithFiles(userID string, content string, files ...*discordgo.File) string {
+	return s.runCommandIn(s.textChannel, userID, content, files...)
+}
+
+// runCommandIn sends a message with attached files as the user to the channel, and returns the bot's reply.
+func (s *BotTestSuite) runCommandIn(channel *discordgo.Channel, userID string, content string, files ...*discordgo.File) string {
+	repliesBefore := len(s.session.Messages(channel.ID))
+
+	event, err := s.session.SendMessageWithFiles(channel.ID, userID, content, files...)
+	s.Require().NoError(err)
+	s.bot.MessageCreate(nil, event)
+
+	replies := []string{}
+	for _, message := range s.session.Messages(channel.ID)[repliesBefore:] {
+		if message.Author.ID == testBotUserID {
+			replies = append(replies, message.Content)
+		}
+	}
+
+	return strings.Join(replies, "\n")
+}
+
+// joinVoice moves the user to a voice channel, or out of voice chats if the channel is nil.
+func (s *BotTestSuite) joinVoice(userID string, voiceChannel *discordgo.Channel) {
+	channelID := ""
+	if voiceChannel != nil {
+		channelID = voiceChannel.ID
+	}
+
+	s.bot.VoiceStatusUpdate(nil, s.session.SetVoiceState(s.guild.ID, userID, channelID))
+}
+
+// tempChannels returns the channels the bot created in the temp category.
+func (s *BotTestSuite) tempChannels() []*discordgo.Channel {
+	return s.session.ChannelsInCategory(s.guild.ID, s.category.ID)
+}
+
+// requireTempChannel returns the only temp channel, failing if there isn't exactly one.
+func (s *BotTestSuite) requireTempChannel() *discordgo.Channel {
+	channels := s.tempChannels()
+	s.Require().Len(channels, 1, "Expected a single temp channel")
+	return channels[0]
+}
+
+// canView tells whether the user can see the channel according to its permission overwrites.
+func (s *BotTestSuite) canView(channel *discordgo.Channel, userID string) bool {
+	member, err := s.session.StateMember(s.guild.ID, userID)
+	s.Require().NoError(err)
+	return resolvePermissions(s.guild, member, channel)&discordgo.PermissionViewChannel != 0
+}
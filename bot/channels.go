@@ -2,20 +2,24 @@ package bot
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"sync"
+	"time"
 
-	"github.com/Pallinder/go-randomdata"
 	"github.com/bwmarrin/discordgo"
 	"github.com/jonathroth/temp-chat/consts"
 	"github.com/jonathroth/temp-chat/state"
 )
 
 // ChannelDelete is called whenever a channel is deleted in a server the bot is in.
-func (b *TempChannelBot) ChannelDelete(s *discordgo.Session, m *discordgo.ChannelDelete) {
+func (b *TempChannelBot) ChannelDelete(_ *discordgo.Session, m *discordgo.ChannelDelete) {
+	defer recoverEvent("ChannelDelete")
+
 	channelID, err := state.ParseDiscordID(m.ID)
 	if err != nil {
-		log.Fatalf("Failed to parse channel ID of a channel that was just deleted")
+		log.Printf("Failed to parse channel ID of a channel that was just deleted: %v", err)
+		return
 	}
 
 	if m.Type == discordgo.ChannelTypeGuildVoice {
@@ -29,18 +33,99 @@ func (b *TempChannelBot) ChannelDelete(s *discordgo.Session, m *discordgo.Channe
 	}
 }
 
+// GuildCreate is called whenever the bot connects to a server, or joins a new one.
+func (b *TempChannelBot) GuildCreate(_ *discordgo.Session, g *discordgo.GuildCreate) {
+	defer recoverEvent("GuildCreate")
+
+	serverID, err := state.ParseDiscordID(g.ID)
+	if err != nil {
+		log.Printf("Failed to parse server ID of a server the bot connected to: %v", err)
+		return
+	}
+
+	restored, err := b.store.RestoreServer(serverID)
+	if err != nil {
+		log.Printf("Failed to restore the settings of server %v: %v", serverID, err)
+	} else if restored {
+		log.Printf("The bot was invited back to server %v, its settings were restored", serverID)
+	}
+
+	b.tempChannels.SyncVoiceStates(serverID, g.VoiceStates)
+	b.reconcileOrphans(b.session, g.Guild)
+}
+
+// GuildDelete is called whenever the bot is removed from a server, or a server becomes unavailable.
+func (b *TempChannelBot) GuildDelete(_ *discordgo.Session, g *discordgo.GuildDelete) {
+	defer recoverEvent("GuildDelete")
+
+	// An unavailable server is an outage, the bot is still in it and gets a GuildCreate once it's back
+	if g.Unavailable {
+		return
+	}
+
+	serverID, err := state.ParseDiscordID(g.ID)
+	if err != nil {
+		log.Printf("Failed to parse server ID of a server the bot was removed from: %v", err)
+		return
+	}
+
+	b.removeServer(serverID)
+}
+
+// removeServer drops the temp channels and the settings of a server the bot was removed from.
+func (b *TempChannelBot) removeServer(serverID state.DiscordID) {
+	removed := b.tempChannels.RemoveServer(serverID)
+	log.Printf("The bot was removed from server %v, dropped its %v temp channels", serverID, removed)
+
+	err := b.store.RemoveServer(serverID)
+	if err != nil {
+		log.Printf("Failed to remove server %v from the store: %v", serverID, err)
+	}
+}
+
+// removeMissingServers removes the servers the bot was removed from while it was down,
+// which are the servers in the store that aren't in the list of servers Discord sent on connection.
+func (b *TempChannelBot) removeMissingServers(guilds []*discordgo.Guild) {
+	connected := map[state.DiscordID]bool{}
+	for _, guild := range guilds {
+		serverID, err := state.ParseDiscordID(guild.ID)
+		if err != nil {
+			log.Printf("Failed to parse server ID of a server the bot connected to: %v", err)
+			// Nothing is removed, as the missing server may be this one
+			return
+		}
+
+		connected[serverID] = true
+	}
+
+	for _, serverID := range b.store.ServerIDs() {
+		if !connected[serverID] {
+			b.removeServer(serverID)
+		}
+	}
+}
+
 // VoiceStatusUpdate is called whenever a user joins/leaves/moves a voice channel.
-func (b *TempChannelBot) VoiceStatusUpdate(s *discordgo.Session, vsu *discordgo.VoiceStateUpdate) {
+func (b *TempChannelBot) VoiceStatusUpdate(_ *discordgo.Session, vsu *discordgo.VoiceStateUpdate) {
+	defer recoverEvent("VoiceStatusUpdate")
+
+	serverID, err := state.ParseDiscordID(vsu.GuildID)
+	if err != nil {
+		log.Printf("Failed to parse server ID from user voice status update: %v", err)
+		return
+	}
+
 	userID, err := state.ParseDiscordID(vsu.UserID)
 	if err != nil {
-		log.Fatalf("Failed to parse user ID from user voice status update: %v", err)
+		log.Printf("Failed to parse user ID from user voice status update: %v", err)
+		return
 	}
 
 	if vsu.ChannelID == "" {
 		// User has left voice chat
 		err = b.tempChannels.RemoveUserFromChannel(userID)
 		if err != nil {
-			log.Printf("Failed to remove user from temp channel: %v", err) // TODO: notify user somehow?
+			b.handleError(serverID, "remove a user from a temp channel", err)
 		}
 		return
 	}
@@ -48,13 +133,154 @@ func (b *TempChannelBot) VoiceStatusUpdate(s *discordgo.Session, vsu *discordgo.
 	// User joined voice chat/switch to another chat
 	voiceChannelID, err := state.ParseDiscordID(vsu.ChannelID)
 	if err != nil {
-		log.Fatalf("Failed to parse channel ID from user voice status update: %v", err)
+		log.Printf("Failed to parse channel ID from user voice status update: %v", err)
+		return
 	}
 
 	err = b.tempChannels.AssignUserToTempChannel(userID, voiceChannelID)
 	if err != nil {
-		log.Printf("Failed to assign user to new temp channel: %v", err) // TODO: notify user somehow?
+		b.handleError(serverID, "give a user access to a temp channel", err)
 	}
+
+	b.autoCreateTempChannel(b.session, vsu.GuildID, voiceChannelID, userID)
+}
+
+// autoCreateTempChannel creates a temp channel for a voice chat that doesn't have one, if the server enabled automatic creation for it.
+// The user that joined the voice chat is considered the owner of the channel.
+func (b *TempChannelBot) autoCreateTempChannel(s Session, guildID string, voiceChannelID state.DiscordID, userID state.DiscordID) {
+	serverID, err := state.ParseDiscordID(guildID)
+	if err != nil {
+		log.Printf("Failed to parse server ID from user voice status update: %v", err)
+		return
+	}
+
+	serverData, serverIsSetup := b.store.Server(serverID)
+	if !serverIsSetup {
+		return
+	}
+
+	config := serverData.Config()
+	policy := config.VoiceChannelPolicy(voiceChannelID)
+	if policy.MkchDisabled || (!policy.ForceAutoCreate && (!config.AutoCreate || !autoCreateAppliesTo(serverData, voiceChannelID))) {
+		return
+	}
+
+	if _, alreadyExists := b.tempChannels.GetTempChannelForVoiceChat(voiceChannelID); alreadyExists {
+		return
+	}
+
+	categoryID := tempChannelCategoryID(s, serverData, voiceChannelID)
+	category, err := s.StateChannel(categoryID.RESTAPIFormat())
+	if !existsInState(err) || category.Type != discordgo.ChannelTypeGuildCategory {
+		log.Printf("Can't automatically create a temp channel in server %v, the temp channel category %v doesn't exist", serverID, categoryID)
+		return
+	}
+
+	participants, err := voiceChannelParticipants(s, guildID, voiceChannelID)
+	if err != nil {
+		log.Printf("Failed to get the participants of voice channel %v: %v", voiceChannelID, err)
+		return
+	}
+
+	tempChannel, created, err := b.createTempChannel(s, serverData, voiceChannelID, userID, participants)
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		log.Printf("Didn't automatically create a temp channel for voice channel %v: %v", voiceChannelID, err)
+		return
+	} else if err != nil {
+		b.handleError(serverID, "automatically create a temp channel", err)
+		return
+	}
+
+	if created {
+		log.Printf("Automatically created temp channel %v for voice channel %v", tempChannel.channelID, voiceChannelID)
+	}
+}
+
+func autoCreateAppliesTo(serverData state.ServerData, voiceChannelID state.DiscordID) bool {
+	if !serverData.HasAutoCreateVoiceChannelIDs() {
+		return true
+	}
+
+	for _, allowedID := range serverData.AutoCreateVoiceChannelIDs() {
+		if allowedID == voiceChannelID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// createTempChannel creates and tracks a temp channel for the voice chat, unless it already has one.
+// The owner is the user the channel is created for, used for naming the channel and allowed to control it afterwards.
+// Returns the voice chat's temp channel, and whether it was just created.
+func (b *TempChannelBot) createTempChannel(s Session, serverData state.ServerData, voiceChannelID state.DiscordID, ownerID state.DiscordID, participants []state.DiscordID) (*TempChannel, bool, error) {
+	guildID := serverData.ServerID().RESTAPIFormat()
+	config := serverData.Config()
+	limits := CreationLimits{Cooldown: config.MkchCooldown, MaxChannels: config.MaxTempChannels}
+	tempChannel, created, err := b.tempChannels.CreateTempChannel(serverData.ServerID(), voiceChannelID, ownerID, limits, func(channelNumber int) (*TempChannel, error) {
+		name := formatChannelName(config.ChannelNameTemplateFor(voiceChannelID), newChannelNameParams(s, guildID, voiceChannelID, ownerID, channelNumber))
+		return NewTempChannel(s, serverData, b.botUserID, voiceChannelID, ownerID, name, participants)
+	})
+	return tempChannel, created, err
+}
+
+// tempChannelCategoryID returns the category the temp channel of the voice channel is created in,
+// according to the server's category routes.
+func tempChannelCategoryID(s Session, serverData state.ServerData, voiceChannelID state.DiscordID) state.DiscordID {
+	voiceCategoryID := state.DiscordIDNone
+	voiceChannel, err := s.StateChannel(voiceChannelID.RESTAPIFormat())
+	if err == nil && voiceChannel.ParentID != "" {
+		parentID, err := state.ParseDiscordID(voiceChannel.ParentID)
+		if err == nil {
+			voiceCategoryID = parentID
+		}
+	}
+
+	config := serverData.Config()
+	return config.TempChannelCategoryFor(voiceChannelID, voiceCategoryID)
+}
+
+// historyVisibility returns the history visibility of the temp channel of a voice channel, the default if the server isn't set up.
+func historyVisibility(servers state.ServerStore, serverID state.DiscordID, voiceChannelID state.DiscordID) state.HistoryVisibility {
+	serverData, found := servers.Server(serverID)
+	if !found {
+		return state.HistoryVisibility{Mode: consts.DefaultHistoryMode}
+	}
+
+	config := serverData.Config()
+	return config.HistoryVisibilityFor(voiceChannelID)
+}
+
+// memberPermissions returns the permissions allowed and denied to the members of a temp channel.
+// Members may only read the messages sent before they joined if the history is visible.
+func memberPermissions(history state.HistoryVisibility) (allow int64, deny int64) {
+	if history.Mode == consts.HistoryModeVisible {
+		return discordgo.PermissionViewChannel | discordgo.PermissionReadMessageHistory, 0
+	}
+
+	return discordgo.PermissionViewChannel, discordgo.PermissionReadMessageHistory
+}
+
+func voiceChannelParticipants(s Session, guildID string, voiceChannelID state.DiscordID) ([]state.DiscordID, error) {
+	guild, err := s.StateGuild(guildID)
+	if err != nil {
+		return nil, err
+	}
+
+	participants := []state.DiscordID{}
+	for _, voiceState := range guild.VoiceStates {
+		if voiceChannelID.Equals(voiceState.ChannelID) {
+			id, err := state.ParseDiscordID(voiceState.UserID)
+			if err != nil {
+				return nil, err
+			}
+
+			participants = append(participants, id)
+		}
+	}
+
+	return participants, nil
 }
 
 type channelMap map[state.DiscordID]*TempChannel
@@ -66,17 +292,154 @@ type TempChannelList struct {
 	tempChannelIDToTempChannel  channelMap
 	voiceChannelIDToTempChannel channelMap
 	userIDToTempChannel         channelMap
+	// Users kicked from a temp channel while in its voice chat, until they leave the voice chat
+	kickedUserIDToTempChannel channelMap
+
+	// Empty temp channels waiting for their server's grace period to pass before they're deleted
+	pendingDeletions map[state.DiscordID]*pendingDeletion
+
+	// Temp channels that are being created, by the voice chat they're created for
+	pendingCreations map[state.DiscordID]*pendingCreation
+
+	// When each user last created a temp channel in each server, for the servers' mkch cooldowns
+	lastCreations map[creator]time.Time
+
+	session  Session
+	servers  state.ServerStore
+	store    state.TempChannelStore
+	archiver *Archiver
+}
 
-	session *discordgo.Session
+type pendingDeletion struct {
+	timer *time.Timer
 }
 
 // NewTempChannelList initializes a new instance of TempChannelList
-func NewTempChannelList(session *discordgo.Session) *TempChannelList {
+// The archiver may be nil, in which case transcripts are never archived.
+func NewTempChannelList(session Session, servers state.ServerStore, store state.TempChannelStore, archiver *Archiver) *TempChannelList {
 	return &TempChannelList{
 		tempChannelIDToTempChannel:  channelMap{},
 		voiceChannelIDToTempChannel: channelMap{},
 		userIDToTempChannel:         channelMap{},
+		kickedUserIDToTempChannel:   channelMap{},
+		pendingDeletions:            map[state.DiscordID]*pendingDeletion{},
+		pendingCreations:            map[state.DiscordID]*pendingCreation{},
+		lastCreations:               map[creator]time.Time{},
 		session:                     session,
+		servers:                     servers,
+		store:                       store,
+		archiver:                    archiver,
+	}
+}
+
+// Restore re-adopts the temp channels saved in the store by a previous run of the bot.
+// Channels whose text or voice channel no longer exist are deleted.
+// Channels that can't be fetched for any other reason, such as servers the bot was removed from, are skipped but kept in the store.
+func (l *TempChannelList) Restore() error {
+	savedChannels, err := l.store.TempChannels()
+	if err != nil {
+		return err
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	for _, data := range savedChannels {
+		channel, err := l.session.Channel(data.ChannelID.RESTAPIFormat())
+		if isNotFound(err) {
+			log.Printf("Temp channel %v was deleted while the bot was down", data.ChannelID)
+			l.removeFromStore(data.ChannelID)
+			continue
+		} else if err != nil {
+			log.Printf("Failed to fetch temp channel %v, skipping it: %v", data.ChannelID, err)
+			continue
+		}
+
+		tempChannel := restoreTempChannel(l.session, data, channel, historyVisibility(l.servers, data.ServerID, data.VoiceChannelID))
+
+		_, err = l.session.Channel(data.VoiceChannelID.RESTAPIFormat())
+		if isNotFound(err) {
+			log.Printf("The voice channel of temp channel %v was deleted while the bot was down", data.ChannelID)
+			l.removeFromStore(data.ChannelID)
+			err = tempChannel.Delete()
+			if err != nil {
+				log.Printf("Failed to delete temp channel %v: %v", data.ChannelID, err)
+			}
+			continue
+		} else if err != nil {
+			log.Printf("Failed to fetch the voice channel of temp channel %v, skipping it: %v", data.ChannelID, err)
+			continue
+		}
+
+		l.addTempChannelNoLock(tempChannel)
+	}
+
+	log.Printf("Restored %v temp channels", len(l.tempChannelIDToTempChannel))
+	return nil
+}
+
+// SyncVoiceStates updates the members of all temp channels in a server according to the current voice states.
+// Used to catch up on users that joined or left voice chats while the bot was down.
+func (l *TempChannelList) SyncVoiceStates(serverID state.DiscordID, voiceStates []*discordgo.VoiceState) {
+	l.Lock()
+	defer l.Unlock()
+
+	userIDToVoiceChannelID := map[state.DiscordID]state.DiscordID{}
+	for _, voiceState := range voiceStates {
+		userID, err := state.ParseDiscordID(voiceState.UserID)
+		if err != nil {
+			log.Printf("Failed to parse user ID of voice state: %v", err)
+			continue
+		}
+
+		voiceChannelID, err := state.ParseDiscordID(voiceState.ChannelID)
+		if err != nil {
+			log.Printf("Failed to parse channel ID of voice state: %v", err)
+			continue
+		}
+
+		userIDToVoiceChannelID[userID] = voiceChannelID
+	}
+
+	for userID, kickedFrom := range l.kickedUserIDToTempChannel {
+		if kickedFrom.serverID == serverID && userIDToVoiceChannelID[userID] != kickedFrom.voiceChannelID {
+			l.forgetKickNoLock(userID)
+		}
+	}
+
+	for userID, tempChannel := range l.userIDToTempChannel {
+		voiceChannelID := userIDToVoiceChannelID[userID]
+		if tempChannel.serverID != serverID || voiceChannelID == tempChannel.voiceChannelID {
+			continue
+		}
+
+		if tempChannel.invited[userID] && voiceChannelID == state.DiscordIDNone {
+			// Invited users keep their access without being in the voice chat
+			continue
+		}
+
+		err := l.removeUserFromChannelNoLock(userID)
+		if err != nil {
+			log.Printf("Failed to remove user %v that left while the bot was down: %v", userID, err)
+		}
+	}
+
+	for userID, voiceChannelID := range userIDToVoiceChannelID {
+		tempChannel, found := l.voiceChannelIDToTempChannel[voiceChannelID]
+		if !found || tempChannel.members[userID] {
+			continue
+		}
+
+		err := l.assignUserToTempChannelNoLock(userID, voiceChannelID)
+		if err != nil {
+			log.Printf("Failed to assign user %v that joined while the bot was down: %v", userID, err)
+		}
+	}
+
+	for _, tempChannel := range l.tempChannelIDToTempChannel {
+		if tempChannel.serverID == serverID && len(tempChannel.members) == 0 {
+			l.scheduleDeletionNoLock(tempChannel)
+		}
 	}
 }
 
@@ -88,11 +451,35 @@ func (l *TempChannelList) GetTempChannelForVoiceChat(voiceChannelID state.Discor
 	return tempChannel, found
 }
 
+// GetTempChannel returns the temp channel with the given text channel ID.
+func (l *TempChannelList) GetTempChannel(channelID state.DiscordID) (*TempChannel, bool) {
+	l.RLock()
+	defer l.RUnlock()
+	tempChannel, found := l.tempChannelIDToTempChannel[channelID]
+	return tempChannel, found
+}
+
+// GetTempChannelForUser returns the temp channel the user currently has access to.
+func (l *TempChannelList) GetTempChannelForUser(userID state.DiscordID) (*TempChannel, bool) {
+	l.RLock()
+	defer l.RUnlock()
+	tempChannel, found := l.userIDToTempChannel[userID]
+	return tempChannel, found
+}
+
+// IsTempChannel returns whether the given text channel is a temp channel tracked by the list.
+func (l *TempChannelList) IsTempChannel(channelID state.DiscordID) bool {
+	l.RLock()
+	defer l.RUnlock()
+	_, found := l.tempChannelIDToTempChannel[channelID]
+	return found
+}
+
 // RemoveTempChannelByVoiceChat deletes a temp channel bound to the given voice channel ID, if it exists.
 // Returns whether a channel was found and removed.
 func (l *TempChannelList) RemoveTempChannelByVoiceChat(voiceChannelID state.DiscordID) (*TempChannel, bool) {
-	l.RLock()
-	defer l.RUnlock()
+	l.Lock()
+	defer l.Unlock()
 	tempChannel, found := l.voiceChannelIDToTempChannel[voiceChannelID]
 	if !found {
 		return nil, false
@@ -105,8 +492,8 @@ func (l *TempChannelList) RemoveTempChannelByVoiceChat(voiceChannelID state.Disc
 // RemoveTempChannelByID deletes a temp channel if it exists.
 // Returns whether a channel was found and removed.
 func (l *TempChannelList) RemoveTempChannelByID(tempChannelID state.DiscordID) (*TempChannel, bool) {
-	l.RLock()
-	defer l.RUnlock()
+	l.Lock()
+	defer l.Unlock()
 	tempChannel, found := l.tempChannelIDToTempChannel[tempChannelID]
 	if !found {
 		return nil, false
@@ -116,15 +503,209 @@ func (l *TempChannelList) RemoveTempChannelByID(tempChannelID state.DiscordID) (
 	return tempChannel, true
 }
 
+// CreateTempChannel creates a temp channel for the voice chat using the given function, unless the voice chat already has one.
+// The function gets the number of the new channel among the server's temp channels.
+// The voice chat is reserved while the function runs without holding the list, so users joining at the same time can't create two channels,
+// they get the channel once it's created instead.
+// A *LimitError is returned if the user or the server reached one of the limits.
+// Returns the voice chat's temp channel, and whether it was just created.
+func (l *TempChannelList) CreateTempChannel(serverID state.DiscordID, voiceChannelID state.DiscordID, userID state.DiscordID, limits CreationLimits,
+	create func(channelNumber int) (*TempChannel, error)) (*TempChannel, bool, error) {
+	creation, channelNumber, existing, err := l.reserveCreation(serverID, voiceChannelID, userID, limits)
+	if err != nil || existing != nil {
+		return existing, false, err
+	}
+
+	defer l.finishCreation(voiceChannelID, creation)
+	creation.tempChannel, creation.err = create(channelNumber)
+	if creation.err != nil {
+		return nil, false, creation.err
+	}
+
+	return creation.tempChannel, true, nil
+}
+
+// pendingCreation is a temp channel being created for a voice chat.
+type pendingCreation struct {
+	serverID state.DiscordID
+	// done is closed once the creation finished, successfully or not
+	done        chan struct{}
+	tempChannel *TempChannel
+	err         error
+
+	// The user's previous creation, restored if the creation fails so it doesn't start the cooldown
+	creator             creator
+	previousCreation    time.Time
+	hadPreviousCreation bool
+}
+
+// reserveCreation reserves the voice chat for a new temp channel, checking the creation limits.
+// If the voice chat has a temp channel, or one that is being created, returns it instead.
+// Returns the reservation and the number of the new channel among the server's temp channels.
+func (l *TempChannelList) reserveCreation(serverID state.DiscordID, voiceChannelID state.DiscordID, userID state.DiscordID, limits CreationLimits) (*pendingCreation, int, *TempChannel, error) {
+	l.Lock()
+
+	if tempChannel, found := l.voiceChannelIDToTempChannel[voiceChannelID]; found {
+		l.Unlock()
+		return nil, 0, tempChannel, nil
+	}
+
+	if creation, found := l.pendingCreations[voiceChannelID]; found {
+		l.Unlock()
+		<-creation.done
+		return nil, 0, creation.tempChannel, creation.err
+	}
+
+	defer l.Unlock()
+
+	now := time.Now()
+	channelCount := l.serverChannelCountNoLock(serverID)
+	err := l.checkLimitsNoLock(serverID, userID, limits, channelCount, now)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	creation := &pendingCreation{serverID: serverID, done: make(chan struct{}), creator: creator{serverID: serverID, userID: userID}}
+	creation.previousCreation, creation.hadPreviousCreation = l.lastCreations[creation.creator]
+	l.recordCreationNoLock(serverID, userID, now)
+	l.pendingCreations[voiceChannelID] = creation
+	return creation, channelCount + 1, nil, nil
+}
+
+// finishCreation releases the voice chat's reservation, and tracks the created temp channel if the creation succeeded.
+// It runs even if the creation panicked, so the voice chat doesn't stay reserved.
+func (l *TempChannelList) finishCreation(voiceChannelID state.DiscordID, creation *pendingCreation) {
+	l.Lock()
+	defer l.Unlock()
+	defer close(creation.done)
+
+	delete(l.pendingCreations, voiceChannelID)
+	if creation.tempChannel == nil {
+		if creation.err == nil {
+			creation.err = errors.New("The temp channel creation failed")
+		}
+
+		if creation.hadPreviousCreation {
+			l.lastCreations[creation.creator] = creation.previousCreation
+		} else {
+			delete(l.lastCreations, creation.creator)
+		}
+		return
+	}
+
+	tempChannel := creation.tempChannel
+	joined := l.catchUpWithVoiceChatNoLock(tempChannel)
+	l.addTempChannelNoLock(tempChannel)
+
+	err := l.store.AddTempChannel(tempChannel.data())
+	if err != nil {
+		log.Printf("Failed to save temp channel %v, it won't survive a restart: %v", tempChannel.channelID, err)
+	}
+
+	for _, userID := range joined {
+		err = l.assignUserToTempChannelNoLock(userID, voiceChannelID)
+		if err != nil {
+			log.Printf("Failed to give user %v access to temp channel %v: %v", userID, tempChannel.channelID, err)
+		}
+	}
+
+	if len(tempChannel.members) == 0 {
+		l.scheduleDeletionNoLock(tempChannel)
+	} else if !tempChannel.members[tempChannel.ownerID] {
+		l.setOwnerNoLock(tempChannel, tempChannel.nextOwner())
+	}
+}
+
+// catchUpWithVoiceChatNoLock removes the access of the users who left the voice chat while its temp channel was created,
+// and returns the users who joined it in the meantime.
+// The owner is left as is, even if they left, until the users who joined are given access.
+func (l *TempChannelList) catchUpWithVoiceChatNoLock(tempChannel *TempChannel) []state.DiscordID {
+	participants, err := voiceChannelParticipants(l.session, tempChannel.serverID.RESTAPIFormat(), tempChannel.voiceChannelID)
+	if err != nil {
+		log.Printf("Failed to get the participants of voice chat %v: %v", tempChannel.voiceChannelID, err)
+		return nil
+	}
+
+	inVoiceChat := map[state.DiscordID]bool{}
+	joined := []state.DiscordID{}
+	for _, userID := range participants {
+		inVoiceChat[userID] = true
+		if !tempChannel.members[userID] {
+			joined = append(joined, userID)
+		}
+	}
+
+	for userID := range tempChannel.members {
+		if inVoiceChat[userID] {
+			continue
+		}
+
+		_, err := tempChannel.DenyUserAccess(userID)
+		if err != nil {
+			log.Printf("Failed to remove the access of user %v to temp channel %v: %v", userID, tempChannel.channelID, err)
+		}
+	}
+
+	return joined
+}
+
+func (l *TempChannelList) serverChannelCountNoLock(serverID state.DiscordID) int {
+	count := 0
+	for _, tempChannel := range l.tempChannelIDToTempChannel {
+		if tempChannel.serverID == serverID {
+			count++
+		}
+	}
+
+	// Channels that are being created already count towards the server's limit
+	for _, creation := range l.pendingCreations {
+		if creation.serverID == serverID {
+			count++
+		}
+	}
+
+	return count
+}
+
 // AddTempChannel adds a new temp channel to the list.
-func (l *TempChannelList) AddTempChannel(tempChannel *TempChannel) {
+func (l *TempChannelList) AddTempChannel(tempChannel *TempChannel) error {
 	l.Lock()
 	defer l.Unlock()
+	l.addTempChannelNoLock(tempChannel)
+	return l.store.AddTempChannel(tempChannel.data())
+}
+
+func (l *TempChannelList) addTempChannelNoLock(tempChannel *TempChannel) {
 	l.tempChannelIDToTempChannel[tempChannel.channelID] = tempChannel
 	l.voiceChannelIDToTempChannel[tempChannel.voiceChannelID] = tempChannel
 	for userID := range tempChannel.members {
 		l.userIDToTempChannel[userID] = tempChannel
 	}
+
+	for userID := range tempChannel.kicked {
+		l.kickedUserIDToTempChannel[userID] = tempChannel
+	}
+}
+
+// RemoveServer stops tracking the temp channels of a server the bot was removed from.
+// The channels aren't deleted, the bot no longer has access to them.
+// Returns the number of removed channels.
+func (l *TempChannelList) RemoveServer(serverID state.DiscordID) int {
+	l.Lock()
+	defer l.Unlock()
+
+	removed := 0
+	for _, tempChannel := range l.tempChannelIDToTempChannel {
+		if tempChannel.serverID != serverID {
+			continue
+		}
+
+		l.untrackTempChannelNoLock(tempChannel)
+		l.removeFromStore(tempChannel.channelID)
+		removed++
+	}
+
+	return removed
 }
 
 // DeleteAllChannels deletes all temp channels.
@@ -137,20 +718,64 @@ func (l *TempChannelList) DeleteAllChannels() {
 }
 
 func (l *TempChannelList) deleteTempChannelNoLock(tempChannel *TempChannel) {
+	l.untrackTempChannelNoLock(tempChannel)
+	l.deleteUntrackedChannel(tempChannel)
+}
+
+// deleteUntrackedChannel deletes a temp channel that was removed from the list, archiving it first if its server archives transcripts.
+// The list doesn't have to be held, as the channel is no longer in it.
+// The channel is removed from the store once it's deleted.
+func (l *TempChannelList) deleteUntrackedChannel(tempChannel *TempChannel) {
+	_, err := l.session.StateChannel(tempChannel.channelID.RESTAPIFormat())
+	if !existsInState(err) {
+		l.removeFromStore(tempChannel.channelID)
+		return
+	}
+
+	if serverData, archive := l.archiver.serverData(tempChannel); archive {
+		// Fetching the whole history may take a while, so it's done without holding the list.
+		// The channel stays in the store meanwhile, so if the bot stops before it's deleted, it's restored and archived again.
+		go l.archiveAndDelete(tempChannel, serverData)
+		return
+	}
+
+	l.deleteChannel(tempChannel)
+	l.removeFromStore(tempChannel.channelID)
+}
+
+// untrackTempChannelNoLock removes the temp channel from the list, without deleting it or removing it from the store.
+func (l *TempChannelList) untrackTempChannelNoLock(tempChannel *TempChannel) {
+	l.cancelDeletionNoLock(tempChannel)
+
 	for userID := range tempChannel.members {
 		delete(l.userIDToTempChannel, userID)
 	}
 
+	for userID := range tempChannel.kicked {
+		delete(l.kickedUserIDToTempChannel, userID)
+	}
+
 	delete(l.tempChannelIDToTempChannel, tempChannel.channelID)
 	delete(l.voiceChannelIDToTempChannel, tempChannel.voiceChannelID)
+}
 
-	_, err := l.session.State.Channel(tempChannel.channelID.RESTAPIFormat())
-	if existsInState(err) {
-		err := tempChannel.Delete()
-		if err != nil {
-			// TODO: check for permission error, notify the server
-			log.Fatalf("Failed to delete temp channel: %v", err)
-		}
+func (l *TempChannelList) archiveAndDelete(tempChannel *TempChannel, serverData state.ServerData) {
+	defer recoverEvent("archive")
+
+	err := l.archiver.Archive(tempChannel, serverData)
+	if err != nil {
+		reportError(l.session, l.servers, tempChannel.serverID, fmt.Sprintf("archive #%v", tempChannel.channel.Name), err)
+	}
+
+	l.deleteChannel(tempChannel)
+	l.removeFromStore(tempChannel.channelID)
+}
+
+// deleteChannel deletes the temp channel from Discord, channels that were already deleted are ignored.
+func (l *TempChannelList) deleteChannel(tempChannel *TempChannel) {
+	err := tempChannel.Delete()
+	if err != nil && !isNotFound(err) {
+		reportError(l.session, l.servers, tempChannel.serverID, fmt.Sprintf("delete #%v", tempChannel.channel.Name), err)
 	}
 }
 
@@ -159,6 +784,23 @@ func (l *TempChannelList) deleteTempChannelNoLock(tempChannel *TempChannel) {
 func (l *TempChannelList) AssignUserToTempChannel(userID state.DiscordID, voiceChannelID state.DiscordID) error {
 	l.Lock()
 	defer l.Unlock()
+	return l.assignUserToTempChannelNoLock(userID, voiceChannelID)
+}
+
+func (l *TempChannelList) assignUserToTempChannelNoLock(userID state.DiscordID, voiceChannelID state.DiscordID) error {
+	// Muting, deafening and streaming also update the voice state, the user stays in the same voice chat
+	if tempChannel, found := l.voiceChannelIDToTempChannel[voiceChannelID]; found && l.userIDToTempChannel[userID] == tempChannel {
+		return nil
+	}
+
+	if kickedFrom, found := l.kickedUserIDToTempChannel[userID]; found {
+		if kickedFrom.voiceChannelID == voiceChannelID {
+			// Kicked users get their access back only once they leave the voice chat and rejoin it
+			return nil
+		}
+
+		l.forgetKickNoLock(userID)
+	}
 
 	err := l.removeUserFromChannelNoLock(userID)
 	if err != nil {
@@ -171,19 +813,34 @@ func (l *TempChannelList) AssignUserToTempChannel(userID state.DiscordID, voiceC
 		return nil
 	}
 
+	if tempChannel.locked && tempChannel.ownerID != userID {
+		// Newcomers have to be invited by the owner
+		return nil
+	}
+
 	err = tempChannel.AllowUserAccess(userID)
 	if err != nil {
 		return err
 	}
 
+	l.cancelDeletionNoLock(tempChannel)
 	l.userIDToTempChannel[userID] = tempChannel
+	l.saveMembers(tempChannel)
+	tempChannel.postRecap(userID)
 	return nil
 }
 
 // RemoveUserFromChannel removes a user when from a voice chat when the user.
+// Users the owner invited keep their access.
 func (l *TempChannelList) RemoveUserFromChannel(userID state.DiscordID) error {
 	l.Lock()
 	defer l.Unlock()
+
+	l.forgetKickNoLock(userID)
+	if tempChannel, found := l.userIDToTempChannel[userID]; found && tempChannel.invited[userID] {
+		return nil
+	}
+
 	return l.removeUserFromChannelNoLock(userID)
 }
 
@@ -195,8 +852,17 @@ func (l *TempChannelList) removeUserFromChannelNoLock(userID state.DiscordID) er
 			return err
 		}
 
+		delete(l.userIDToTempChannel, userID)
+		l.saveMembers(oldChannel)
+		if oldChannel.invited[userID] {
+			delete(oldChannel.invited, userID)
+			l.saveInvited(oldChannel)
+		}
+
 		if channelEmpty {
-			l.deleteTempChannelNoLock(oldChannel)
+			l.scheduleDeletionNoLock(oldChannel)
+		} else if oldChannel.ownerID == userID {
+			l.setOwnerNoLock(oldChannel, oldChannel.nextOwner())
 		}
 	}
 
@@ -204,22 +870,242 @@ func (l *TempChannelList) removeUserFromChannelNoLock(userID state.DiscordID) er
 	return nil
 }
 
+// InviteUser gives a user that isn't in the voice chat access to the temp channel.
+// The user keeps the access until they're kicked, or join another voice chat.
+func (l *TempChannelList) InviteUser(tempChannel *TempChannel, userID state.DiscordID) error {
+	l.Lock()
+	defer l.Unlock()
+
+	if tempChannel.members[userID] {
+		return nil
+	}
+
+	err := tempChannel.AllowUserAccess(userID)
+	if err != nil {
+		return err
+	}
+
+	if l.kickedUserIDToTempChannel[userID] == tempChannel {
+		l.forgetKickNoLock(userID)
+	}
+
+	l.cancelDeletionNoLock(tempChannel)
+	l.userIDToTempChannel[userID] = tempChannel
+	tempChannel.invited[userID] = true
+	l.saveMembers(tempChannel)
+	l.saveInvited(tempChannel)
+	tempChannel.postRecap(userID)
+	return nil
+}
+
+// KickUser removes the access of a member of the temp channel.
+// Unless the channel is locked, the user gets the access back when rejoining the voice chat, but not while staying in it.
+func (l *TempChannelList) KickUser(tempChannel *TempChannel, userID state.DiscordID) error {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.userIDToTempChannel[userID] != tempChannel {
+		return nil
+	}
+
+	participants, err := voiceChannelParticipants(l.session, tempChannel.serverID.RESTAPIFormat(), tempChannel.voiceChannelID)
+	if err != nil {
+		return err
+	}
+
+	err = l.removeUserFromChannelNoLock(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, participantID := range participants {
+		if participantID == userID {
+			l.forgetKickNoLock(userID)
+			tempChannel.kicked[userID] = true
+			l.kickedUserIDToTempChannel[userID] = tempChannel
+		}
+	}
+
+	return nil
+}
+
+// forgetKickNoLock lets a kicked user get access to the temp channel again by joining its voice chat.
+func (l *TempChannelList) forgetKickNoLock(userID state.DiscordID) {
+	kickedFrom, found := l.kickedUserIDToTempChannel[userID]
+	if !found {
+		return
+	}
+
+	delete(kickedFrom.kicked, userID)
+	delete(l.kickedUserIDToTempChannel, userID)
+}
+
+// Owner returns the ID of the user controlling the temp channel.
+func (l *TempChannelList) Owner(tempChannel *TempChannel) state.DiscordID {
+	l.RLock()
+	defer l.RUnlock()
+	return tempChannel.ownerID
+}
+
+// IsLocked returns whether users that join the voice chat are kept out of the temp channel.
+func (l *TempChannelList) IsLocked(tempChannel *TempChannel) bool {
+	l.RLock()
+	defer l.RUnlock()
+	return tempChannel.locked
+}
+
+// SetOwner hands the control of the temp channel over to another user.
+func (l *TempChannelList) SetOwner(tempChannel *TempChannel, ownerID state.DiscordID) {
+	l.Lock()
+	defer l.Unlock()
+	l.setOwnerNoLock(tempChannel, ownerID)
+}
+
+func (l *TempChannelList) setOwnerNoLock(tempChannel *TempChannel, ownerID state.DiscordID) {
+	tempChannel.ownerID = ownerID
+	err := l.store.SetTempChannelOwner(tempChannel.channelID, ownerID)
+	if err != nil {
+		log.Printf("Failed to save the owner of temp channel %v: %v", tempChannel.channelID, err)
+	}
+}
+
+// SetLocked changes whether users that join the voice chat are given access to the temp channel.
+func (l *TempChannelList) SetLocked(tempChannel *TempChannel, locked bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	tempChannel.locked = locked
+	err := l.store.SetTempChannelLocked(tempChannel.channelID, locked)
+	if err != nil {
+		log.Printf("Failed to save the lock of temp channel %v: %v", tempChannel.channelID, err)
+	}
+}
+
+// RenameTempChannel changes the name of the temp channel.
+func (l *TempChannelList) RenameTempChannel(tempChannel *TempChannel, name string) error {
+	l.Lock()
+	defer l.Unlock()
+	return tempChannel.Rename(name)
+}
+
+// scheduleDeletionNoLock deletes an empty temp channel once its server's grace period passes.
+// The deletion is cancelled if a user joins the channel's voice chat before that.
+func (l *TempChannelList) scheduleDeletionNoLock(tempChannel *TempChannel) {
+	gracePeriod := time.Duration(0)
+	if serverData, found := l.servers.Server(tempChannel.serverID); found {
+		gracePeriod = serverData.DeletionGracePeriod()
+	}
+
+	if gracePeriod <= 0 {
+		l.deleteTempChannelNoLock(tempChannel)
+		return
+	}
+
+	if _, alreadyPending := l.pendingDeletions[tempChannel.channelID]; alreadyPending {
+		return
+	}
+
+	pending := &pendingDeletion{}
+	pending.timer = time.AfterFunc(gracePeriod, func() {
+		defer recoverEvent("scheduled deletion")
+
+		l.Lock()
+		defer l.Unlock()
+
+		// The deletion may have been cancelled while the timer was waiting for the lock
+		if l.pendingDeletions[tempChannel.channelID] != pending {
+			return
+		}
+
+		delete(l.pendingDeletions, tempChannel.channelID)
+		if len(tempChannel.members) == 0 {
+			l.deleteTempChannelNoLock(tempChannel)
+		}
+	})
+	l.pendingDeletions[tempChannel.channelID] = pending
+}
+
+func (l *TempChannelList) cancelDeletionNoLock(tempChannel *TempChannel) {
+	pending, found := l.pendingDeletions[tempChannel.channelID]
+	if !found {
+		return
+	}
+
+	pending.timer.Stop()
+	delete(l.pendingDeletions, tempChannel.channelID)
+}
+
+// StopTimers cancels all scheduled deletions, the channels are kept and handled on the next run.
+func (l *TempChannelList) StopTimers() {
+	l.Lock()
+	defer l.Unlock()
+	for channelID, pending := range l.pendingDeletions {
+		pending.timer.Stop()
+		delete(l.pendingDeletions, channelID)
+	}
+}
+
+// The in-memory list is the source of truth while the bot runs, failing to persist it only affects the next restart.
+func (l *TempChannelList) saveMembers(tempChannel *TempChannel) {
+	err := l.store.SetTempChannelMembers(tempChannel.channelID, tempChannel.memberIDs())
+	if err != nil {
+		log.Printf("Failed to save the members of temp channel %v: %v", tempChannel.channelID, err)
+	}
+}
+
+func (l *TempChannelList) saveInvited(tempChannel *TempChannel) {
+	err := l.store.SetTempChannelInvited(tempChannel.channelID, discordIDs(tempChannel.invited))
+	if err != nil {
+		log.Printf("Failed to save the invited members of temp channel %v: %v", tempChannel.channelID, err)
+	}
+}
+
+func (l *TempChannelList) removeFromStore(channelID state.DiscordID) {
+	err := l.store.RemoveTempChannel(channelID)
+	if err != nil {
+		log.Printf("Failed to remove temp channel %v from the store: %v", channelID, err)
+	}
+}
+
 // TempChannel is a temporary text channel created by the bot.
 type TempChannel struct {
 	channelID      state.DiscordID
 	voiceChannelID state.DiscordID
+	serverID       state.DiscordID
+	createdAt      time.Time
+
+	// ownerID is the user that may rename, lock, invite to and kick from the channel
+	ownerID state.DiscordID
+	locked  bool
+
+	// history is how members that join late see the messages sent before they joined
+	history state.HistoryVisibility
+
+	// lastActivity is when the last message was sent to the channel, used for expiring idle channels
+	lastActivity time.Time
+	// expiryWarnedAt is when the members were warned the channel expires, zero if they weren't
+	expiryWarnedAt time.Time
+	// rotating is set while the expired channel is being replaced with a new one
+	rotating bool
 
 	channel *discordgo.Channel
 
 	// Value isn't used, map is used for faster checks
 	members map[state.DiscordID]bool
+	// invited are the members the owner invited, who keep their access without being in the voice chat
+	invited map[state.DiscordID]bool
+	// kicked are the users kicked while in the voice chat, who don't get their access back until they leave it
+	kicked map[state.DiscordID]bool
 
-	session *discordgo.Session
+	session Session
 }
 
-// NewTempChannel creates a temporary channel for the given users.
-func NewTempChannel(context *CommandHandlerContext, voiceChannelID state.DiscordID, userIDs []state.DiscordID) (*TempChannel, error) {
-	channel, err := createTempChannel(context, userIDs)
+// NewTempChannel creates a temporary channel with the given name for the given users, controlled by the given owner.
+func NewTempChannel(session Session, serverData state.ServerData, botUserID state.DiscordID, voiceChannelID state.DiscordID, ownerID state.DiscordID, name string, userIDs []state.DiscordID) (*TempChannel, error) {
+	config := serverData.Config()
+	history := config.HistoryVisibilityFor(voiceChannelID)
+
+	channel, err := createTempChannel(session, serverData, botUserID, voiceChannelID, name, userIDs, history)
 	if err != nil {
 		return nil, err
 	}
@@ -234,17 +1120,60 @@ func NewTempChannel(context *CommandHandlerContext, voiceChannelID state.Discord
 		userIDsMap[userID] = true
 	}
 
+	createdAt := time.Now().UTC()
 	return &TempChannel{
 		channelID:      channelID,
 		voiceChannelID: voiceChannelID,
+		serverID:       serverData.ServerID(),
+		createdAt:      createdAt,
+		ownerID:        ownerID,
+		history:        history,
+		lastActivity:   createdAt,
 		channel:        channel,
 		members:        userIDsMap,
-		session:        context.Session,
+		invited:        map[state.DiscordID]bool{},
+		kicked:         map[state.DiscordID]bool{},
+		session:        session,
 	}, nil
 }
 
-func createTempChannel(context *CommandHandlerContext, userIDs []state.DiscordID) (*discordgo.Channel, error) {
-	everyoneRoleID, err := getEveryoneRoleID(context)
+// restoreTempChannel tracks an existing temp channel again.
+// The messages sent while the bot was down aren't known, so the channel is considered active at the time it's restored.
+func restoreTempChannel(session Session, data *state.TempChannelData, channel *discordgo.Channel, history state.HistoryVisibility) *TempChannel {
+	userIDsMap := map[state.DiscordID]bool{}
+	for _, userID := range data.Members {
+		userIDsMap[userID] = true
+	}
+
+	invited := map[state.DiscordID]bool{}
+	for _, userID := range data.Invited {
+		invited[userID] = true
+	}
+
+	return &TempChannel{
+		channelID:      data.ChannelID,
+		voiceChannelID: data.VoiceChannelID,
+		serverID:       data.ServerID,
+		createdAt:      data.CreatedAt,
+		ownerID:        data.OwnerID,
+		locked:         data.Locked,
+		history:        history,
+		lastActivity:   time.Now().UTC(),
+		channel:        channel,
+		members:        userIDsMap,
+		invited:        invited,
+		kicked:         map[state.DiscordID]bool{},
+		session:        session,
+	}
+}
+
+func createTempChannel(session Session, serverData state.ServerData, botUserID state.DiscordID, voiceChannelID state.DiscordID, name string, userIDs []state.DiscordID, history state.HistoryVisibility) (*discordgo.Channel, error) {
+	guild, err := session.StateGuild(serverData.ServerID().RESTAPIFormat())
+	if err != nil {
+		return nil, err
+	}
+
+	everyoneRoleID, err := findEveryoneRoleID(guild)
 	if err != nil {
 		return nil, err
 	}
@@ -252,42 +1181,47 @@ func createTempChannel(context *CommandHandlerContext, userIDs []state.DiscordID
 	overwrites := []*discordgo.PermissionOverwrite{
 		{
 			ID:   everyoneRoleID,
-			Type: consts.PermissionTypeRole,
-			Deny: discordgo.PermissionReadMessages,
+			Type: discordgo.PermissionOverwriteTypeRole,
+			Deny: discordgo.PermissionViewChannel,
 		},
 		{
-			ID:    context.BotUserID.RESTAPIFormat(),
-			Type:  consts.PermissionTypeMember,
-			Allow: discordgo.PermissionReadMessages | discordgo.PermissionManageChannels | discordgo.PermissionManageRoles,
+			ID:    botUserID.RESTAPIFormat(),
+			Type:  discordgo.PermissionOverwriteTypeMember,
+			Allow: discordgo.PermissionViewChannel | discordgo.PermissionManageChannels | discordgo.PermissionManageRoles | discordgo.PermissionReadMessageHistory,
 		},
 	}
 
+	allow, deny := memberPermissions(history)
 	for _, userID := range userIDs {
 		perm := &discordgo.PermissionOverwrite{
 			ID:    userID.RESTAPIFormat(),
-			Type:  consts.PermissionTypeMember,
-			Allow: discordgo.PermissionReadMessages,
-			Deny:  discordgo.PermissionReadMessageHistory,
+			Type:  discordgo.PermissionOverwriteTypeMember,
+			Allow: allow,
+			Deny:  deny,
 		}
 		overwrites = append(overwrites, perm)
 	}
 
 	creationData := discordgo.GuildChannelCreateData{
-		Name:                 randomdata.SillyName(),
+		Name:                 name,
 		Type:                 discordgo.ChannelTypeGuildText,
 		PermissionOverwrites: overwrites,
-		ParentID:             context.ServerData.TempChannelCategoryID().RESTAPIFormat(),
+		ParentID:             tempChannelCategoryID(session, serverData, voiceChannelID).RESTAPIFormat(),
 	}
 
-	return context.Session.GuildChannelCreateComplex(context.Event.GuildID, creationData)
+	return session.GuildChannelCreateComplex(guild.ID, creationData)
 }
 
 func getEveryoneRoleID(context *CommandHandlerContext) (string, error) {
-	guild, err := context.Session.State.Guild(context.Event.GuildID)
+	guild, err := context.Session.StateGuild(context.GuildID)
 	if err != nil {
 		return "", nil
 	}
 
+	return findEveryoneRoleID(guild)
+}
+
+func findEveryoneRoleID(guild *discordgo.Guild) (string, error) {
 	for _, role := range guild.Roles {
 		if role.Name == consts.EveryoneRoleName {
 			return role.ID, nil
@@ -297,6 +1231,65 @@ func getEveryoneRoleID(context *CommandHandlerContext) (string, error) {
 	return "", errors.New("@everyone not found")
 }
 
+func (c *TempChannel) memberIDs() []state.DiscordID {
+	return discordIDs(c.members)
+}
+
+// snapshot copies the temp channel, so the copy can be read without holding the list.
+func (c *TempChannel) snapshot() *TempChannel {
+	copied := *c
+	channel := *c.channel
+	copied.channel = &channel
+	copied.members = copyIDSet(c.members)
+	copied.invited = copyIDSet(c.invited)
+	copied.kicked = copyIDSet(c.kicked)
+	return &copied
+}
+
+func copyIDSet(set map[state.DiscordID]bool) map[state.DiscordID]bool {
+	copied := make(map[state.DiscordID]bool, len(set))
+	for id := range set {
+		copied[id] = true
+	}
+
+	return copied
+}
+
+// discordIDs returns the IDs in the set, in no particular order.
+func discordIDs(set map[state.DiscordID]bool) []state.DiscordID {
+	ids := make([]state.DiscordID, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func (c *TempChannel) data() *state.TempChannelData {
+	return &state.TempChannelData{
+		ChannelID:      c.channelID,
+		VoiceChannelID: c.voiceChannelID,
+		ServerID:       c.serverID,
+		Members:        c.memberIDs(),
+		CreatedAt:      c.createdAt,
+		OwnerID:        c.ownerID,
+		Locked:         c.locked,
+		Invited:        discordIDs(c.invited),
+	}
+}
+
+// nextOwner picks the member that takes over the channel when its owner leaves, the member with the lowest ID for consistency.
+func (c *TempChannel) nextOwner() state.DiscordID {
+	nextOwnerID := state.DiscordIDNone
+	for userID := range c.members {
+		if nextOwnerID == state.DiscordIDNone || userID < nextOwnerID {
+			nextOwnerID = userID
+		}
+	}
+
+	return nextOwnerID
+}
+
 // AllowUserAccess gives a user access to the temporary channel.
 func (c *TempChannel) AllowUserAccess(userID state.DiscordID) error {
 	_, userIsChannelMember := c.members[userID]
@@ -304,7 +1297,8 @@ func (c *TempChannel) AllowUserAccess(userID state.DiscordID) error {
 		log.Printf("User %v is already in the channel %v", userID, c.channel.Name)
 	}
 
-	err := c.session.ChannelPermissionSet(c.channel.ID, userID.RESTAPIFormat(), consts.PermissionTypeMember, discordgo.PermissionReadMessages, discordgo.PermissionReadMessageHistory)
+	allow, deny := memberPermissions(c.history)
+	err := c.session.ChannelPermissionSet(c.channel.ID, userID.RESTAPIFormat(), discordgo.PermissionOverwriteTypeMember, allow, deny)
 	if err != nil {
 		return err
 	}
@@ -330,6 +1324,17 @@ func (c *TempChannel) DenyUserAccess(userID state.DiscordID) (bool, error) {
 	return true, nil
 }
 
+// Rename changes the name of the temporary channel.
+func (c *TempChannel) Rename(name string) error {
+	channel, err := c.session.ChannelEdit(c.channel.ID, &discordgo.ChannelEdit{Name: name})
+	if err != nil {
+		return err
+	}
+
+	c.channel = channel
+	return nil
+}
+
 // Delete deletes the temporary channel.
 func (c *TempChannel) Delete() error {
 	_, err := c.session.ChannelDelete(c.channel.ID)
@@ -0,0 +1,311 @@
+package bot
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+func (s *BotTestSuite) TestJoiningVoiceGrantsAccess() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	s.True(s.canView(tempChannel, testUser2ID), "A user that joined the voice chat can't view the temp channel")
+
+	s.joinVoice(testUser3ID, s.voiceChannel2)
+	s.False(s.canView(tempChannel, testUser3ID), "A user that joined another voice chat can view the temp channel")
+}
+
+func (s *BotTestSuite) TestLeavingVoiceRevokesAccess() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+
+	s.joinVoice(testUser2ID, nil)
+	s.False(s.canView(tempChannel, testUser2ID), "A user that left the voice chat can still view the temp channel")
+	s.True(s.canView(tempChannel, testUser1ID), "A user that stayed in the voice chat lost access")
+
+	s.joinVoice(testUser1ID, s.voiceChannel2)
+	s.False(s.canView(tempChannel, testUser1ID), "A user that moved to another voice chat can still view the temp channel")
+}
+
+func (s *BotTestSuite) TestEmptyChannelIsDeleted() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	s.requireTempChannel()
+
+	s.joinVoice(testUser1ID, nil)
+	s.Empty(s.tempChannels(), "The temp channel wasn't deleted after everyone left")
+	s.Empty(s.savedTempChannels(), "The deleted temp channel is still saved")
+}
+
+func (s *BotTestSuite) TestGracePeriodKeepsEmptyChannel() {
+	serverData := s.setupServer()
+	s.Require().NoError(serverData.SetDeletionGracePeriod(time.Hour))
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+
+	s.joinVoice(testUser1ID, nil)
+	s.requireTempChannel()
+	s.Len(s.bot.tempChannels.pendingDeletions, 1, "The deletion wasn't scheduled")
+
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	s.Empty(s.bot.tempChannels.pendingDeletions, "Rejoining the voice chat didn't cancel the deletion")
+	s.True(s.canView(tempChannel, testUser2ID))
+}
+
+func (s *BotTestSuite) TestLockedChannelDoesNotGrantAccess() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+
+	s.bot.tempChannels.SetLocked(s.bot.tempChannels.tempChannelIDToTempChannel[s.parseID(tempChannel.ID)], true)
+
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	s.False(s.canView(tempChannel, testUser2ID), "A newcomer got access to a locked temp channel")
+}
+
+func (s *BotTestSuite) TestMutingKeepsAccessToLockedChannel() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+
+	s.bot.tempChannels.SetLocked(s.bot.tempChannels.tempChannelIDToTempChannel[s.parseID(tempChannel.ID)], true)
+
+	// Muting sends a voice state update for the same voice chat
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	s.True(s.canView(tempChannel, testUser2ID), "A member of a locked temp channel lost access by muting")
+}
+
+func (s *BotTestSuite) TestMutingDoesNotUndoKick() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+
+	s.Contains(s.runCommandIn(tempChannel, testUser1ID, "!kick "+testUser2ID), "The user was kicked from the channel")
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	s.False(s.canView(tempChannel, testUser2ID), "A kicked user got access back by muting")
+
+	s.joinVoice(testUser2ID, nil)
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	s.True(s.canView(tempChannel, testUser2ID), "A kicked user didn't get access back by rejoining the voice chat")
+}
+
+func (s *BotTestSuite) TestInvitedUserKeepsAccess() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+	s.Contains(s.runCommandIn(tempChannel, testUser1ID, "!invite "+testUser3ID), "Invited")
+
+	s.bot.GuildCreate(nil, &discordgo.GuildCreate{Guild: s.guild})
+	s.True(s.canView(tempChannel, testUser3ID), "An invited user lost access when the voice states were synced")
+
+	s.joinVoice(testUser3ID, s.voiceChannel1)
+	s.joinVoice(testUser3ID, nil)
+	s.True(s.canView(tempChannel, testUser3ID), "An invited user lost access by leaving the voice chat")
+
+	s.joinVoice(testUser3ID, s.voiceChannel2)
+	s.False(s.canView(tempChannel, testUser3ID), "An invited user kept access after joining another voice chat")
+}
+
+func (s *BotTestSuite) TestOwnerMutingKeepsOwnership() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.Equal(s.parseID(testUser1ID), s.bot.tempChannels.tempChannelIDToTempChannel[s.parseID(tempChannel.ID)].ownerID, "The owner lost the channel by muting")
+}
+
+func (s *BotTestSuite) TestAutoCreate() {
+	serverData := s.setupServer()
+	s.Require().NoError(serverData.SetAutoCreate(true))
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	tempChannel := s.requireTempChannel()
+	s.True(s.canView(tempChannel, testUser1ID))
+
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	s.requireTempChannel()
+	s.True(s.canView(tempChannel, testUser2ID))
+}
+
+func (s *BotTestSuite) TestDeletedVoiceChannelDeletesTempChannel() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	s.requireTempChannel()
+
+	channel, err := s.session.ChannelDelete(s.voiceChannel1.ID)
+	s.Require().NoError(err)
+	s.bot.ChannelDelete(nil, &discordgo.ChannelDelete{Channel: channel})
+
+	s.Empty(s.tempChannels(), "The temp channel of a deleted voice chat wasn't deleted")
+}
+
+func (s *BotTestSuite) TestRemovedFromServer() {
+	serverData := s.setupServer()
+	s.Require().NoError(serverData.SetCustomCommandPrefix("?"))
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "?mkch")
+	s.requireTempChannel()
+
+	s.bot.GuildDelete(nil, &discordgo.GuildDelete{Guild: &discordgo.Guild{ID: s.guild.ID}})
+	_, found := s.store.Server(s.serverID)
+	s.False(found, "The server is still in the store")
+	s.Empty(s.savedTempChannels(), "The temp channels of the server are still saved")
+	_, found = s.bot.tempChannels.GetTempChannelForVoiceChat(s.parseID(s.voiceChannel1.ID))
+	s.False(found, "The temp channels of the server are still tracked")
+
+	s.bot.GuildCreate(nil, &discordgo.GuildCreate{Guild: s.guild})
+	serverData, found = s.store.Server(s.serverID)
+	s.Require().True(found, "The server wasn't restored when the bot was invited back")
+	s.Equal("?", serverData.CommandPrefix())
+}
+
+func (s *BotTestSuite) TestRestoreSkipsForbiddenChannels() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+
+	// The bot was removed from the server while it was down
+	s.bot.Close()
+	s.session.RemoveBot(s.guild.ID)
+
+	var err error
+	s.bot, err = NewTempChannelBot(s.session, s.store, s.provider, s.provider, s.provider)
+	s.Require().NoError(err, "A channel the bot can't access stopped the startup")
+	_, found := s.bot.tempChannels.GetTempChannelForVoiceChat(s.parseID(s.voiceChannel1.ID))
+	s.False(found)
+	s.Len(s.savedTempChannels(), 1, "A temp channel that couldn't be fetched was dropped")
+}
+
+func (s *BotTestSuite) TestUnavailableServerIsKept() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+
+	s.bot.GuildDelete(nil, &discordgo.GuildDelete{Guild: &discordgo.Guild{ID: s.guild.ID, Unavailable: true}})
+	_, found := s.store.Server(s.serverID)
+	s.True(found, "A server that's only unavailable was removed")
+	s.Len(s.savedTempChannels(), 1, "The temp channels of an unavailable server were dropped")
+}
+
+func (s *BotTestSuite) TestRemovedFromServerWhileDown() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	application := &discordgo.Application{ID: "1"}
+
+	s.bot.Ready(nil, &discordgo.Ready{Application: application, Guilds: []*discordgo.Guild{{ID: s.guild.ID, Unavailable: true}}})
+	_, found := s.store.Server(s.serverID)
+	s.True(found, "A server the bot is still in was removed")
+	s.Len(s.savedTempChannels(), 1, "The temp channels of a server the bot is still in were dropped")
+
+	s.bot.Ready(nil, &discordgo.Ready{Application: application, Guilds: []*discordgo.Guild{}})
+	_, found = s.store.Server(s.serverID)
+	s.False(found, "A server the bot was removed from is still in the store")
+	s.Empty(s.savedTempChannels(), "The temp channels of the server are still saved")
+	_, found = s.bot.tempChannels.GetTempChannelForVoiceChat(s.parseID(s.voiceChannel1.ID))
+	s.False(found, "The temp channels of the server are still tracked")
+}
+
+// createWhile creates a temp channel for the user's voice chat, running the event while the channel is created.
+// Fails instead of blocking if the event waits for the creation.
+func (s *BotTestSuite) createWhile(userID string, voiceChannel *discordgo.Channel, event func(), createErr error) (*TempChannel, bool, error) {
+	serverData, found := s.bot.store.Server(s.serverID)
+	s.Require().True(found)
+
+	voiceChannelID := s.parseID(voiceChannel.ID)
+	create := func(channelNumber int) (*TempChannel, error) {
+		done := make(chan struct{})
+		go func() {
+			event()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			s.Fail("The temp channels were held while the channel was created")
+		}
+
+		if createErr != nil {
+			return nil, createErr
+		}
+
+		return NewTempChannel(s.session, serverData, s.bot.botUserID, voiceChannelID, s.parseID(userID), "temp", []state.DiscordID{s.parseID(userID)})
+	}
+
+	return s.bot.tempChannels.CreateTempChannel(s.serverID, voiceChannelID, s.parseID(userID), CreationLimits{Cooldown: time.Minute}, create)
+}
+
+func (s *BotTestSuite) TestCreationCatchesUpWithVoiceChat() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+
+	_, created, err := s.createWhile(testUser1ID, s.voiceChannel1, func() {
+		s.joinVoice(testUser3ID, s.voiceChannel1)
+		s.joinVoice(testUser1ID, nil)
+	}, nil)
+	s.Require().NoError(err)
+	s.True(created)
+
+	tempChannel := s.requireTempChannel()
+	s.True(s.canView(tempChannel, testUser3ID), "A user who joined during the creation can't view the temp channel")
+	s.False(s.canView(tempChannel, testUser1ID), "A user who left during the creation can still view the temp channel")
+	joined, found := s.bot.tempChannels.GetTempChannelForUser(s.parseID(testUser3ID))
+	s.Require().True(found)
+	s.NotEqual(s.parseID(testUser1ID), s.bot.tempChannels.Owner(joined), "The user who left still owns the temp channel")
+}
+
+func (s *BotTestSuite) TestConcurrentCreationGetsTheSameChannel() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+
+	secondCreation := make(chan bool)
+	tempChannel, created, err := s.createWhile(testUser1ID, s.voiceChannel1, func() {
+		go func() {
+			_, created, err := s.createWhile(testUser2ID, s.voiceChannel1, func() {}, nil)
+			s.NoError(err)
+			secondCreation <- created
+		}()
+	}, nil)
+	s.Require().NoError(err)
+	s.True(created)
+	s.False(<-secondCreation, "A second temp channel was created for the voice chat")
+
+	s.Equal(tempChannel.channel.ID, s.requireTempChannel().ID)
+}
+
+func (s *BotTestSuite) TestFailedCreationReleasesVoiceChat() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+
+	_, _, err := s.createWhile(testUser1ID, s.voiceChannel1, func() {}, errors.New("Discord is down"))
+	s.Require().Error(err)
+	s.Empty(s.bot.tempChannels.pendingCreations)
+
+	_, created, err := s.createWhile(testUser1ID, s.voiceChannel1, func() {}, nil)
+	s.Require().NoError(err, "The failed creation started the user's cooldown")
+	s.True(created)
+}
@@ -1,9 +1,13 @@
 package bot
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/jonathroth/temp-chat/consts"
@@ -12,19 +16,253 @@ import (
 
 func (b *TempChannelBot) initCommands() map[string]*Command {
 	return map[string]*Command{
-		"help":                           {SetupRequired: false, AdminOnly: false, Handler: helpHandler},
-		"setup":                          {SetupRequired: false, AdminOnly: true, Handler: b.setupHandler},
-		consts.DefaultMakeChannelCommand: {SetupRequired: true, AdminOnly: false, Handler: b.mkchHandler},
-		"set-mkch":                       {SetupRequired: true, AdminOnly: true, Handler: b.setMkchHandler},
-		"set-prefix":                     {SetupRequired: true, AdminOnly: true, Handler: b.setPrefixHandler},
-		"set-command-ch":                 {SetupRequired: true, AdminOnly: true, Handler: b.setCommandChannelHandler},
+		"help": {
+			SetupRequired: false, AdminOnly: false, Handler: helpHandler,
+			Description: "Displays the help menu",
+		},
+		"setup": {
+			SetupRequired: false, AdminOnly: true, Handler: b.setupHandler,
+			Description: "Sets the category the temp channels are created in",
+			Options: []*discordgo.ApplicationCommandOption{
+				channelOption("category", "The category to create temp channels in", true, discordgo.ChannelTypeGuildCategory),
+			},
+		},
+		consts.DefaultMakeChannelCommand: {
+			SetupRequired: true, AdminOnly: false, Handler: b.mkchHandler,
+			Description: "Creates a temp channel for the users in your voice chat",
+		},
+		"set-mkch": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setMkchHandler,
+			Description: "Changes the name of the mkch prefix command, resets it if no name is given",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("name", "The new command name", false),
+			},
+		},
+		"set-prefix": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setPrefixHandler,
+			Description: "Changes the command prefix, resets it if no prefix is given",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("prefix", "The new command prefix", false),
+			},
+		},
+		"set-command-ch": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setCommandChannelHandler,
+			Description: "Sets the only channel prefix commands are read from, removes it if no channel is given",
+			Options: []*discordgo.ApplicationCommandOption{
+				channelOption("channel", "The command channel", false, discordgo.ChannelTypeGuildText),
+			},
+		},
+		"set-orphan-policy": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setOrphanPolicyHandler,
+			Description: "Sets what the bot does with channels in the temp category it didn't create",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("policy", "The orphan channel policy", false, consts.ValidOrphanPolicies...),
+			},
+		},
+		"set-auto-create": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setAutoCreateHandler,
+			Description: "Turns on/off creating temp channels automatically when users join a voice chat",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("state", "Whether automatic creation is on", true, "on", "off"),
+			},
+		},
+		"set-auto-create-ch": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setAutoCreateChannelsHandler,
+			Description: "Limits the automatic creation to specific voice channels, applies it to all if none are given",
+			Options:     channelOptions("voice-channel", "A voice channel to create temp channels for", maxChannelOptions, discordgo.ChannelTypeGuildVoice),
+		},
+		"set-channel-name": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setChannelNameHandler,
+			Description: "Sets the temp channel name template, resets it to random names if no template is given",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("template", "The name template, e.g. {voice}-{n}", false),
+			},
+		},
+		"set-archive": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setArchiveHandler,
+			Description: "Sets the format temp channel transcripts are archived in before deletion, or turns archiving off",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("format", "The transcript format", true, append([]string{"off"}, consts.ValidArchiveFormats...)...),
+			},
+		},
+		"set-archive-ch": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setArchiveChannelHandler,
+			Description: "Sets the channel transcripts are posted to, keeps them in the database if no channel is given",
+			Options: []*discordgo.ApplicationCommandOption{
+				channelOption("channel", "The archive channel", false, discordgo.ChannelTypeGuildText),
+			},
+		},
+		"set-grace-period": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setGracePeriodHandler,
+			Description: "Sets how long an empty temp channel is kept before it's deleted, deletes immediately if not given",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("period", "Seconds, or a duration such as 30s or 5m", false),
+			},
+		},
+		"set-history": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setHistoryHandler,
+			Description: "Sets how members that join a temp channel late see the messages sent before they joined, hides them if not given",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("mode", "The history mode", false, consts.ValidHistoryModes...),
+				stringOption("minutes", "How far back the recap goes, for the recap mode", false),
+			},
+		},
+		"set-max-age": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setMaxAgeHandler,
+			Description: "Sets how long a temp channel is kept before it expires, never expires for its age if not given",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("duration", "A duration such as 12h or 72h", false),
+			},
+		},
+		"set-max-idle": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setMaxIdleHandler,
+			Description: "Sets how long a temp channel is kept without messages before it expires, never expires for being idle if not given",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("duration", "A duration such as 30m or 2h", false),
+			},
+		},
+		"set-expiry-action": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setExpiryActionHandler,
+			Description: "Sets whether expired temp channels are deleted or replaced with new ones, deletes them if not given",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("action", "What's done with expired temp channels", false, consts.ValidExpiryActions...),
+			},
+		},
+		"set-mkch-cooldown": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setMkchCooldownHandler,
+			Description: "Sets how long a user waits between creating temp channels, no cooldown if not given",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("cooldown", "Seconds, or a duration such as 30s or 5m", false),
+			},
+		},
+		"set-max-channels": {
+			SetupRequired: true, AdminOnly: true, Handler: b.setMaxChannelsHandler,
+			Description: "Sets the most temp channels the server may have at once, no limit if not given",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("count", "The maximum number of temp channels", false),
+			},
+		},
+		"rename": {
+			SetupRequired: true, AdminOnly: false, Handler: b.renameHandler,
+			Description: "Renames the temp channel you own, run inside the temp channel",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("name", "The new channel name", true),
+			},
+		},
+		"lock": {
+			SetupRequired: true, AdminOnly: false, Handler: b.lockHandler,
+			Description: "Stops giving users that join your voice chat access to your temp channel",
+		},
+		"unlock": {
+			SetupRequired: true, AdminOnly: false, Handler: b.unlockHandler,
+			Description: "Gives users that join your voice chat access to your temp channel again",
+		},
+		"invite": {
+			SetupRequired: true, AdminOnly: false, Handler: b.inviteHandler,
+			Description: "Gives a user that isn't in your voice chat access to your temp channel",
+			Options: []*discordgo.ApplicationCommandOption{
+				userOption("user", "The user to invite", true),
+			},
+		},
+		"kick": {
+			SetupRequired: true, AdminOnly: false, Handler: b.kickHandler,
+			Description: "Removes a user's access to your temp channel",
+			Options: []*discordgo.ApplicationCommandOption{
+				userOption("user", "The user to kick", true),
+			},
+		},
+		"transfer": {
+			SetupRequired: true, AdminOnly: false, Handler: b.transferHandler,
+			Description: "Hands the control of your temp channel over to another member of it",
+			Options: []*discordgo.ApplicationCommandOption{
+				userOption("user", "The new owner", true),
+			},
+		},
+		"route-add": {
+			SetupRequired: true, AdminOnly: true, Handler: b.routeAddHandler,
+			Description: "Creates the temp channels of a voice channel, or of the voice channels in a category, in a specific category",
+			Options: []*discordgo.ApplicationCommandOption{
+				channelOption("source", "The voice channel or the category of voice channels", true, discordgo.ChannelTypeGuildVoice, discordgo.ChannelTypeGuildCategory),
+				channelOption("category", "The category to create their temp channels in", true, discordgo.ChannelTypeGuildCategory),
+			},
+		},
+		"route-remove": {
+			SetupRequired: true, AdminOnly: true, Handler: b.routeRemoveHandler,
+			Description: "Creates the temp channels of a voice channel or a category in the default category again",
+			Options: []*discordgo.ApplicationCommandOption{
+				channelOption("source", "The voice channel or the category of voice channels", true, discordgo.ChannelTypeGuildVoice, discordgo.ChannelTypeGuildCategory),
+			},
+		},
+		"route-list": {
+			SetupRequired: true, AdminOnly: true, Handler: b.routeListHandler,
+			Description: "Lists the categories temp channels are created in",
+		},
+		"voice-policy": {
+			SetupRequired: true, AdminOnly: true, Handler: b.voicePolicyHandler,
+			Description: "Shows or changes the settings a voice channel overrides, lists all the overrides if no channel is given",
+			Options: []*discordgo.ApplicationCommandOption{
+				channelOption("voice-channel", "The voice channel", false, discordgo.ChannelTypeGuildVoice),
+				stringOption("setting", "The setting to change", false, voicePolicySettings...),
+				stringOption("value", "on/off for mkch, force/default for auto-create, a template or default for name, a mode for history", false),
+			},
+		},
+		"perm-add": {
+			SetupRequired: true, AdminOnly: true, Handler: b.permAddHandler,
+			Description: "Allows a role, a user, or anyone with the given permissions to run a command",
+			Options:     permissionRuleOptions(),
+		},
+		"perm-remove": {
+			SetupRequired: true, AdminOnly: true, Handler: b.permRemoveHandler,
+			Description: "Removes a rule added by perm-add",
+			Options:     permissionRuleOptions(),
+		},
+		"history": {
+			SetupRequired: true, AdminOnly: true, Handler: b.historyHandler,
+			Description: "Lists the recent changes to the bot's settings, newest first",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("page", "The page number, 1 by default", false),
+			},
+		},
+		"revert": {
+			SetupRequired: true, AdminOnly: true, Handler: b.revertHandler,
+			Description: "Sets a setting back to its value before a change listed by history",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("change", "The change number, e.g. 12", true),
+			},
+		},
+		"export": {
+			SetupRequired: true, AdminOnly: true, Handler: b.exportHandler,
+			Description: "Sends the bot's settings as a file, to be restored or copied to another server by import",
+		},
+		"import": {
+			SetupRequired: true, AdminOnly: true, Handler: b.importHandler,
+			Description: "Lists the changes the settings file created by export would make, and applies them if asked to",
+			Options: []*discordgo.ApplicationCommandOption{
+				attachmentOption("file", "The settings file created by export", true),
+				stringOption("mode", "Whether to only list the changes or apply them, preview by default", false, importModePreview, importModeApply),
+			},
+		},
+		"perm-list": {
+			SetupRequired: true, AdminOnly: true, Handler: b.permListHandler,
+			Description: "Lists who may run each command, or a specific command if given",
+			Options: []*discordgo.ApplicationCommandOption{
+				stringOption("command", "The command name, e.g. mkch", false),
+			},
+		},
 	}
 }
 
 // CommandHandlerContext are the parameters passed to a command handler.
 type CommandHandlerContext struct {
-	Session *discordgo.Session
-	Event   *discordgo.MessageCreate
+	Session Session
+	// Event is the message the command was parsed from, nil for application commands.
+	Event *discordgo.MessageCreate
+	// Interaction is the application command interaction, nil for commands parsed from messages.
+	Interaction *discordgo.Interaction
+
+	GuildID   string
+	ChannelID string
+	AuthorID  string
 
 	BotUserID state.DiscordID
 
@@ -35,23 +273,95 @@ type CommandHandlerContext struct {
 	CommandArgs []string
 
 	replyFormatter replyFormatter
+	replied        bool
 }
 
 // NewCommandHandlerContext initializes a new instance of CommandHandlerContext.
-func NewCommandHandlerContext(session *discordgo.Session, event *discordgo.MessageCreate, botUserID state.DiscordID) *CommandHandlerContext {
+func NewCommandHandlerContext(session Session, event *discordgo.MessageCreate, botUserID state.DiscordID) *CommandHandlerContext {
 	return &CommandHandlerContext{
 		Session:        session,
 		Event:          event,
+		GuildID:        event.GuildID,
+		ChannelID:      event.ChannelID,
+		AuthorID:       event.Author.ID,
+		BotUserID:      botUserID,
+		replyFormatter: backtickReplyFormatter,
+	}
+}
+
+// NewInteractionCommandHandlerContext initializes a new instance of CommandHandlerContext for an application command.
+func NewInteractionCommandHandlerContext(session Session, interaction *discordgo.Interaction, botUserID state.DiscordID) *CommandHandlerContext {
+	author := interaction.User
+	if interaction.Member != nil {
+		author = interaction.Member.User
+	}
+
+	return &CommandHandlerContext{
+		Session:        session,
+		Interaction:    interaction,
+		GuildID:        interaction.GuildID,
+		ChannelID:      interaction.ChannelID,
+		AuthorID:       author.ID,
 		BotUserID:      botUserID,
 		replyFormatter: backtickReplyFormatter,
 	}
 }
 
 func (c *CommandHandlerContext) replyUnformatted(message string) {
-	_, err := c.Session.ChannelMessageSend(c.Event.ChannelID, message)
+	c.replied = true
+
+	// A failed reply has no one to be reported to, so it's only logged
+	if c.Interaction != nil {
+		_, err := c.Session.FollowupMessageCreate(c.Interaction, true, &discordgo.WebhookParams{Content: message, Flags: discordgo.MessageFlagsEphemeral})
+		if err != nil {
+			log.Printf("Failed sending interaction response (%v): %v", errorKindOf(err), err)
+		}
+		return
+	}
+
+	_, err := c.Session.ChannelMessageSend(c.ChannelID, message)
 	if err != nil {
-		log.Fatalf("Failed sending message response: %v", err)
+		log.Printf("Failed sending message response (%v): %v", errorKindOf(err), err)
+	}
+}
+
+// replyWithFile replies with a message that has a file attached.
+func (c *CommandHandlerContext) replyWithFile(message string, file *discordgo.File) {
+	c.replied = true
+
+	if c.Interaction != nil {
+		_, err := c.Session.FollowupMessageCreate(c.Interaction, true, &discordgo.WebhookParams{
+			Content: message, Files: []*discordgo.File{file}, Flags: discordgo.MessageFlagsEphemeral,
+		})
+		if err != nil {
+			log.Printf("Failed sending interaction response (%v): %v", errorKindOf(err), err)
+		}
+		return
+	}
+
+	_, err := c.Session.ChannelMessageSendComplex(c.ChannelID, &discordgo.MessageSend{Content: message, Files: []*discordgo.File{file}})
+	if err != nil {
+		log.Printf("Failed sending message response (%v): %v", errorKindOf(err), err)
+	}
+}
+
+// attachments returns the files attached to the command's message, or given as options of the application command.
+func (c *CommandHandlerContext) attachments() []*discordgo.MessageAttachment {
+	if c.Interaction == nil {
+		return c.Event.Attachments
+	}
+
+	resolved := c.Interaction.ApplicationCommandData().Resolved
+	if resolved == nil {
+		return nil
 	}
+
+	attachments := []*discordgo.MessageAttachment{}
+	for _, attachment := range resolved.Attachments {
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments
 }
 
 func (c *CommandHandlerContext) reply(message string, args ...interface{}) {
@@ -63,119 +373,109 @@ func (c *CommandHandlerContext) logAndReply(message string, args ...interface{})
 	c.reply(message, args...)
 }
 
-func (c *CommandHandlerContext) hasServerPermission(userID state.DiscordID, wantedPermission int) bool {
-	member, err := c.Session.State.Member(c.Event.GuildID, userID.RESTAPIFormat())
+// userPermissions resolves the permissions of the user in a channel of the server, including the channel's overwrites.
+// An empty channel ID resolves the user's server wide permissions.
+func (c *CommandHandlerContext) userPermissions(userID state.DiscordID, channelID string) (int64, error) {
+	guild, err := c.Session.StateGuild(c.GuildID)
 	if err != nil {
-		log.Printf("Bot couldn't find the bot in a server it's already in: %v", err) // TODO: error log?
-		return false
+		return 0, fmt.Errorf("Couldn't find server %v: %v", c.GuildID, err)
 	}
 
-	server, err := c.Session.State.Guild(c.Event.GuildID)
+	member, err := c.Session.StateMember(c.GuildID, userID.RESTAPIFormat())
 	if err != nil {
-		log.Fatalf("Failed to parse server ID: %v", err)
-		return false
-	}
-
-	if userID.Equals(server.OwnerID) {
-		return true
+		return 0, fmt.Errorf("Couldn't find member %v: %v", userID, err)
 	}
 
-	everyoneRoleID, err := getEveryoneRoleID(c)
-	if err != nil {
-		log.Fatalf("Failed to get @everyone role ID: %v", err)
-		return false
+	if channelID == "" {
+		return resolvePermissions(guild, member, nil), nil
 	}
 
-	everyone, err := c.Session.State.Role(c.Event.GuildID, everyoneRoleID)
+	channel, err := c.Session.StateChannel(channelID)
 	if err != nil {
-		log.Fatalf("Failed to get @everyone role: %v", err)
-		return false
-	}
-
-	permissions := everyone.Permissions
-
-	for _, roleID := range member.Roles {
-		role, err := c.Session.State.Role(c.Event.GuildID, roleID)
-		if err != nil {
-			log.Printf("Couldn't find a role the bot owns: %v", err) // TODO: error log?
-			return false
-		}
-
-		permissions |= role.Permissions
+		return 0, fmt.Errorf("Couldn't find channel %v: %v", channelID, err)
 	}
 
-	return permissions&wantedPermission != 0
+	return resolvePermissions(guild, member, channel), nil
 }
 
-func (c *CommandHandlerContext) hasChannelPermission(channelID state.DiscordID, wantedPermission int) bool {
-	permissions, err := c.Session.UserChannelPermissions(c.BotUserID.RESTAPIFormat(), channelID.RESTAPIFormat())
+// hasChannelPermission tells whether the bot has all of the wanted permissions in the channel.
+func (c *CommandHandlerContext) hasChannelPermission(channelID state.DiscordID, wantedPermission int64) bool {
+	permissions, err := c.userPermissions(c.BotUserID, channelID.RESTAPIFormat())
 	if err != nil {
 		log.Printf("Failed to get permissions: %v", err)
 		return false
 	}
 
-	return permissions&wantedPermission != 0
+	return permissions&wantedPermission == wantedPermission
 }
 
-func (c *CommandHandlerContext) getUserVoiceChannelID(userID state.DiscordID) state.DiscordID {
-	guild, err := c.Session.State.Guild(c.Event.GuildID)
+func (c *CommandHandlerContext) getUserVoiceChannelID(userID state.DiscordID) (state.DiscordID, error) {
+	guild, err := c.Session.StateGuild(c.GuildID)
 	if err != nil {
-		log.Fatalf("Bot couldn't find a guild it got a message from: %v", err)
+		return state.DiscordIDNone, fmt.Errorf("Bot couldn't find a guild it got a message from: %w", err)
 	}
 
 	for _, voiceState := range guild.VoiceStates {
 		if voiceState.UserID == userID.RESTAPIFormat() {
 			id, err := state.ParseDiscordID(voiceState.ChannelID)
 			if err != nil {
-				log.Fatalf("Bot couldn't parse voice channel ID of user inside a voice channel: %v", err)
-			}
-
-			return id
-		}
-	}
-
-	return state.DiscordIDNone
-}
-
-func (c *CommandHandlerContext) getVoiceChannelParticipants(voiceChanelID state.DiscordID) []state.DiscordID {
-	guild, err := c.Session.State.Guild(c.Event.GuildID)
-	if err != nil {
-		log.Fatalf("Bot couldn't find a guild it got a message from: %v", err)
-	}
-
-	participants := []state.DiscordID{}
-
-	for _, voiceState := range guild.VoiceStates {
-		if voiceState.ChannelID == voiceChanelID.RESTAPIFormat() {
-			id, err := state.ParseDiscordID(voiceState.UserID)
-			if err != nil {
-				log.Fatalf("Bot couldn't parse voice channel ID of user inside a voice channel: %v", err)
+				return state.DiscordIDNone, fmt.Errorf("Bot couldn't parse voice channel ID of user inside a voice channel: %w", err)
 			}
 
-			participants = append(participants, id)
+			return id, nil
 		}
 	}
 
-	return participants
+	return state.DiscordIDNone, nil
 }
 
 func (c *CommandHandlerContext) isDM() bool {
-	return c.Event.GuildID == ""
+	return c.GuildID == ""
 }
 
 func (c *CommandHandlerContext) categoryExists(categoryID string) bool {
-	channel, err := c.Session.State.Channel(categoryID)
-	return existsInState(err) && channel.GuildID == c.Event.GuildID && channel.Type == discordgo.ChannelTypeGuildCategory
+	channel, err := c.Session.StateChannel(categoryID)
+	return existsInState(err) && channel.GuildID == c.GuildID && channel.Type == discordgo.ChannelTypeGuildCategory
 }
 
 func (c *CommandHandlerContext) textChannelExists(channelID string) bool {
-	channel, err := c.Session.State.Channel(channelID)
-	return existsInState(err) && channel.GuildID == c.Event.GuildID && channel.Type == discordgo.ChannelTypeGuildText
+	channel, err := c.Session.StateChannel(channelID)
+	return existsInState(err) && channel.GuildID == c.GuildID && channel.Type == discordgo.ChannelTypeGuildText
+}
+
+func (c *CommandHandlerContext) voiceChannelExists(channelID string) bool {
+	channel, err := c.Session.StateChannel(channelID)
+	return existsInState(err) && channel.GuildID == c.GuildID && channel.Type == discordgo.ChannelTypeGuildVoice
 }
 
 func (c *CommandHandlerContext) channelExists(channelID string) bool {
-	channel, err := c.Session.State.Channel(channelID)
-	return existsInState(err) && channel.GuildID == c.Event.GuildID
+	channel, err := c.Session.StateChannel(channelID)
+	return existsInState(err) && channel.GuildID == c.GuildID
+}
+
+// parseUserMention parses either a user mention or a raw user ID.
+func parseUserMention(value string) (state.DiscordID, error) {
+	if strings.HasPrefix(value, "<@") && strings.HasSuffix(value, ">") {
+		value = strings.TrimPrefix(strings.TrimSuffix(strings.TrimPrefix(value, "<@"), ">"), "!")
+	}
+
+	return state.ParseDiscordID(value)
+}
+
+// memberExists checks whether the user is a member of the server, fetching the member if it isn't in the state.
+func (c *CommandHandlerContext) memberExists(userID state.DiscordID) bool {
+	_, err := c.Session.StateMember(c.GuildID, userID.RESTAPIFormat())
+	if err == nil {
+		return true
+	}
+
+	member, err := c.Session.GuildMember(c.GuildID, userID.RESTAPIFormat())
+	if err != nil {
+		return false
+	}
+
+	trackMember(c.Session, c.GuildID, member)
+	return true
 }
 
 func existsInState(err error) bool {
@@ -183,7 +483,7 @@ func existsInState(err error) bool {
 }
 
 // CommandHandler is a handler func called when a command is successfully parsed.
-// An error returned by the handler will cause the bot to exit with a log.Fatal.
+// An error returned by the handler is logged and reported to the user according to its kind, see handleCommandError.
 type CommandHandler func(*CommandHandlerContext) error
 
 // Command defines the logic and conditions required for a command to run.
@@ -192,15 +492,23 @@ type Command struct {
 	SetupRequired bool
 	AdminOnly     bool
 	Handler       CommandHandler
+
+	// Description is shown to users in the application command picker.
+	Description string
+	// Options are the typed application command options.
+	// Their values are passed to the handler as CommandArgs, in the order they are defined.
+	Options []*discordgo.ApplicationCommandOption
 }
 
 // MessageCreate is called whenever a message arrives in a server the bot is in.
-func (b *TempChannelBot) MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
+func (b *TempChannelBot) MessageCreate(_ *discordgo.Session, m *discordgo.MessageCreate) {
+	defer recoverEvent("MessageCreate")
+
 	if m.Author.ID == b.botUserID.RESTAPIFormat() || (!b.AllowBots && m.Author.Bot) {
 		return
 	}
 
-	context := NewCommandHandlerContext(s, m, b.botUserID)
+	context := NewCommandHandlerContext(b.session, m, b.botUserID)
 
 	if context.isDM() {
 		b.handleDM(context)
@@ -209,7 +517,13 @@ func (b *TempChannelBot) MessageCreate(s *discordgo.Session, m *discordgo.Messag
 
 	serverID, err := state.ParseDiscordID(m.GuildID)
 	if err != nil {
-		log.Fatalf("Failed to parse discord server ID: %v", err)
+		log.Printf("Failed to parse discord server ID: %v", err)
+		return
+	}
+
+	// Any message postpones the expiry of an idle temp channel, including commands
+	if channelID, err := state.ParseDiscordID(m.ChannelID); err == nil {
+		b.tempChannels.RecordActivity(channelID, time.Now())
 	}
 
 	serverData, serverIsSetup := b.store.Server(serverID)
@@ -226,12 +540,13 @@ func (b *TempChannelBot) MessageCreate(s *discordgo.Session, m *discordgo.Messag
 		return
 	}
 
-	if serverIsSetup && serverData.HasCommandChannelID() && serverData.CommandChannelID().NotEquals(m.ChannelID) {
+	// Temp channel owners control their channel from inside it, so temp channels also accept commands
+	if serverIsSetup && serverData.HasCommandChannelID() && serverData.CommandChannelID().NotEquals(m.ChannelID) && !b.isTempChannel(m.ChannelID) {
 		if !context.channelExists(serverData.CommandChannelID().RESTAPIFormat()) {
-			err := serverData.ClearCommandChannelID()
+			err := state.NewAuditedServerData(serverData, b.configChanges, b.botUserID).ClearCommandChannelID()
 			if err != nil {
-				context.reply("An internal error has occurred")
-				log.Fatalf("ClearCommandChannelID failed: %v", err)
+				b.handleCommandError(context, fmt.Errorf("ClearCommandChannelID failed: %w", err))
+				return
 			}
 
 			context.logAndReply("The custom command channel was deleted, and is therefore unset")
@@ -247,9 +562,29 @@ func (b *TempChannelBot) MessageCreate(s *discordgo.Session, m *discordgo.Messag
 		return
 	}
 
+	// Only the authors of commands are tracked, so the state doesn't grow with every message
+	if m.Member != nil {
+		m.Member.User = m.Author
+		trackMember(b.session, m.GuildID, m.Member)
+	}
+
 	b.handleCommand(context, prefix)
 }
 
+func (b *TempChannelBot) isTempChannel(channelID string) bool {
+	id, err := state.ParseDiscordID(channelID)
+	return err == nil && b.tempChannels.IsTempChannel(id)
+}
+
+// trackMember adds the author of a command to the state, as the state only has the members of small servers.
+func trackMember(s Session, guildID string, member *discordgo.Member) {
+	member.GuildID = guildID
+	err := s.StateMemberAdd(member)
+	if err != nil {
+		log.Printf("Failed to add member %v to the state: %v", member.User.ID, err)
+	}
+}
+
 func (b *TempChannelBot) parseCommand(context *CommandHandlerContext, prefix string) bool {
 	commandText := strings.TrimPrefix(context.Event.Content, prefix)
 	commandParts := strings.Split(commandText, " ")
@@ -279,37 +614,38 @@ func (b *TempChannelBot) handleCommand(context *CommandHandlerContext, prefix st
 		return false
 	}
 
-	if command.AdminOnly {
-		authorID, err := state.ParseDiscordID(context.Event.Author.ID)
+	if !context.canRunCommand(command) {
+		return false
+	}
+
+	if context.ServerData != nil {
+		authorID, err := state.ParseDiscordID(context.AuthorID)
 		if err != nil {
-			log.Fatalf("Failed to parse author ID: %v", err)
+			b.handleCommandError(context, fmt.Errorf("Bot couldn't parse author ID of a command it just got: %w", err))
 			return false
 		}
 
-		if !(context.hasServerPermission(authorID, discordgo.PermissionAdministrator)) {
-			context.reply(`You must have "Administrator" permissions in order to run this command`)
-			return false
-		}
+		context.ServerData = state.NewAuditedServerData(context.ServerData, b.configChanges, authorID)
 	}
 
 	err := command.Handler(context)
 	if err != nil {
-		log.Fatalf("Command handler %v failed: %v", context.CommandName, err)
+		b.handleCommandError(context, err)
 	}
 
 	return true
 }
 
 func (b *TempChannelBot) handleDM(context *CommandHandlerContext) {
-	valid := b.parseCommand(context, consts.DefaultCommandPrefix)
+	valid := context.Interaction != nil || b.parseCommand(context, consts.DefaultCommandPrefix)
 	if !valid || context.CommandName != "help" {
-		context.replyUnformatted("`The bot doesn't accept any command besides !help in private messages. You may use the following link to invite the bot to your server: `\nhttps://discordapp.com/oauth2/authorize?&client_id=503558207189417984&scope=bot&permissions=3088")
+		context.replyUnformatted("`The bot doesn't accept any command besides !help in private messages. You may use the following link to invite the bot to your server: `\nhttps://discordapp.com/oauth2/authorize?&client_id=503558207189417984&scope=bot%20applications.commands&permissions=3088")
 		return
 	}
 
 	err := helpHandler(context)
 	if err != nil {
-		log.Fatalf("Failed to send help to DM: %v", err)
+		b.handleCommandError(context, err)
 	}
 }
 
@@ -321,7 +657,8 @@ TempChat is a bot that creates temporary text channels for Discord voice chats.
 The bot will give permission to any new user that joins the voice chat, and revoke the permission to any user that leaves it.
 All channels are created under a specific category, the category must give the bot account the [Manage Channel] permission, and must deny the [Read Text Channels & See Voice Channels] from @everyone
 
-As of now, the bot requires [Developer Mode] to be active in order to use the setup/configuration commands.
+As of now, the bot requires [Developer Mode] to be active in order to use the setup/configuration prefix commands.
+All commands are also available as slash commands (e.g. /mkch), which let you pick channels instead of pasting their IDs.
 
 #Commands:
 !help - Displays this menu
@@ -337,7 +674,67 @@ As of now, the bot requires [Developer Mode] to be active in order to use the se
 !set-mkch [new-name] - Changes the !mkch command to the desired command name
 !set-mkch - Resets the command name to !mkch
 !set-command-ch [channel-name] - Sets a specific channel for the bot to read commands from, the bot will ignore all other channels.
-!set-command-ch - Removes the specified command channel` + "```")
+!set-command-ch - Removes the specified command channel
+!set-orphan-policy [delete|adopt|repermission] - Sets what the bot does on startup with channels in the temp category it didn't create (repermission by default)
+!set-orphan-policy - Resets the orphan channel policy to repermission
+!set-auto-create [on|off] - Turns on/off creating temp channels automatically when users join a voice chat
+!set-auto-create-ch [voice-channel-id...] - Limits the automatic creation to specific voice channels
+!set-auto-create-ch - Makes the automatic creation apply to all voice channels
+!set-channel-name [template] - Sets the temp channel name template, may use {voice}, {owner}, {date}, {n} and {silly}
+!set-channel-name - Resets the temp channel names to random silly names
+!set-archive [off|text|json|html] - Archives the messages of temp channels in the given format before they are deleted
+!set-archive-ch [channel-id] - Posts the archived transcripts to a specific channel
+!set-archive-ch - Keeps the archived transcripts in the bot's database
+!set-grace-period [period] - Keeps empty temp channels for the given period (e.g. 30s, 5m) in case someone rejoins
+!set-grace-period - Deletes empty temp channels immediately
+!set-history [hidden|visible] - Hides/shows the messages sent before a member joined the temp channel (hidden by default)
+!set-history recap [minutes] - Hides the messages sent before a member joined, but re-posts the ones of the last minutes for them
+!set-history - Hides the messages sent before members joined again
+!set-max-age [duration] - Expires temp channels once they're open for the given duration (e.g. 12h), their members are warned first
+!set-max-age - Stops temp channels from expiring for their age
+!set-max-idle [duration] - Expires temp channels once no messages were sent in them for the given duration (e.g. 2h)
+!set-max-idle - Stops temp channels from expiring for being idle
+!set-expiry-action [delete|rotate] - Deletes expired temp channels, or replaces them with new ones with the same members (delete by default)
+!set-mkch-cooldown [cooldown] - Makes users wait the given cooldown (e.g. 30s, 5m) between creating temp channels
+!set-mkch-cooldown - Lets users create temp channels without waiting
+!set-max-channels [count] - Limits the number of temp channels the server may have at once
+!set-max-channels - Removes the limit on the number of temp channels
+
+[Categories]
+!route-add [voice-channel|category] [temp-category] - Creates the temp channels of the voice channel, or of the voice channels in the category, in a specific temp category, which must not have other text channels
+!route-remove [voice-channel|category] - Creates the temp channels of the voice channel or category in the default temp category again
+!route-list - Lists the temp categories, a voice channel's own route is preferred over its category's route
+
+[Voice Channels]
+!voice-policy - Lists the voice channels that override the server's settings
+!voice-policy [voice-channel] - Shows the settings of the voice channel
+!voice-policy [voice-channel] mkch [on|off] - Turns on/off creating temp channels for the voice channel, by !mkch or automatically
+!voice-policy [voice-channel] auto-create [force|default] - Creates temp channels automatically for the voice channel even if !set-auto-create doesn't apply to it
+!voice-policy [voice-channel] name [template|default] - Overrides the temp channel name template, resets it to the server's if default or no template is given
+!voice-policy [voice-channel] history [hidden|visible|recap minutes|default] - Overrides how members that join late see the messages sent before they joined
+!voice-policy [voice-channel] reset - Removes all of the voice channel's overrides
+
+[Temp Channel Owner - run inside the temp channel]
+!rename [name] - Renames the temp channel
+!lock - Stops giving users that join the voice chat access to the temp channel
+!unlock - Gives users that join the voice chat access to the temp channel again
+!invite [user] - Gives a user that isn't in the voice chat access to the temp channel
+!kick [user] - Removes a user's access to the temp channel
+!transfer [user] - Hands the control of the temp channel over to another member of it
+
+[Permissions]
+!perm-add [command] [role|user|permission] - Allows a role, a user, or anyone with the permission (e.g. manage_channels, or manage_channels+move_members for both) to run the command
+!perm-remove [command] [role|user|permission] - Removes a rule added by !perm-add, commands without rules are back to their defaults
+!perm-list [command] - Lists who may run each command, administrators may always run every command
+
+[History]
+!history [page] - Lists the recent changes to the bot's settings, who made them and when
+!revert [change] - Sets a setting back to its value before the given change, the revert is listed as a change too
+
+[Backup]
+!export - Sends the bot's settings and permission rules as a file
+!import - Lists the changes the attached settings file would make, channels and categories it uses must exist in the server
+!import apply - Applies the attached settings file, the changes are listed by !history` + "```")
 	return nil
 }
 
@@ -350,26 +747,9 @@ func (b *TempChannelBot) setupHandler(context *CommandHandlerContext) error {
 		return nil
 	}
 
-	categoryIDStr := context.CommandArgs[0]
-	categoryID, err := state.ParseDiscordID(categoryIDStr)
-	if err != nil {
-		context.reply(`Invalid category ID, please right click the category and click "Copy ID"`)
-		log.Printf("Invalid category ID %q: %v", categoryIDStr, err) // TODO: consider log level error
-		return nil
-	}
-
-	if !context.channelExists(categoryIDStr) {
-		context.reply(`This category doesn't exist, please right click the category and click "Copy ID"`)
-		return nil
-	}
-
-	if !context.categoryExists(categoryIDStr) {
-		context.reply(`The given ID isn't of a category, please right click the category and click "Copy ID"`)
-		return nil
-	}
-
-	if !context.hasChannelPermission(categoryID, discordgo.PermissionManageChannels) {
-		context.reply(`The bot doesn't have the "Manage Channels" permission for this category.`)
+	categoryID, problem := context.checkTempChannelCategory(context.CommandArgs[0])
+	if problem != "" {
+		context.reply("%v", problem)
 		return nil
 	}
 
@@ -383,55 +763,99 @@ func (b *TempChannelBot) setupHandler(context *CommandHandlerContext) error {
 		err := context.ServerData.SetTempChannelCategoryID(categoryID)
 		if err != nil {
 			context.reply("An internal error has occurred")
-			return fmt.Errorf("SetTempChannelCategoryID failed: %v", err)
+			return fmt.Errorf("SetTempChannelCategoryID failed: %w", err)
 		}
 
 		context.reply("Category ID updated successfully")
 		return nil
 	}
 
-	err = b.store.AddServer(context.ServerID, categoryID)
+	err := b.store.AddServer(context.ServerID, categoryID)
 	if err != nil {
 		context.reply("An internal error has occurred")
-		return fmt.Errorf("AddServer failed: %v", err)
+		return fmt.Errorf("AddServer failed: %w", err)
 	}
 
 	context.logAndReply("Server was setup successfully, you may use %v%v", consts.DefaultCommandPrefix, consts.DefaultMakeChannelCommand)
 	return nil
 }
 
+// checkTempChannelCategory checks that the ID is of a category of the server the bot may create channels in.
+// Returns the parsed ID, or a message explaining why the category can't be used.
+func (c *CommandHandlerContext) checkTempChannelCategory(categoryIDStr string) (state.DiscordID, string) {
+	categoryID, err := state.ParseDiscordID(categoryIDStr)
+	if err != nil {
+		log.Printf("Invalid category ID %q: %v", categoryIDStr, err) // TODO: consider log level error
+		return state.DiscordIDNone, `Invalid category ID, please right click the category and click "Copy ID"`
+	}
+
+	if !c.channelExists(categoryIDStr) {
+		return state.DiscordIDNone, `This category doesn't exist, please right click the category and click "Copy ID"`
+	}
+
+	if !c.categoryExists(categoryIDStr) {
+		return state.DiscordIDNone, `The given ID isn't of a category, please right click the category and click "Copy ID"`
+	}
+
+	if !c.hasChannelPermission(categoryID, discordgo.PermissionManageChannels) {
+		return state.DiscordIDNone, `The bot doesn't have the "Manage Channels" permission for this category.`
+	}
+
+	return categoryID, ""
+}
+
 func (b *TempChannelBot) mkchHandler(context *CommandHandlerContext) error {
-	authorID, err := state.ParseDiscordID(context.Event.Author.ID)
+	authorID, err := state.ParseDiscordID(context.AuthorID)
 	if err != nil {
-		return fmt.Errorf("Bot couldn't parse author ID of a message it just got: %v", err)
+		return fmt.Errorf("Bot couldn't parse author ID of a message it just got: %w", err)
 	}
 
-	if !context.categoryExists(context.ServerData.TempChannelCategoryID().RESTAPIFormat()) {
-		context.reply("The temp channel category doesn't exist, please run %vsetup again", context.ServerData.CommandPrefix())
-		return nil
+	voiceChannelID, err := context.getUserVoiceChannelID(authorID)
+	if err != nil {
+		return err
 	}
 
-	voiceChannelID := context.getUserVoiceChannelID(authorID)
 	if voiceChannelID == state.DiscordIDNone {
 		context.reply("You must be in a voice chat to use this command")
 		return nil
 	}
 
-	tempChannel, alreadyExists := b.tempChannels.GetTempChannelForVoiceChat(voiceChannelID)
-	if alreadyExists {
-		context.replyUnformatted(fmt.Sprintf("`A temp channel already exists for this voice chat` %v", tempChannel.channel.Mention()))
+	config := context.ServerData.Config()
+	if config.VoiceChannelPolicy(voiceChannelID).MkchDisabled {
+		context.reply("Temp channels are disabled for this voice chat")
+		return nil
+	}
+
+	categoryID := tempChannelCategoryID(context.Session, context.ServerData, voiceChannelID)
+	if !context.categoryExists(categoryID.RESTAPIFormat()) {
+		if categoryID != context.ServerData.TempChannelCategoryID() {
+			context.reply("The temp channel category of this voice chat doesn't exist, please run %vroute-add again", context.ServerData.CommandPrefix())
+			return nil
+		}
+
+		context.reply("The temp channel category doesn't exist, please run %vsetup again", context.ServerData.CommandPrefix())
 		return nil
 	}
 
-	participants := context.getVoiceChannelParticipants(voiceChannelID)
-	tempChannel, err = NewTempChannel(context, voiceChannelID, participants)
+	participants, err := voiceChannelParticipants(context.Session, context.GuildID, voiceChannelID)
 	if err != nil {
-		context.reply("Failed to create a temp channel, please make sure the bot has the right permissions")
-		log.Printf("Couldn't create temp channel: %v", err)
+		return fmt.Errorf("Couldn't get the participants of voice channel %v: %w", voiceChannelID, err)
+	}
+
+	tempChannel, created, err := b.createTempChannel(context.Session, context.ServerData, voiceChannelID, authorID, participants)
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		context.reply("%v", limitReply(limitErr, context.ServerData.CommandPrefix()))
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Couldn't create temp channel: %w", err)
+	}
+
+	if !created {
+		context.replyUnformatted(fmt.Sprintf("`A temp channel already exists for this voice chat` %v", tempChannel.channel.Mention()))
 		return nil
 	}
 
-	b.tempChannels.AddTempChannel(tempChannel)
 	context.replyUnformatted(fmt.Sprintf("`The temporary channel was created` %v", tempChannel.channel.Mention()))
 	return nil
 }
@@ -451,7 +875,7 @@ func (b *TempChannelBot) setMkchHandler(context *CommandHandlerContext) error {
 		err := context.ServerData.ResetCustomCommand()
 		if err != nil {
 			context.reply("An internal error has occurred")
-			return fmt.Errorf("ResetCustomCommand failed: %v", err)
+			return fmt.Errorf("ResetCustomCommand failed: %w", err)
 		}
 
 		context.reply("Temp channel command reset successful")
@@ -484,7 +908,7 @@ func (b *TempChannelBot) setMkchHandler(context *CommandHandlerContext) error {
 		err := context.ServerData.SetCustomCommand(newCommand)
 		if err != nil {
 			context.reply("An internal error has occurred")
-			return fmt.Errorf("SetCustomCommand failed: %v", err)
+			return fmt.Errorf("SetCustomCommand failed: %w", err)
 		}
 
 		context.reply("Command name changed successfully")
@@ -507,7 +931,7 @@ func (b *TempChannelBot) setPrefixHandler(context *CommandHandlerContext) error
 		err := context.ServerData.ResetCommandPrefix()
 		if err != nil {
 			context.reply("An internal error has occurred")
-			return fmt.Errorf("ResetCommandPrefix failed: %v", err)
+			return fmt.Errorf("ResetCommandPrefix failed: %w", err)
 		}
 
 		context.reply("Prefix reset successfully")
@@ -531,7 +955,7 @@ func (b *TempChannelBot) setPrefixHandler(context *CommandHandlerContext) error
 		err := context.ServerData.SetCustomCommandPrefix(newPrefix)
 		if err != nil {
 			context.reply("An internal error has occurred")
-			return fmt.Errorf("SetCustomCommandPrefix failed: %v", err)
+			return fmt.Errorf("SetCustomCommandPrefix failed: %w", err)
 		}
 
 		context.reply("Prefix changed successfully")
@@ -554,7 +978,7 @@ func (b *TempChannelBot) setCommandChannelHandler(context *CommandHandlerContext
 		err := context.ServerData.ClearCommandChannelID()
 		if err != nil {
 			context.reply("An internal error has occurred")
-			return fmt.Errorf("ClearCommandChannelID failed: %v", err)
+			return fmt.Errorf("ClearCommandChannelID failed: %w", err)
 		}
 
 		context.reply("Removed specific command channel successfully")
@@ -582,7 +1006,7 @@ func (b *TempChannelBot) setCommandChannelHandler(context *CommandHandlerContext
 		err = context.ServerData.SetCommandChannelID(channelID)
 		if err != nil {
 			context.reply("An internal error has occurred")
-			return fmt.Errorf("SetCommandChannelID failed: %v", err)
+			return fmt.Errorf("SetCommandChannelID failed: %w", err)
 		}
 
 		context.reply("Specific command channel set successfully")
@@ -591,3 +1015,724 @@ func (b *TempChannelBot) setCommandChannelHandler(context *CommandHandlerContext
 
 	return nil
 }
+
+func (b *TempChannelBot) setOrphanPolicyHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) > 1 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	if len(context.CommandArgs) == 0 {
+		if context.ServerData.OrphanChannelPolicy() == consts.DefaultOrphanPolicy {
+			context.reply("The orphan channel policy is already set to %v, please check %vhelp to see how to use the command", consts.DefaultOrphanPolicy, context.ServerData.CommandPrefix())
+			return nil
+		}
+
+		err := context.ServerData.ResetOrphanChannelPolicy()
+		if err != nil {
+			context.reply("An internal error has occurred")
+			return fmt.Errorf("ResetOrphanChannelPolicy failed: %w", err)
+		}
+
+		context.reply("Orphan channel policy reset successfully")
+		return nil
+	}
+
+	newPolicy := strings.ToLower(context.CommandArgs[0])
+	if context.ServerData.OrphanChannelPolicy() == newPolicy {
+		context.reply("Orphan channel policy is already %v", newPolicy)
+		return nil
+	}
+
+	if !isValidOrphanPolicy(newPolicy) {
+		context.reply("Invalid orphan channel policy, please use one of the following: %v", strings.Join(consts.ValidOrphanPolicies, ", "))
+		return nil
+	}
+
+	err := context.ServerData.SetOrphanChannelPolicy(newPolicy)
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("SetOrphanChannelPolicy failed: %w", err)
+	}
+
+	context.reply("Orphan channel policy changed successfully")
+	return nil
+}
+
+func isValidOrphanPolicy(policy string) bool {
+	for _, validPolicy := range consts.ValidOrphanPolicies {
+		if policy == validPolicy {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *TempChannelBot) setAutoCreateHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) != 1 {
+		context.reply("Expected on or off, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	var autoCreate bool
+	switch strings.ToLower(context.CommandArgs[0]) {
+	case "on":
+		autoCreate = true
+	case "off":
+		autoCreate = false
+	default:
+		context.reply("Expected on or off, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	if context.ServerData.AutoCreate() == autoCreate {
+		context.reply("Automatic temp channel creation is already %v", context.CommandArgs[0])
+		return nil
+	}
+
+	err := context.ServerData.SetAutoCreate(autoCreate)
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("SetAutoCreate failed: %w", err)
+	}
+
+	if autoCreate {
+		context.reply("Temp channels will now be created automatically when users join a voice chat")
+	} else {
+		context.reply("Temp channels will no longer be created automatically")
+	}
+
+	return nil
+}
+
+func (b *TempChannelBot) setAutoCreateChannelsHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) == 0 {
+		if !context.ServerData.HasAutoCreateVoiceChannelIDs() {
+			context.reply("The automatic creation already applies to all voice channels, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+			return nil
+		}
+
+		err := context.ServerData.ClearAutoCreateVoiceChannelIDs()
+		if err != nil {
+			context.reply("An internal error has occurred")
+			return fmt.Errorf("ClearAutoCreateVoiceChannelIDs failed: %w", err)
+		}
+
+		context.reply("The automatic creation now applies to all voice channels")
+		return nil
+	}
+
+	voiceChannelIDs := []state.DiscordID{}
+	for _, channelIDStr := range context.CommandArgs {
+		channelID, err := state.ParseDiscordID(channelIDStr)
+		if err != nil {
+			context.reply(`Invalid channel ID %v, please right click the voice channel and click "Copy ID"`, channelIDStr)
+			log.Printf("Invalid channel ID %q: %v", channelIDStr, err) // TODO: consider log level error
+			return nil
+		}
+
+		if !context.voiceChannelExists(channelIDStr) {
+			context.reply(`The channel %v isn't a voice channel, please right click the voice channel and click "Copy ID"`, channelIDStr)
+			return nil
+		}
+
+		voiceChannelIDs = append(voiceChannelIDs, channelID)
+	}
+
+	err := context.ServerData.SetAutoCreateVoiceChannelIDs(voiceChannelIDs)
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("SetAutoCreateVoiceChannelIDs failed: %w", err)
+	}
+
+	context.reply("The automatic creation now applies to %v voice channels", len(voiceChannelIDs))
+	return nil
+}
+
+func (b *TempChannelBot) setChannelNameHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) == 0 {
+		if !context.ServerData.HasChannelNameTemplate() {
+			context.reply("Temp channels already get random names, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+			return nil
+		}
+
+		err := context.ServerData.ResetChannelNameTemplate()
+		if err != nil {
+			context.reply("An internal error has occurred")
+			return fmt.Errorf("ResetChannelNameTemplate failed: %w", err)
+		}
+
+		context.reply("Channel name template reset successfully")
+		return nil
+	}
+
+	template := strings.Join(context.CommandArgs, " ")
+	if context.ServerData.ChannelNameTemplate() == template {
+		context.reply("Channel name template is already %v", template)
+		return nil
+	}
+
+	err := validateChannelNameTemplate(template)
+	if err != nil {
+		context.reply("%v", err)
+		return nil
+	}
+
+	err = context.ServerData.SetChannelNameTemplate(template)
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("SetChannelNameTemplate failed: %w", err)
+	}
+
+	example := formatChannelName(template, channelNameParams{VoiceChannelName: "General", OwnerName: "Someone", Date: time.Now().UTC(), Number: 1})
+	context.reply("Channel name template changed successfully, channels will be named like %v", example)
+	return nil
+}
+
+func (b *TempChannelBot) setArchiveHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) != 1 {
+		context.reply("Expected off or one of the formats: %v, please check %vhelp to see how to use the command", strings.Join(consts.ValidArchiveFormats, ", "), context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	format := strings.ToLower(context.CommandArgs[0])
+	if format == "off" {
+		if !context.ServerData.ArchiveEnabled() {
+			context.reply("Archiving is already off")
+			return nil
+		}
+
+		err := context.ServerData.DisableArchive()
+		if err != nil {
+			context.reply("An internal error has occurred")
+			return fmt.Errorf("DisableArchive failed: %w", err)
+		}
+
+		context.reply("Archiving turned off successfully")
+		return nil
+	}
+
+	if !isValidArchiveFormat(format) {
+		context.reply("Invalid format, please use off or one of the following: %v", strings.Join(consts.ValidArchiveFormats, ", "))
+		return nil
+	}
+
+	if context.ServerData.ArchiveFormat() == format {
+		context.reply("Transcripts are already archived as %v", format)
+		return nil
+	}
+
+	err := context.ServerData.SetArchiveFormat(format)
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("SetArchiveFormat failed: %w", err)
+	}
+
+	context.reply("Temp channel transcripts will be archived as %v", format)
+	return nil
+}
+
+func isValidArchiveFormat(format string) bool {
+	for _, validFormat := range consts.ValidArchiveFormats {
+		if format == validFormat {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *TempChannelBot) setArchiveChannelHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) > 1 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	if len(context.CommandArgs) == 0 {
+		if !context.ServerData.HasArchiveChannelID() {
+			context.reply("Transcripts are already kept in the database, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+			return nil
+		}
+
+		err := context.ServerData.ClearArchiveChannelID()
+		if err != nil {
+			context.reply("An internal error has occurred")
+			return fmt.Errorf("ClearArchiveChannelID failed: %w", err)
+		}
+
+		context.reply("Transcripts will be kept in the database")
+		return nil
+	}
+
+	channelIDStr := context.CommandArgs[0]
+	channelID, err := state.ParseDiscordID(channelIDStr)
+	if err != nil {
+		context.reply(`Invalid channel ID, please right click the channel and click "Copy ID"`)
+		log.Printf("Invalid channel ID %q: %v", channelIDStr, err) // TODO: consider log level error
+		return nil
+	}
+
+	if !context.textChannelExists(channelIDStr) {
+		context.reply(`The requested channel isn't a text channel, please right click the channel and click "Copy ID"`)
+		return nil
+	}
+
+	if !context.hasChannelPermission(channelID, discordgo.PermissionSendMessages|discordgo.PermissionAttachFiles) {
+		context.reply(`The bot doesn't have the "Send Messages" and "Attach Files" permissions for this channel.`)
+		return nil
+	}
+
+	err = context.ServerData.SetArchiveChannelID(channelID)
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("SetArchiveChannelID failed: %w", err)
+	}
+
+	context.reply("Archive channel set successfully")
+	return nil
+}
+
+func (b *TempChannelBot) setGracePeriodHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) > 1 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	if len(context.CommandArgs) == 0 {
+		if context.ServerData.DeletionGracePeriod() == 0 {
+			context.reply("Empty temp channels are already deleted immediately, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+			return nil
+		}
+
+		err := context.ServerData.ResetDeletionGracePeriod()
+		if err != nil {
+			context.reply("An internal error has occurred")
+			return fmt.Errorf("ResetDeletionGracePeriod failed: %w", err)
+		}
+
+		context.reply("Empty temp channels will be deleted immediately")
+		return nil
+	}
+
+	gracePeriod, err := parseDuration(context.CommandArgs[0])
+	if err != nil || gracePeriod < time.Second {
+		context.reply("Invalid grace period, please use a number of seconds or a duration such as 30s or 5m")
+		return nil
+	}
+
+	if gracePeriod > consts.MaxDeletionGracePeriod {
+		context.reply("The grace period cannot be longer than %v", consts.MaxDeletionGracePeriod)
+		return nil
+	}
+
+	err = context.ServerData.SetDeletionGracePeriod(gracePeriod)
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("SetDeletionGracePeriod failed: %w", err)
+	}
+
+	context.reply("Empty temp channels will be deleted after %v", gracePeriod.Truncate(time.Second))
+	return nil
+}
+
+func (b *TempChannelBot) setHistoryHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) == 0 {
+		if context.ServerData.Config().HistoryVisibility.Mode == consts.DefaultHistoryMode {
+			context.reply("The messages sent before members joined are already hidden, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+			return nil
+		}
+
+		err := context.ServerData.Update(func(config *state.ServerConfig) error {
+			config.HistoryVisibility = state.HistoryVisibility{Mode: consts.DefaultHistoryMode}
+			return nil
+		})
+		if err != nil {
+			context.reply("An internal error has occurred")
+			return fmt.Errorf("Update failed: %w", err)
+		}
+
+		context.reply("The messages sent before members joined will be hidden in new temp channels")
+		return nil
+	}
+
+	visibility, problem := context.parseHistoryArgs(context.CommandArgs)
+	if problem != "" {
+		context.reply("%v", problem)
+		return nil
+	}
+
+	if context.ServerData.Config().HistoryVisibility == visibility {
+		context.reply("The history mode is already %v", describeHistoryVisibility(visibility))
+		return nil
+	}
+
+	err := context.ServerData.Update(func(config *state.ServerConfig) error {
+		config.HistoryVisibility = visibility
+		return nil
+	})
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("Update failed: %w", err)
+	}
+
+	context.reply("The history mode of new temp channels is now %v", describeHistoryVisibility(visibility))
+	return nil
+}
+
+func (b *TempChannelBot) setMaxAgeHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) > 1 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	if len(context.CommandArgs) == 0 {
+		if context.ServerData.Config().MaxChannelAge == 0 {
+			context.reply("Temp channels already never expire for their age, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+			return nil
+		}
+
+		err := context.ServerData.Update(func(config *state.ServerConfig) error {
+			config.MaxChannelAge = 0
+			return nil
+		})
+		if err != nil {
+			context.reply("An internal error has occurred")
+			return fmt.Errorf("Update failed: %w", err)
+		}
+
+		context.reply("Temp channels will no longer expire for their age")
+		return nil
+	}
+
+	maxAge, problem := parseChannelExpiry(context.CommandArgs[0], "maximum age")
+	if problem != "" {
+		context.reply("%v", problem)
+		return nil
+	}
+
+	err := context.ServerData.Update(func(config *state.ServerConfig) error {
+		config.MaxChannelAge = maxAge
+		return nil
+	})
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("Update failed: %w", err)
+	}
+
+	context.reply("Temp channels will expire %v after they're created", maxAge)
+	return nil
+}
+
+func (b *TempChannelBot) setMaxIdleHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) > 1 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	if len(context.CommandArgs) == 0 {
+		if context.ServerData.Config().MaxIdleTime == 0 {
+			context.reply("Temp channels already never expire for being idle, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+			return nil
+		}
+
+		err := context.ServerData.Update(func(config *state.ServerConfig) error {
+			config.MaxIdleTime = 0
+			return nil
+		})
+		if err != nil {
+			context.reply("An internal error has occurred")
+			return fmt.Errorf("Update failed: %w", err)
+		}
+
+		context.reply("Temp channels will no longer expire for being idle")
+		return nil
+	}
+
+	maxIdleTime, problem := parseChannelExpiry(context.CommandArgs[0], "maximum idle time")
+	if problem != "" {
+		context.reply("%v", problem)
+		return nil
+	}
+
+	err := context.ServerData.Update(func(config *state.ServerConfig) error {
+		config.MaxIdleTime = maxIdleTime
+		return nil
+	})
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("Update failed: %w", err)
+	}
+
+	context.reply("Temp channels will expire after %v without messages", maxIdleTime)
+	return nil
+}
+
+func (b *TempChannelBot) setExpiryActionHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) > 1 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	action := consts.DefaultExpiryAction
+	if len(context.CommandArgs) == 1 {
+		action = strings.ToLower(context.CommandArgs[0])
+	}
+
+	if !isValidExpiryAction(action) {
+		context.reply("Invalid expiry action, please use one of the following: %v", strings.Join(consts.ValidExpiryActions, ", "))
+		return nil
+	}
+
+	if context.ServerData.Config().ExpiryAction == action {
+		context.reply("The expiry action is already %v", action)
+		return nil
+	}
+
+	err := context.ServerData.Update(func(config *state.ServerConfig) error {
+		config.ExpiryAction = action
+		return nil
+	})
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("Update failed: %w", err)
+	}
+
+	if action == consts.ExpiryActionRotate {
+		context.reply("Expired temp channels will be replaced with new ones with the same members, the old ones are archived if %vset-archive is on", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	context.reply("Expired temp channels will be deleted")
+	return nil
+}
+
+func (b *TempChannelBot) setMkchCooldownHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) > 1 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	if len(context.CommandArgs) == 0 {
+		if context.ServerData.Config().MkchCooldown == 0 {
+			context.reply("Users already create temp channels without waiting, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+			return nil
+		}
+
+		err := context.ServerData.Update(func(config *state.ServerConfig) error {
+			config.MkchCooldown = 0
+			return nil
+		})
+		if err != nil {
+			context.reply("An internal error has occurred")
+			return fmt.Errorf("Update failed: %w", err)
+		}
+
+		context.reply("Users will create temp channels without waiting")
+		return nil
+	}
+
+	cooldown, problem := parseMkchCooldown(context.CommandArgs[0])
+	if problem != "" {
+		context.reply("%v", problem)
+		return nil
+	}
+
+	err := context.ServerData.Update(func(config *state.ServerConfig) error {
+		config.MkchCooldown = cooldown
+		return nil
+	})
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("Update failed: %w", err)
+	}
+
+	context.reply("Users will wait %v between creating temp channels", cooldown)
+	return nil
+}
+
+func (b *TempChannelBot) setMaxChannelsHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) > 1 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	if len(context.CommandArgs) == 0 {
+		if context.ServerData.Config().MaxTempChannels == 0 {
+			context.reply("The number of temp channels is already unlimited, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+			return nil
+		}
+
+		err := context.ServerData.Update(func(config *state.ServerConfig) error {
+			config.MaxTempChannels = 0
+			return nil
+		})
+		if err != nil {
+			context.reply("An internal error has occurred")
+			return fmt.Errorf("Update failed: %w", err)
+		}
+
+		context.reply("The number of temp channels is no longer limited")
+		return nil
+	}
+
+	maxChannels, problem := parseMaxTempChannels(context.CommandArgs[0])
+	if problem != "" {
+		context.reply("%v", problem)
+		return nil
+	}
+
+	err := context.ServerData.Update(func(config *state.ServerConfig) error {
+		config.MaxTempChannels = maxChannels
+		return nil
+	})
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("Update failed: %w", err)
+	}
+
+	channelCount := b.tempChannels.ServerChannelCount(context.ServerID)
+	if channelCount > maxChannels {
+		context.reply("The server may have up to %v temp channels at once, its %v existing temp channels are kept", maxChannels, channelCount)
+		return nil
+	}
+
+	context.reply("The server may have up to %v temp channels at once", maxChannels)
+	return nil
+}
+
+// parseDuration parses either a whole number of seconds, or a Go duration string.
+func parseDuration(value string) (time.Duration, error) {
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return time.ParseDuration(value)
+}
+
+func (b *TempChannelBot) parsePermissionRuleArgs(context *CommandHandlerContext) (state.CommandPermissionRule, bool) {
+	if len(context.CommandArgs) < 2 {
+		context.reply("Missing command or role/user/permission, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return state.CommandPermissionRule{}, false
+	} else if len(context.CommandArgs) > 2 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return state.CommandPermissionRule{}, false
+	}
+
+	commandName := context.CommandArgs[0]
+	if _, found := b.commands[commandName]; !found {
+		context.reply("Unknown command %v", commandName)
+		return state.CommandPermissionRule{}, false
+	}
+
+	rule, valid := context.parseRuleTarget(commandName, context.CommandArgs[1])
+	if !valid {
+		context.reply("Invalid role/user/permission, valid permissions are: %v", strings.Join(sortedPermissionNames(), ", "))
+		return state.CommandPermissionRule{}, false
+	}
+
+	return rule, true
+}
+
+func hasCommandPermissionRule(serverData state.ServerData, rule state.CommandPermissionRule) bool {
+	return containsRule(serverData.CommandPermissionRules(), rule)
+}
+
+func (b *TempChannelBot) permAddHandler(context *CommandHandlerContext) error {
+	rule, valid := b.parsePermissionRuleArgs(context)
+	if !valid {
+		return nil
+	}
+
+	if hasCommandPermissionRule(context.ServerData, rule) {
+		context.reply("The %v may already run %v", context.describeRule(rule), rule.Command)
+		return nil
+	}
+
+	err := context.ServerData.AddCommandPermissionRule(rule)
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("AddCommandPermissionRule failed: %w", err)
+	}
+
+	context.reply("The %v may now run %v", context.describeRule(rule), rule.Command)
+	return nil
+}
+
+func (b *TempChannelBot) permRemoveHandler(context *CommandHandlerContext) error {
+	rule, valid := b.parsePermissionRuleArgs(context)
+	if !valid {
+		return nil
+	}
+
+	if !hasCommandPermissionRule(context.ServerData, rule) {
+		context.reply("There's no such rule for %v, please check %vperm-list", rule.Command, context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	err := context.ServerData.RemoveCommandPermissionRule(rule)
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("RemoveCommandPermissionRule failed: %w", err)
+	}
+
+	context.reply("Removed the rule allowing the %v to run %v", context.describeRule(rule), rule.Command)
+	return nil
+}
+
+func (b *TempChannelBot) permListHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) > 1 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	commandNames := make([]string, 0, len(b.commands))
+	if len(context.CommandArgs) == 1 {
+		if _, found := b.commands[context.CommandArgs[0]]; !found {
+			context.reply("Unknown command %v", context.CommandArgs[0])
+			return nil
+		}
+
+		commandNames = append(commandNames, context.CommandArgs[0])
+	} else {
+		for name := range b.commands {
+			commandNames = append(commandNames, name)
+		}
+		sort.Strings(commandNames)
+	}
+
+	lines := []string{}
+	for _, name := range commandNames {
+		rules := commandRules(context.ServerData, name)
+		if len(rules) == 0 {
+			if len(commandNames) == 1 {
+				lines = append(lines, fmt.Sprintf("%v: %v", name, defaultCommandPermission(b.commands[name])))
+			}
+			continue
+		}
+
+		descriptions := make([]string, 0, len(rules))
+		for _, rule := range rules {
+			descriptions = append(descriptions, context.describeRule(rule))
+		}
+
+		lines = append(lines, fmt.Sprintf("%v: %v", name, strings.Join(descriptions, ", ")))
+	}
+
+	if len(lines) == 0 {
+		context.reply("No command has permission rules, admin commands require \"Administrator\" permissions and the rest are open to everyone")
+		return nil
+	}
+
+	context.reply("%v", strings.Join(lines, "\n"))
+	return nil
+}
+
+func defaultCommandPermission(command *Command) string {
+	if command.AdminOnly {
+		return "administrators only (default)"
+	}
+
+	return "everyone (default)"
+}
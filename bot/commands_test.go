@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+func (s *BotTestSuite) TestSetup() {
+	reply := s.runCommand(testOwnerID, "!setup "+s.category.ID)
+	s.Contains(reply, "Server was setup successfully")
+
+	serverData, found := s.store.Server(s.serverID)
+	s.Require().True(found, "The server wasn't added to the store")
+	s.True(serverData.TempChannelCategoryID().Equals(s.category.ID))
+}
+
+func (s *BotTestSuite) TestSetupChangesCategory() {
+	serverData := s.setupServer()
+	newCategory := s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildCategory, "new-temp", "",
+		&discordgo.PermissionOverwrite{ID: testBotUserID, Type: discordgo.PermissionOverwriteTypeMember, Allow: discordgo.PermissionManageChannels},
+	)
+
+	reply := s.runCommand(testOwnerID, "!setup "+newCategory.ID)
+	s.Contains(reply, "Category ID updated successfully")
+	s.True(serverData.TempChannelCategoryID().Equals(newCategory.ID))
+}
+
+func (s *BotTestSuite) TestSetupRequiresAdministrator() {
+	reply := s.runCommand(testUser1ID, "!setup "+s.category.ID)
+	s.Contains(reply, `You must have "Administrator" permissions in order to run this command`)
+
+	_, found := s.store.Server(s.serverID)
+	s.False(found, "A non-admin managed to set up the server")
+}
+
+func (s *BotTestSuite) TestSetupAllowsAdministratorRole() {
+	adminRole := s.session.AddRole(s.guild.ID, "admins", discordgo.PermissionAdministrator)
+	s.session.AddMember(s.guild.ID, "300", adminRole.ID)
+
+	reply := s.runCommand("300", "!setup "+s.category.ID)
+	s.Contains(reply, "Server was setup successfully")
+}
+
+func (s *BotTestSuite) TestSetupInvalidCategory() {
+	s.Contains(s.runCommand(testOwnerID, "!setup"), "Missing category ID")
+	s.Contains(s.runCommand(testOwnerID, "!setup not-an-id"), "Invalid category ID")
+	s.Contains(s.runCommand(testOwnerID, "!setup 99999"), "This category doesn't exist")
+	s.Contains(s.runCommand(testOwnerID, "!setup "+s.textChannel.ID), "The given ID isn't of a category")
+}
+
+func (s *BotTestSuite) TestSetupRequiresManageChannels() {
+	category := s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildCategory, "no-access", "",
+		&discordgo.PermissionOverwrite{ID: testBotUserID, Type: discordgo.PermissionOverwriteTypeMember, Deny: discordgo.PermissionManageChannels},
+	)
+
+	reply := s.runCommand(testOwnerID, "!setup "+category.ID)
+	s.Contains(reply, `The bot doesn't have the "Manage Channels" permission for this category.`)
+}
+
+func (s *BotTestSuite) TestMkchBeforeSetup() {
+	reply := s.runCommand(testUser1ID, "!mkch")
+	s.Contains(reply, "The bot hasn't been set up yet")
+}
+
+func (s *BotTestSuite) TestMkchOutsideVoiceChat() {
+	s.setupServer()
+
+	reply := s.runCommand(testUser1ID, "!mkch")
+	s.Contains(reply, "You must be in a voice chat to use this command")
+	s.Empty(s.tempChannels())
+}
+
+func (s *BotTestSuite) TestMkch() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	s.joinVoice(testUser3ID, s.voiceChannel2)
+
+	reply := s.runCommand(testUser1ID, "!mkch")
+	s.Contains(reply, "The temporary channel was created")
+
+	tempChannel := s.requireTempChannel()
+	s.Contains(reply, tempChannel.Mention())
+	s.True(s.canView(tempChannel, testUser1ID), "The author can't view the temp channel")
+	s.True(s.canView(tempChannel, testUser2ID), "A participant can't view the temp channel")
+	s.False(s.canView(tempChannel, testUser3ID), "A user from another voice chat can view the temp channel")
+	s.True(s.canView(tempChannel, testBotUserID), "The bot can't view the temp channel")
+
+	s.Equal(testUser1ID, s.bot.tempChannels.tempChannelIDToTempChannel[s.parseID(tempChannel.ID)].ownerID.RESTAPIFormat())
+	s.Len(s.savedTempChannels(), 1, "The temp channel wasn't saved")
+}
+
+func (s *BotTestSuite) TestMkchTwice() {
+	s.setupServer()
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+
+	s.runCommand(testUser1ID, "!mkch")
+	reply := s.runCommand(testUser2ID, "!mkch")
+	s.Contains(reply, "A temp channel already exists for this voice chat")
+	s.requireTempChannel()
+}
+
+func (s *BotTestSuite) TestMkchMissingCategory() {
+	serverData := s.setupServer()
+	s.Require().NoError(serverData.SetTempChannelCategoryID(s.parseID(s.textChannel.ID)))
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+
+	reply := s.runCommand(testUser1ID, "!mkch")
+	s.Contains(reply, "The temp channel category doesn't exist")
+}
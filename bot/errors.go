@@ -0,0 +1,159 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+// ErrorKind tells how the bot should react to a failure.
+type ErrorKind int
+
+const (
+	// ErrorInternal is an unexpected failure, such as a bug or a database error.
+	ErrorInternal ErrorKind = iota
+	// ErrorTransient is a failure that may succeed if retried later, such as a Discord outage or a rate limit.
+	ErrorTransient
+	// ErrorPermission is a failure caused by the bot missing permissions in a server, only the server's admins can fix it.
+	ErrorPermission
+	// ErrorNotFound is a failure caused by a channel, role or member that no longer exists.
+	ErrorNotFound
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorTransient:
+		return "transient"
+	case ErrorPermission:
+		return "permission"
+	case ErrorNotFound:
+		return "not found"
+	default:
+		return "internal"
+	}
+}
+
+// KindError is an error whose kind is known where it happened, rather than detected from the underlying error.
+type KindError struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *KindError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *KindError) Unwrap() error {
+	return e.Err
+}
+
+// newKindError creates an error of the given kind, the format may wrap other errors with %w.
+func newKindError(kind ErrorKind, format string, args ...interface{}) error {
+	return &KindError{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// errorKindOf classifies an error by the first known error in its chain.
+// Discord REST errors are classified by their status code, network errors are transient.
+func errorKindOf(err error) ErrorKind {
+	var kindErr *KindError
+	if errors.As(err, &kindErr) {
+		return kindErr.Kind
+	}
+
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Response != nil {
+		switch {
+		case restErr.Response.StatusCode == http.StatusForbidden:
+			return ErrorPermission
+		case restErr.Response.StatusCode == http.StatusNotFound:
+			return ErrorNotFound
+		case restErr.Response.StatusCode == http.StatusTooManyRequests || restErr.Response.StatusCode >= http.StatusInternalServerError:
+			return ErrorTransient
+		}
+	}
+
+	var rateLimitErr *discordgo.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return ErrorTransient
+	}
+
+	if errors.Is(err, discordgo.ErrStateNotFound) {
+		return ErrorNotFound
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorTransient
+	}
+
+	return ErrorInternal
+}
+
+func isNotFound(err error) bool {
+	return errorKindOf(err) == ErrorNotFound
+}
+
+// reportError is the error policy for failures outside of commands.
+// Every error is logged, and permission errors are reported to the server's command channel, as only its admins can fix them.
+// Channels that no longer exist are expected when users delete them, so they're only logged.
+func reportError(session Session, servers state.ServerStore, serverID state.DiscordID, action string, err error) {
+	kind := errorKindOf(err)
+	log.Printf("Failed to %v in server %v (%v): %v", action, serverID, kind, err)
+
+	if kind != ErrorPermission {
+		return
+	}
+
+	serverData, found := servers.Server(serverID)
+	if !found || !serverData.HasCommandChannelID() {
+		return
+	}
+
+	message := backtickReplyFormatter.Format(fmt.Sprintf("The bot doesn't have the permissions to %v, please check the permissions of the bot and the temp channel category", action))
+	_, err = session.ChannelMessageSend(serverData.CommandChannelID().RESTAPIFormat(), message)
+	if err != nil {
+		log.Printf("Failed to report a permission error to server %v: %v", serverID, err)
+	}
+}
+
+// handleError applies the error policy to a failure that happened while handling an event of a server.
+func (b *TempChannelBot) handleError(serverID state.DiscordID, action string, err error) {
+	reportError(b.session, b.store, serverID, action, err)
+}
+
+// handleCommandError applies the error policy to a failed command.
+// The error is logged, and the user is told what went wrong, unless the handler already replied.
+func (b *TempChannelBot) handleCommandError(context *CommandHandlerContext, err error) {
+	kind := errorKindOf(err)
+	log.Printf("Command handler %v failed (%v): %v", context.CommandName, kind, err)
+
+	if context.replied {
+		return
+	}
+
+	switch kind {
+	case ErrorTransient:
+		context.reply("Discord is having trouble right now, please try again later")
+	case ErrorPermission:
+		context.reply("The bot doesn't have the permissions to do this, please contact a server admin")
+	case ErrorNotFound:
+		context.reply("A channel or member used by this command no longer exists")
+	default:
+		context.reply("An internal error has occurred")
+	}
+}
+
+// recoverEvent stops a panic while handling a single event from bringing the bot down for every server.
+// Must be deferred directly by the event handler.
+func recoverEvent(event string) {
+	if r := recover(); r != nil {
+		log.Printf("Recovered from a panic while handling %v: %v\n%s", event, r, debug.Stack())
+	}
+}
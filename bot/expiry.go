@@ -0,0 +1,316 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+// expiryReason is why a temp channel expires.
+type expiryReason int
+
+const (
+	expiryReasonAge expiryReason = iota
+	expiryReasonIdle
+)
+
+// RecordActivity marks a message sent to a temp channel, postponing its expiry for being idle.
+// Messages sent to other channels are ignored.
+func (l *TempChannelList) RecordActivity(channelID state.DiscordID, sentAt time.Time) {
+	l.Lock()
+	defer l.Unlock()
+
+	tempChannel, found := l.tempChannelIDToTempChannel[channelID]
+	if found && sentAt.After(tempChannel.lastActivity) {
+		tempChannel.lastActivity = sentAt
+	}
+}
+
+// StartExpiry expires the temp channels that reached their server's maximum age or idle time, now and then every interval.
+// The members are warned consts.ChannelExpiryWarning before a channel expires.
+// Expired channels are deleted, or rotated into the channels returned by recreate, according to the server's expiry action.
+// The expiry runs in the background until the returned function is called.
+func (l *TempChannelList) StartExpiry(interval time.Duration, recreate func(tempChannel *TempChannel) (*TempChannel, error)) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			l.ExpireChannels(time.Now(), recreate)
+
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// expiryWarning is a warning to the members of a temp channel that expires soon.
+type expiryWarning struct {
+	tempChannel *TempChannel
+	config      state.ServerConfig
+	reason      expiryReason
+}
+
+// rotation is an expired temp channel being replaced, with a copy of it to create the new channel from.
+type rotation struct {
+	tempChannel *TempChannel
+	snapshot    *TempChannel
+}
+
+// ExpireChannels warns the members of the temp channels that expire soon, and expires the ones that were warned long enough ago.
+// Empty channels are left to their server's grace period.
+// The list is only held to find the channels, the warnings, deletions and rotations are made without holding it.
+func (l *TempChannelList) ExpireChannels(now time.Time, recreate func(tempChannel *TempChannel) (*TempChannel, error)) {
+	defer recoverEvent("channel expiry")
+
+	warnings, deleted, rotations := l.collectExpiredChannels(now)
+
+	for _, warning := range warnings {
+		l.warnExpiry(warning.tempChannel, warning.config, warning.reason)
+	}
+
+	for _, tempChannel := range deleted {
+		log.Printf("Temp channel %v expired, deleting it", tempChannel.channelID)
+		l.deleteUntrackedChannel(tempChannel)
+	}
+
+	for _, rotation := range rotations {
+		l.rotateTempChannel(rotation, recreate, now)
+	}
+}
+
+// collectExpiredChannels finds the temp channels whose members have to be warned, and the ones that expired.
+// Expired channels that are deleted are already removed from the list.
+func (l *TempChannelList) collectExpiredChannels(now time.Time) ([]expiryWarning, []*TempChannel, []rotation) {
+	l.Lock()
+	defer l.Unlock()
+
+	warnings := []expiryWarning{}
+	deleted := []*TempChannel{}
+	rotations := []rotation{}
+	for _, tempChannel := range l.tempChannelIDToTempChannel {
+		if _, pendingDeletion := l.pendingDeletions[tempChannel.channelID]; pendingDeletion || tempChannel.rotating || len(tempChannel.members) == 0 {
+			continue
+		}
+
+		serverData, found := l.servers.Server(tempChannel.serverID)
+		if !found {
+			continue
+		}
+
+		config := serverData.Config()
+		expiresAt, reason, expires := tempChannel.expiresAt(config.MaxChannelAge, config.MaxIdleTime)
+		if !expires || now.Before(expiresAt.Add(-consts.ChannelExpiryWarning)) {
+			// A message may have postponed an expiry the members were already warned about
+			tempChannel.expiryWarnedAt = time.Time{}
+			continue
+		}
+
+		if tempChannel.expiryWarnedAt.IsZero() {
+			tempChannel.expiryWarnedAt = now
+			warnings = append(warnings, expiryWarning{tempChannel: tempChannel, config: config, reason: reason})
+			continue
+		}
+
+		if now.Before(tempChannel.expiryWarnedAt.Add(consts.ChannelExpiryWarning)) {
+			continue
+		}
+
+		if config.ExpiryAction == consts.ExpiryActionRotate {
+			tempChannel.rotating = true
+			rotations = append(rotations, rotation{tempChannel: tempChannel, snapshot: tempChannel.snapshot()})
+		} else {
+			l.untrackTempChannelNoLock(tempChannel)
+			deleted = append(deleted, tempChannel)
+		}
+	}
+
+	return warnings, deleted, rotations
+}
+
+// expiresAt returns when the channel expires according to the maximum age and idle time, 0 turning each of them off.
+// Returns whether the channel expires at all.
+func (c *TempChannel) expiresAt(maxAge time.Duration, maxIdleTime time.Duration) (time.Time, expiryReason, bool) {
+	expiresAt := time.Time{}
+	reason := expiryReasonAge
+	if maxAge > 0 {
+		expiresAt = c.createdAt.Add(maxAge)
+	}
+
+	if maxIdleTime > 0 {
+		idleExpiresAt := c.lastActivity.Add(maxIdleTime)
+		if expiresAt.IsZero() || idleExpiresAt.Before(expiresAt) {
+			expiresAt = idleExpiresAt
+			reason = expiryReasonIdle
+		}
+	}
+
+	return expiresAt, reason, !expiresAt.IsZero()
+}
+
+func (l *TempChannelList) warnExpiry(tempChannel *TempChannel, config state.ServerConfig, reason expiryReason) {
+	action := "deleted"
+	if config.ExpiryAction == consts.ExpiryActionRotate {
+		action = "replaced with a new channel"
+	}
+
+	warning := fmt.Sprintf("This channel will be %v in %v, as temp channels are kept for up to %v", action, consts.ChannelExpiryWarning, config.MaxChannelAge)
+	if reason == expiryReasonIdle {
+		warning = fmt.Sprintf("This channel will be %v in %v unless a message is sent, as no messages were sent for a while", action, consts.ChannelExpiryWarning)
+	}
+
+	_, err := l.session.ChannelMessageSend(tempChannel.channelID.RESTAPIFormat(), backtickReplyFormatter.Format(warning))
+	if err != nil {
+		log.Printf("Failed to warn temp channel %v it expires: %v", tempChannel.channelID, err)
+	}
+}
+
+// rotateTempChannel replaces an expired temp channel with the one returned by recreate, which is called without holding the list.
+// The old channel is deleted like any other temp channel, archiving its transcript if the server archives transcripts.
+// If the new channel can't be created, the old one is kept and the rotation is retried after another warning period.
+func (l *TempChannelList) rotateTempChannel(rotation rotation, recreate func(tempChannel *TempChannel) (*TempChannel, error), now time.Time) {
+	oldChannel := rotation.tempChannel
+	newChannel, err := recreate(rotation.snapshot)
+	if err != nil {
+		reportError(l.session, l.servers, oldChannel.serverID, fmt.Sprintf("replace the expired #%v", rotation.snapshot.channel.Name), err)
+
+		l.Lock()
+		oldChannel.rotating = false
+		oldChannel.expiryWarnedAt = now
+		l.Unlock()
+		return
+	}
+
+	replaced := l.replaceTempChannel(oldChannel, newChannel)
+	if !replaced {
+		log.Printf("Temp channel %v was deleted while it was replaced, deleting its replacement %v", oldChannel.channelID, newChannel.channelID)
+		l.deleteChannel(newChannel)
+		return
+	}
+
+	log.Printf("Temp channel %v expired, replaced it with %v", oldChannel.channelID, newChannel.channelID)
+	l.deleteUntrackedChannel(oldChannel)
+
+	_, err = l.session.ChannelMessageSend(newChannel.channelID.RESTAPIFormat(), backtickReplyFormatter.Format(fmt.Sprintf("This channel replaces the expired #%v", rotation.snapshot.channel.Name)))
+	if err != nil {
+		log.Printf("Failed to announce temp channel %v: %v", newChannel.channelID, err)
+	}
+}
+
+// replaceTempChannel tracks the new channel instead of the old one, catching up on the changes made to the old one while the new one was created.
+// Returns false if the old channel was deleted in the meantime, in which case the new one isn't tracked.
+func (l *TempChannelList) replaceTempChannel(oldChannel *TempChannel, newChannel *TempChannel) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	oldChannel.rotating = false
+	if l.tempChannelIDToTempChannel[oldChannel.channelID] != oldChannel {
+		return false
+	}
+
+	for userID := range oldChannel.members {
+		if newChannel.members[userID] {
+			continue
+		}
+
+		err := newChannel.AllowUserAccess(userID)
+		if err != nil {
+			log.Printf("Failed to give user %v access to temp channel %v: %v", userID, newChannel.channelID, err)
+		}
+	}
+
+	for userID := range newChannel.members {
+		if oldChannel.members[userID] {
+			continue
+		}
+
+		_, err := newChannel.DenyUserAccess(userID)
+		if err != nil {
+			log.Printf("Failed to remove the access of user %v to temp channel %v: %v", userID, newChannel.channelID, err)
+		}
+	}
+
+	newChannel.ownerID = oldChannel.ownerID
+	newChannel.locked = oldChannel.locked
+	newChannel.invited = copyIDSet(oldChannel.invited)
+	newChannel.kicked = copyIDSet(oldChannel.kicked)
+
+	// The new channel takes the old one's place in the store right away, the old one isn't restored even if it's still being archived
+	l.untrackTempChannelNoLock(oldChannel)
+	l.removeFromStore(oldChannel.channelID)
+	l.addTempChannelNoLock(newChannel)
+
+	err := l.store.AddTempChannel(newChannel.data())
+	if err != nil {
+		log.Printf("Failed to save temp channel %v, it won't survive a restart: %v", newChannel.channelID, err)
+	}
+
+	return true
+}
+
+// parseChannelExpiry parses a maximum age or idle time given to a command.
+// Returns a message explaining the problem if the duration is invalid.
+func parseChannelExpiry(value string, name string) (time.Duration, string) {
+	duration, err := parseDuration(value)
+	if err != nil || duration < consts.MinChannelExpiry {
+		return 0, fmt.Sprintf("Invalid %v, please use a duration of at least %v such as 2h", name, consts.MinChannelExpiry)
+	}
+
+	if duration > consts.MaxChannelExpiry {
+		return 0, fmt.Sprintf("The %v cannot be longer than %v", name, consts.MaxChannelExpiry)
+	}
+
+	return duration.Truncate(time.Second), ""
+}
+
+func isValidExpiryAction(action string) bool {
+	for _, validAction := range consts.ValidExpiryActions {
+		if action == validAction {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StartChannelExpiry expires the temp channels that reached their server's maximum age or idle time, checking them every interval.
+// Expired channels are rotated into new ones with the same name, owner and members if the server chose to.
+// The expiry runs in the background until the returned function is called.
+func (b *TempChannelBot) StartChannelExpiry(interval time.Duration) (stop func()) {
+	return b.tempChannels.StartExpiry(interval, b.recreateTempChannel)
+}
+
+// recreateTempChannel creates a new temp channel replacing an expired one, with the same name, owner, members, invitations, kicks and lock.
+// It's given a copy of the expired channel, as it's called without holding the list.
+func (b *TempChannelBot) recreateTempChannel(tempChannel *TempChannel) (*TempChannel, error) {
+	serverData, found := b.store.Server(tempChannel.serverID)
+	if !found {
+		return nil, fmt.Errorf("Server %v isn't set up", tempChannel.serverID)
+	}
+
+	newChannel, err := NewTempChannel(b.session, serverData, b.botUserID, tempChannel.voiceChannelID, tempChannel.ownerID, tempChannel.channel.Name, tempChannel.memberIDs())
+	if err != nil {
+		return nil, err
+	}
+
+	newChannel.locked = tempChannel.locked
+	newChannel.invited = copyIDSet(tempChannel.invited)
+	newChannel.kicked = copyIDSet(tempChannel.kicked)
+	return newChannel, nil
+}
@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+// expireChannels runs the expiry of temp channels as if the given time passed.
+func (s *BotTestSuite) expireChannels(after time.Duration) {
+	s.bot.tempChannels.ExpireChannels(time.Now().Add(after), s.bot.recreateTempChannel)
+}
+
+// lastMessage returns the content of the last message sent to the channel.
+func (s *BotTestSuite) lastMessage(channel *discordgo.Channel) string {
+	messages := s.session.Messages(channel.ID)
+	s.Require().NotEmpty(messages)
+	return messages[len(messages)-1].Content
+}
+
+func (s *BotTestSuite) TestMaxAgeDeletesChannel() {
+	serverData := s.setupServer()
+	s.updateConfig(serverData, func(config *state.ServerConfig) { config.MaxChannelAge = time.Hour })
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+
+	s.expireChannels(30 * time.Minute)
+	s.Empty(s.session.Messages(tempChannel.ID), "The channel was warned too early")
+
+	s.expireChannels(56 * time.Minute)
+	s.Contains(s.lastMessage(tempChannel), "This channel will be deleted in")
+
+	s.expireChannels(time.Hour)
+	s.requireTempChannel()
+
+	s.expireChannels(62 * time.Minute)
+	s.Empty(s.tempChannels(), "The expired channel wasn't deleted")
+	s.Empty(s.savedTempChannels())
+}
+
+func (s *BotTestSuite) TestMessagePostponesIdleExpiry() {
+	serverData := s.setupServer()
+	s.updateConfig(serverData, func(config *state.ServerConfig) { config.MaxIdleTime = time.Hour })
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+
+	s.expireChannels(56 * time.Minute)
+	s.Contains(s.lastMessage(tempChannel), "unless a message is sent")
+
+	event, err := s.session.SendMessage(tempChannel.ID, testUser1ID, "still here")
+	s.Require().NoError(err)
+	s.bot.MessageCreate(nil, event)
+
+	s.expireChannels(0)
+	s.expireChannels(10 * time.Minute)
+	s.requireTempChannel()
+
+	// The warning is given again before the postponed expiry
+	s.expireChannels(56 * time.Minute)
+	s.Contains(s.lastMessage(tempChannel), "unless a message is sent")
+	s.expireChannels(62 * time.Minute)
+	s.Empty(s.tempChannels())
+}
+
+func (s *BotTestSuite) TestRotateKeepsMembers() {
+	serverData := s.setupServer()
+	s.updateConfig(serverData, func(config *state.ServerConfig) {
+		config.MaxChannelAge = time.Hour
+		config.ExpiryAction = consts.ExpiryActionRotate
+	})
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	oldChannel := s.requireTempChannel()
+
+	s.expireChannels(56 * time.Minute)
+	s.Contains(s.lastMessage(oldChannel), "replaced with a new channel")
+	s.expireChannels(62 * time.Minute)
+
+	newChannel := s.requireTempChannel()
+	s.NotEqual(oldChannel.ID, newChannel.ID)
+	s.Equal(oldChannel.Name, newChannel.Name)
+	s.Contains(s.lastMessage(newChannel), "This channel replaces the expired #"+oldChannel.Name)
+	s.True(s.canView(newChannel, testUser1ID))
+	s.True(s.canView(newChannel, testUser2ID))
+
+	saved := s.savedTempChannels()
+	s.Require().Len(saved, 1)
+	s.Equal(newChannel.ID, saved[0].ChannelID.RESTAPIFormat())
+	tempChannel, found := s.bot.tempChannels.GetTempChannelForUser(s.parseID(testUser2ID))
+	s.Require().True(found)
+	s.Equal(newChannel.ID, tempChannel.channel.ID)
+	s.Equal(s.parseID(testUser1ID), tempChannel.ownerID, "The owner lost the control of the rotated channel")
+
+	// The new channel gets a full maximum age
+	s.expireChannels(62 * time.Minute)
+	s.Equal(newChannel.ID, s.requireTempChannel().ID)
+
+	s.joinVoice(testUser1ID, nil)
+	s.joinVoice(testUser2ID, nil)
+	s.Empty(s.tempChannels(), "The rotated channel wasn't deleted once empty")
+}
+
+// rotateWhile expires the rotating temp channel, running the event while its replacement is created.
+// Fails instead of blocking if the event waits for the rotation.
+func (s *BotTestSuite) rotateWhile(event func()) {
+	recreate := func(tempChannel *TempChannel) (*TempChannel, error) {
+		done := make(chan struct{})
+		go func() {
+			event()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			s.Fail("The temp channels were held while the channel was replaced")
+		}
+
+		return s.bot.recreateTempChannel(tempChannel)
+	}
+
+	s.bot.tempChannels.ExpireChannels(time.Now().Add(62*time.Minute), recreate)
+}
+
+func (s *BotTestSuite) TestRotateCatchesUpWithJoins() {
+	serverData := s.setupServer()
+	s.updateConfig(serverData, func(config *state.ServerConfig) {
+		config.MaxChannelAge = time.Hour
+		config.ExpiryAction = consts.ExpiryActionRotate
+	})
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	s.expireChannels(56 * time.Minute)
+
+	s.rotateWhile(func() { s.joinVoice(testUser2ID, s.voiceChannel1) })
+
+	newChannel := s.requireTempChannel()
+	s.True(s.canView(newChannel, testUser1ID))
+	s.True(s.canView(newChannel, testUser2ID), "A user who joined during the rotation can't see the new channel")
+	tempChannel, found := s.bot.tempChannels.GetTempChannelForUser(s.parseID(testUser2ID))
+	s.Require().True(found)
+	s.Equal(newChannel.ID, tempChannel.channel.ID)
+}
+
+func (s *BotTestSuite) TestRotateOfDeletedChannelIsDiscarded() {
+	serverData := s.setupServer()
+	s.updateConfig(serverData, func(config *state.ServerConfig) {
+		config.MaxChannelAge = time.Hour
+		config.ExpiryAction = consts.ExpiryActionRotate
+	})
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	s.expireChannels(56 * time.Minute)
+
+	s.rotateWhile(func() { s.joinVoice(testUser1ID, nil) })
+
+	s.Empty(s.tempChannels(), "The replacement of a deleted channel was kept")
+	s.Empty(s.savedTempChannels())
+}
+
+func (s *BotTestSuite) TestSetChannelExpiry() {
+	serverData := s.setupServer()
+	s.Contains(s.runCommand(testOwnerID, "!set-max-age"), "already never expire")
+	s.Contains(s.runCommand(testOwnerID, "!set-max-age 5m"), "Invalid maximum age")
+	s.Contains(s.runCommand(testOwnerID, "!set-max-age 1000h"), "The maximum age cannot be longer than")
+	s.Contains(s.runCommand(testOwnerID, "!set-max-age 12h"), "Temp channels will expire 12h0m0s after they're created")
+	s.Equal(12*time.Hour, serverData.Config().MaxChannelAge)
+	s.Contains(s.runCommand(testOwnerID, "!set-max-age"), "no longer expire")
+	s.Zero(serverData.Config().MaxChannelAge)
+
+	s.Contains(s.runCommand(testOwnerID, "!set-max-idle 2h"), "Temp channels will expire after 2h0m0s without messages")
+	s.Equal(2*time.Hour, serverData.Config().MaxIdleTime)
+
+	s.Contains(s.runCommand(testOwnerID, "!set-expiry-action archive"), "Invalid expiry action")
+	s.Contains(s.runCommand(testOwnerID, "!set-expiry-action"), "already delete")
+	s.Contains(s.runCommand(testOwnerID, "!set-expiry-action rotate"), "replaced with new ones")
+	s.Equal("rotate", serverData.Config().ExpiryAction)
+}
@@ -0,0 +1,435 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+const (
+	// exportVersion is the version of the export file format, files of other versions aren't imported.
+	exportVersion = 1
+
+	// importModeApply is the import argument that applies the settings instead of listing the changes.
+	importModeApply = "apply"
+	// importModePreview is the import argument that only lists the changes, the default.
+	importModePreview = "preview"
+)
+
+// serverExport is the JSON file written by the export command and read by the import command.
+// Settings are formatted as in the audit log, settings missing from an imported file are left as they are.
+type serverExport struct {
+	Version         int               `json:"version"`
+	Settings        map[string]string `json:"settings"`
+	PermissionRules []exportedRule    `json:"permission_rules"`
+}
+
+// exportedRule is a command permission rule in an export file.
+type exportedRule struct {
+	Command     string `json:"command"`
+	Type        string `json:"type"`
+	TargetID    string `json:"target_id,omitempty"`
+	Permissions string `json:"permissions,omitempty"`
+}
+
+func (b *TempChannelBot) exportHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) > 0 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	export := serverExport{
+		Version:         exportVersion,
+		Settings:        state.ConfigSettings(context.ServerData),
+		PermissionRules: []exportedRule{},
+	}
+
+	for _, rule := range context.ServerData.CommandPermissionRules() {
+		exported := exportedRule{Command: rule.Command, Type: rule.Type}
+		if rule.Type == consts.PermissionRulePermission {
+			exported.Permissions = formatPermissionNames(rule.Permissions)
+		} else {
+			exported.TargetID = rule.TargetID.RESTAPIFormat()
+		}
+
+		export.PermissionRules = append(export.PermissionRules, exported)
+	}
+
+	content, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("Failed encoding the settings: %w", err)
+	}
+
+	context.replyWithFile(fmt.Sprintf("Use %vimport with this file to copy the settings", context.ServerData.CommandPrefix()), &discordgo.File{
+		Name:        fmt.Sprintf("temp-chat-%v-%v.json", context.ServerID, time.Now().UTC().Format("2006-01-02")),
+		ContentType: "application/json",
+		Reader:      strings.NewReader(string(content)),
+	})
+	return nil
+}
+
+func (b *TempChannelBot) importHandler(context *CommandHandlerContext) error {
+	apply := false
+	for _, arg := range context.CommandArgs {
+		switch {
+		case strings.ToLower(arg) == importModeApply:
+			apply = true
+		case strings.ToLower(arg) == importModePreview:
+		case context.Interaction != nil:
+			// The ID of the attached file option
+		default:
+			context.reply("Invalid argument, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+			return nil
+		}
+	}
+
+	attachments := context.attachments()
+	if len(attachments) != 1 {
+		context.reply("Please attach a single file created by %vexport", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	if attachments[0].Size > consts.MaxAttachmentSize {
+		context.reply("The attached file is too large to be a settings file")
+		return nil
+	}
+
+	content, err := context.Session.AttachmentContent(attachments[0])
+	if err != nil {
+		context.reply("Failed downloading the attached file, please try again")
+		return fmt.Errorf("AttachmentContent failed: %w", err)
+	}
+
+	var export serverExport
+	err = json.Unmarshal(content, &export)
+	if err != nil {
+		context.reply("The attached file isn't a settings file created by %vexport", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	if export.Version != exportVersion {
+		context.reply("The settings file is of version %v, only version %v can be imported", export.Version, exportVersion)
+		return nil
+	}
+
+	settings, problems := b.changedSettings(context, export.Settings)
+	addedRules, removedRules, ruleProblems := b.changedRules(context, export.PermissionRules)
+	problems = append(problems, ruleProblems...)
+	if len(problems) > 0 {
+		context.reply("The settings can't be imported:\n%v", strings.Join(problems, "\n"))
+		return nil
+	}
+
+	lines := []string{}
+	for _, setting := range settings {
+		lines = append(lines, fmt.Sprintf("%v %v -> %v", setting.name, describeSettingValue(setting.oldValue), describeSettingValue(setting.newValue)))
+	}
+	for _, rule := range removedRules {
+		lines = append(lines, fmt.Sprintf("remove the rule allowing the %v to run %v", context.describeRule(rule), rule.Command))
+	}
+	for _, rule := range addedRules {
+		lines = append(lines, fmt.Sprintf("allow the %v to run %v", context.describeRule(rule), rule.Command))
+	}
+
+	if len(lines) == 0 {
+		context.reply("The settings in the file are the same as the current settings")
+		return nil
+	}
+
+	if !apply {
+		context.reply("Importing the file would change:\n%v\nRun %vimport %v with the same file to apply the changes",
+			strings.Join(lines, "\n"), context.ServerData.CommandPrefix(), importModeApply)
+		return nil
+	}
+
+	err = context.ServerData.Update(func(config *state.ServerConfig) error {
+		for _, setting := range settings {
+			err := config.SetSetting(setting.name, setting.newValue)
+			if err != nil {
+				return fmt.Errorf("Failed setting %v: %w", setting.name, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		context.reply("An internal error has occurred, the settings weren't imported")
+		return fmt.Errorf("Update failed: %w", err)
+	}
+
+	for _, rule := range removedRules {
+		err := context.ServerData.RemoveCommandPermissionRule(rule)
+		if err != nil {
+			context.reply("An internal error has occurred, some of the permission rules may have been imported")
+			return fmt.Errorf("RemoveCommandPermissionRule failed: %w", err)
+		}
+	}
+
+	for _, rule := range addedRules {
+		err := context.ServerData.AddCommandPermissionRule(rule)
+		if err != nil {
+			context.reply("An internal error has occurred, some of the permission rules may have been imported")
+			return fmt.Errorf("AddCommandPermissionRule failed: %w", err)
+		}
+	}
+
+	context.reply("Imported the settings:\n%v", strings.Join(lines, "\n"))
+	return nil
+}
+
+// importedSetting is a setting an import changes.
+type importedSetting struct {
+	name     string
+	oldValue string
+	newValue string
+}
+
+// changedSettings returns the imported settings that differ from the current ones, ordered by name,
+// and the reasons the values can't be used if there are any.
+func (b *TempChannelBot) changedSettings(context *CommandHandlerContext, imported map[string]string) ([]importedSetting, []string) {
+	current := state.ConfigSettings(context.ServerData)
+
+	names := make([]string, 0, len(imported))
+	for name := range imported {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	settings := []importedSetting{}
+	problems := []string{}
+	for _, name := range names {
+		if !state.IsConfigSetting(name) {
+			problems = append(problems, fmt.Sprintf("Unknown setting %v", name))
+			continue
+		}
+
+		if imported[name] == current[name] {
+			continue
+		}
+
+		if problem := context.importedSettingProblem(name, imported[name]); problem != "" {
+			problems = append(problems, fmt.Sprintf("%v: %v", name, problem))
+			continue
+		}
+
+		settings = append(settings, importedSetting{name: name, oldValue: current[name], newValue: imported[name]})
+	}
+
+	return settings, problems
+}
+
+// importedSettingProblem checks an imported value the way the command that sets the setting does,
+// returns a message explaining the problem, or an empty string if there's none.
+func (c *CommandHandlerContext) importedSettingProblem(name string, value string) string {
+	switch name {
+	case state.SettingTempChannelCategory:
+		_, problem := c.checkTempChannelCategory(value)
+		return problem
+	case state.SettingCommandPrefix:
+		if len(value) != 1 || !strings.Contains(consts.ValidPrefixes, value) {
+			return fmt.Sprintf("Invalid prefix, please use one of the following: %v", consts.ValidPrefixes)
+		}
+	case state.SettingCommandChannel, state.SettingArchiveChannel:
+		if value != "" && !c.textChannelExists(value) {
+			return "The text channel doesn't exist"
+		}
+	case state.SettingCustomCommand:
+		if value != "" && (len(value) < consts.MinCommandNameLength || len(value) > consts.MaxCommandNameLength ||
+			!consts.ValidCommandLettersRegex.MatchString(value)) {
+			return fmt.Sprintf("Invalid command name, the name can only contain %v", consts.ValidCommandLettersDescription)
+		}
+	case state.SettingOrphanChannelPolicy:
+		if !isValidOrphanPolicy(value) {
+			return fmt.Sprintf("Invalid orphan channel policy, please use one of the following: %v", strings.Join(consts.ValidOrphanPolicies, ", "))
+		}
+	case state.SettingAutoCreate:
+		if value != "true" && value != "false" {
+			return "Expected true or false"
+		}
+	case state.SettingAutoCreateChannels:
+		ids, err := state.ParseDiscordIDs(value)
+		if err != nil {
+			return "Invalid voice channel IDs"
+		}
+
+		for _, id := range ids {
+			if !c.voiceChannelExists(id.RESTAPIFormat()) {
+				return fmt.Sprintf("The voice channel %v doesn't exist", id)
+			}
+		}
+	case state.SettingCategoryRoutes:
+		routes, err := state.ParseCategoryRoutes(value)
+		if err != nil {
+			return "Invalid category routes"
+		}
+
+		for _, route := range routes {
+			source := route.SourceID.RESTAPIFormat()
+			if !c.voiceChannelExists(source) && !c.categoryExists(source) {
+				return fmt.Sprintf("The voice channel or category %v doesn't exist", route.SourceID)
+			}
+
+			if _, problem := c.checkTempChannelCategory(route.CategoryID.RESTAPIFormat()); problem != "" {
+				return problem
+			}
+		}
+	case state.SettingHistoryVisibility:
+		visibility, err := state.ParseHistoryVisibility(value)
+		if err != nil {
+			return err.Error()
+		}
+
+		if visibility.Mode == "" {
+			return fmt.Sprintf("Expected one of the following: %v", strings.Join(consts.ValidHistoryModes, ", "))
+		}
+	case state.SettingVoiceChannelPolicies:
+		policies, err := state.ParseVoiceChannelPolicies(value)
+		if err != nil {
+			return "Invalid voice channel policies"
+		}
+
+		for _, policy := range policies {
+			if !c.voiceChannelExists(policy.VoiceChannelID.RESTAPIFormat()) {
+				return fmt.Sprintf("The voice channel %v doesn't exist", policy.VoiceChannelID)
+			}
+
+			if policy.ChannelNameTemplate != "" {
+				if err := validateChannelNameTemplate(policy.ChannelNameTemplate); err != nil {
+					return err.Error()
+				}
+			}
+		}
+	case state.SettingChannelNameTemplate:
+		if value != "" {
+			if err := validateChannelNameTemplate(value); err != nil {
+				return err.Error()
+			}
+		}
+	case state.SettingArchiveFormat:
+		if value != "" && !isValidArchiveFormat(value) {
+			return fmt.Sprintf("Invalid format, please use one of the following: %v", strings.Join(consts.ValidArchiveFormats, ", "))
+		}
+	case state.SettingMaxChannelAge, state.SettingMaxIdleTime:
+		if value == "" {
+			return ""
+		}
+
+		_, problem := parseChannelExpiry(value, "duration")
+		return problem
+	case state.SettingExpiryAction:
+		if !isValidExpiryAction(value) {
+			return fmt.Sprintf("Expected one of the following: %v", strings.Join(consts.ValidExpiryActions, ", "))
+		}
+	case state.SettingMkchCooldown:
+		if value == "" {
+			return ""
+		}
+
+		_, problem := parseMkchCooldown(value)
+		return problem
+	case state.SettingMaxTempChannels:
+		if value == "" {
+			return ""
+		}
+
+		_, problem := parseMaxTempChannels(value)
+		return problem
+	case state.SettingDeletionGracePeriod:
+		if value == "" {
+			return ""
+		}
+
+		gracePeriod, err := time.ParseDuration(value)
+		if err != nil || gracePeriod < time.Second {
+			return "Invalid grace period, please use a duration such as 30s or 5m"
+		}
+
+		if gracePeriod > consts.MaxDeletionGracePeriod {
+			return fmt.Sprintf("The grace period cannot be longer than %v", consts.MaxDeletionGracePeriod)
+		}
+	}
+
+	return ""
+}
+
+// changedRules returns the permission rules an import adds and removes,
+// and the reasons the imported rules can't be used if there are any.
+func (b *TempChannelBot) changedRules(context *CommandHandlerContext, exported []exportedRule) (added []state.CommandPermissionRule, removed []state.CommandPermissionRule, problems []string) {
+	imported := []state.CommandPermissionRule{}
+	for _, exportedRule := range exported {
+		rule, problem := b.parseExportedRule(context, exportedRule)
+		if problem != "" {
+			problems = append(problems, fmt.Sprintf("permission rule for %v: %v", exportedRule.Command, problem))
+			continue
+		}
+
+		imported = append(imported, rule)
+	}
+
+	current := context.ServerData.CommandPermissionRules()
+	for _, rule := range imported {
+		if !containsRule(current, rule) && !containsRule(added, rule) {
+			added = append(added, rule)
+		}
+	}
+
+	for _, rule := range current {
+		if !containsRule(imported, rule) {
+			removed = append(removed, rule)
+		}
+	}
+
+	return added, removed, problems
+}
+
+func (b *TempChannelBot) parseExportedRule(context *CommandHandlerContext, exported exportedRule) (state.CommandPermissionRule, string) {
+	rule := state.CommandPermissionRule{Command: exported.Command, Type: exported.Type}
+	if _, found := b.commands[exported.Command]; !found {
+		return rule, "Unknown command"
+	}
+
+	if exported.Type == consts.PermissionRulePermission {
+		permissions, valid := parsePermissionNames(exported.Permissions)
+		if !valid {
+			return rule, fmt.Sprintf("Invalid permissions, valid permissions are: %v", strings.Join(sortedPermissionNames(), ", "))
+		}
+
+		rule.Permissions = permissions
+		return rule, ""
+	}
+
+	targetID, err := state.ParseDiscordID(exported.TargetID)
+	if err != nil {
+		return rule, "Invalid role/user ID"
+	}
+	rule.TargetID = targetID
+
+	switch exported.Type {
+	case consts.PermissionRuleRole:
+		if _, err := context.Session.StateRole(context.GuildID, exported.TargetID); err != nil {
+			return rule, fmt.Sprintf("The role %v doesn't exist", targetID)
+		}
+	case consts.PermissionRuleUser:
+	default:
+		return rule, fmt.Sprintf("Invalid rule type %v", exported.Type)
+	}
+
+	return rule, ""
+}
+
+func containsRule(rules []state.CommandPermissionRule, rule state.CommandPermissionRule) bool {
+	for _, existingRule := range rules {
+		if existingRule == rule {
+			return true
+		}
+	}
+
+	return false
+}
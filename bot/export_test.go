@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func settingsFile(content string) *discordgo.File {
+	return &discordgo.File{Name: "settings.json", ContentType: "application/json", Reader: strings.NewReader(content)}
+}
+
+// exportSettings runs the given export command and returns the content of the file it replied with.
+func (s *BotTestSuite) exportSettings(command string) string {
+	s.runCommand(testOwnerID, command)
+
+	messages := s.session.Messages(s.textChannel.ID)
+	reply := messages[len(messages)-1]
+	s.Require().Len(reply.Attachments, 1, reply.Content)
+
+	content, err := s.session.AttachmentContent(reply.Attachments[0])
+	s.Require().NoError(err)
+	return string(content)
+}
+
+func (s *BotTestSuite) TestExportImport() {
+	serverData := s.setupServer()
+	s.runCommand(testOwnerID, "!set-prefix ?")
+	s.runCommand(testOwnerID, "?set-command-ch "+s.textChannel.ID)
+	s.runCommand(testOwnerID, "?perm-add mkch manage_channels")
+	exported := s.exportSettings("?export")
+
+	s.runCommand(testOwnerID, "?set-prefix")
+	s.runCommand(testOwnerID, "!set-command-ch")
+	s.runCommand(testOwnerID, "!perm-remove mkch manage_channels")
+
+	reply := s.runCommandWithFiles(testOwnerID, "!import", settingsFile(exported))
+	s.Contains(reply, "prefix ! -> ?")
+	s.Contains(reply, "command-channel (none) -> "+s.textChannel.ID)
+	s.Contains(reply, "allow the members with manage_channels to run mkch")
+	s.Equal("!", serverData.CommandPrefix(), "The preview changed the settings")
+
+	reply = s.runCommandWithFiles(testOwnerID, "!import apply", settingsFile(exported))
+	s.Contains(reply, "Imported the settings")
+	s.Equal("?", serverData.CommandPrefix())
+	s.Equal(s.parseID(s.textChannel.ID), serverData.CommandChannelID())
+	s.Len(serverData.CommandPermissionRules(), 1)
+
+	reply = s.runCommandWithFiles(testOwnerID, "?import apply", settingsFile(exported))
+	s.Contains(reply, "the same as the current settings")
+}
+
+func (s *BotTestSuite) TestImportMissingChannel() {
+	serverData := s.setupServer()
+	file := `{"version": 1, "settings": {"prefix": "?", "archive-channel": "999999"}, "permission_rules": []}`
+
+	reply := s.runCommandWithFiles(testOwnerID, "!import apply", settingsFile(file))
+	s.Contains(reply, "archive-channel: The text channel doesn't exist")
+	s.Equal("!", serverData.CommandPrefix(), "Some of the settings were imported")
+}
+
+func (s *BotTestSuite) TestImportCategoryWithoutPermission() {
+	s.setupServer()
+	category := s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildCategory, "other", "")
+	file := `{"version": 1, "settings": {"category": "` + category.ID + `"}}`
+
+	reply := s.runCommandWithFiles(testOwnerID, "!import", settingsFile(file))
+	s.Contains(reply, `The bot doesn't have the "Manage Channels" permission for this category.`)
+}
+
+func (s *BotTestSuite) TestImportInvalidFile() {
+	s.setupServer()
+	s.Contains(s.runCommand(testOwnerID, "!import"), "Please attach a single file")
+	s.Contains(s.runCommandWithFiles(testOwnerID, "!import", settingsFile("not json")), "isn't a settings file")
+	s.Contains(s.runCommandWithFiles(testOwnerID, "!import", settingsFile(`{"version": 2}`)), "only version 1 can be imported")
+}
+
+func (s *BotTestSuite) TestImportRequiresAdministrator() {
+	s.setupServer()
+	s.Contains(s.runCommand(testUser1ID, "!export"), `You must have "Administrator" permissions in order to run this command`)
+}
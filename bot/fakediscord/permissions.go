@@ -0,0 +1,94 @@
+package fakediscord
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// memberPermissionsNoLock computes the permissions of a member in a channel the way Discord does:
+// the guild owner and Administrators have every permission, everyone else gets the permissions of their roles,
+// overridden by the channel's @everyone, role and member overwrites, in that order.
+func memberPermissionsNoLock(guild *discordgo.Guild, member *discordgo.Member, channel *discordgo.Channel) int64 {
+	if guild.OwnerID == member.User.ID {
+		return discordgo.PermissionAll
+	}
+
+	memberRoles := map[string]bool{guild.ID: true}
+	for _, roleID := range member.Roles {
+		memberRoles[roleID] = true
+	}
+
+	var permissions int64
+	for _, role := range guild.Roles {
+		if memberRoles[role.ID] {
+			permissions |= role.Permissions
+		}
+	}
+
+	if permissions&discordgo.PermissionAdministrator != 0 {
+		return discordgo.PermissionAll
+	}
+
+	if channel == nil {
+		return permissions
+	}
+
+	for _, overwrite := range channel.PermissionOverwrites {
+		if overwrite.ID == guild.ID {
+			permissions = permissions&^overwrite.Deny | overwrite.Allow
+		}
+	}
+
+	var roleAllow, roleDeny int64
+	for _, overwrite := range channel.PermissionOverwrites {
+		if overwrite.Type == discordgo.PermissionOverwriteTypeRole && overwrite.ID != guild.ID && memberRoles[overwrite.ID] {
+			roleAllow |= overwrite.Allow
+			roleDeny |= overwrite.Deny
+		}
+	}
+	permissions = permissions&^roleDeny | roleAllow
+
+	for _, overwrite := range channel.PermissionOverwrites {
+		if overwrite.Type == discordgo.PermissionOverwriteTypeMember && overwrite.ID == member.User.ID {
+			permissions = permissions&^overwrite.Deny | overwrite.Allow
+		}
+	}
+
+	return permissions
+}
+
+func (s *Session) memberNoLock(guildID string, userID string) *discordgo.Member {
+	guild, found := s.guilds[guildID]
+	if !found {
+		return nil
+	}
+
+	for _, member := range guild.Members {
+		if member.User.ID == userID {
+			return member
+		}
+	}
+
+	return nil
+}
+
+// Permissions returns the permissions of a user in a channel, or in the whole guild if channelID is empty.
+// Users that aren't members of the guild have no permissions.
+func (s *Session) Permissions(guildID string, userID string, channelID string) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.permissionsNoLock(guildID, userID, channelID)
+}
+
+func (s *Session) permissionsNoLock(guildID string, userID string, channelID string) int64 {
+	member := s.memberNoLock(guildID, userID)
+	if member == nil {
+		return 0
+	}
+
+	var channel *discordgo.Channel
+	if channelID != "" {
+		channel = s.channels[channelID]
+	}
+
+	return memberPermissionsNoLock(s.guilds[guildID], member, channel)
+}
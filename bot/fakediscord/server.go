@@ -0,0 +1,678 @@
+package fakediscord
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	gatewayPath              = "/gateway-ws/"
+	gatewayHeartbeatInterval = 41250
+
+	gatewayOpDispatch         = 0
+	gatewayOpHeartbeat        = 1
+	gatewayOpIdentify         = 2
+	gatewayOpVoiceStateUpdate = 4
+	gatewayOpResume           = 6
+	gatewayOpHello            = 10
+	gatewayOpHeartbeatACK     = 11
+
+	gatewayCloseAuthenticationFailed = 4004
+)
+
+// Server serves the subset of Discord's REST API and gateway the bot uses, over its in-memory Session.
+// Point discordgo at it by replacing discordgo's endpoints with ones built from URL.
+//
+// Changes made through the REST API and the gateway are dispatched to every connected member of the guild.
+// Changes made directly through the embedded Session aren't, so the guild should be set up before clients connect.
+type Server struct {
+	*Session
+
+	httpServer *httptest.Server
+	upgrader   websocket.Upgrader
+
+	usersMutex sync.Mutex
+	users      map[string]*discordgo.User
+
+	connectionsMutex sync.Mutex
+	connections      map[*gatewayConnection]bool
+}
+
+type gatewayConnection struct {
+	conn *websocket.Conn
+	user *discordgo.User
+
+	writeMutex sync.Mutex
+	sequence   int64
+}
+
+type gatewayPayload struct {
+	Operation int             `json:"op"`
+	Sequence  int64           `json:"s,omitempty"`
+	Type      string          `json:"t,omitempty"`
+	Data      json.RawMessage `json:"d"`
+}
+
+// NewServer starts a new Server, with a bot user of the given ID that authenticates with botToken.
+func NewServer(botUserID string, botToken string) *Server {
+	s := &Server{
+		Session:     NewSession(botUserID),
+		users:       map[string]*discordgo.User{},
+		connections: map[*gatewayConnection]bool{},
+	}
+	s.users[botToken] = s.BotUser
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v"+discordgo.APIVersion+"/", s.serveREST)
+	mux.HandleFunc(gatewayPath, s.serveGateway)
+	s.httpServer = httptest.NewServer(mux)
+
+	return s
+}
+
+// URL is the base URL of the server, in the form of discordgo.EndpointDiscord.
+func (s *Server) URL() string {
+	return s.httpServer.URL + "/"
+}
+
+// AddUser creates a user that authenticates with the given token.
+// The user still has to be added as a member to the guilds it should be in.
+func (s *Server) AddUser(token string, bot bool) *discordgo.User {
+	userID := s.NewID()
+	user := &discordgo.User{ID: userID, Username: "user-" + userID, Bot: bot}
+
+	s.usersMutex.Lock()
+	defer s.usersMutex.Unlock()
+	s.users[token] = user
+
+	return user
+}
+
+// Close disconnects all gateway connections and stops the server.
+func (s *Server) Close() {
+	s.connectionsMutex.Lock()
+	for connection := range s.connections {
+		connection.conn.Close()
+	}
+	s.connectionsMutex.Unlock()
+
+	s.httpServer.Close()
+}
+
+func (s *Server) authenticate(token string) (*discordgo.User, bool) {
+	s.usersMutex.Lock()
+	defer s.usersMutex.Unlock()
+
+	user, found := s.users[strings.TrimPrefix(token, "Bot ")]
+	return user, found
+}
+
+type restHandler func(user *discordgo.User, r *http.Request, path []string) (interface{}, error)
+
+type restRoute struct {
+	method  string
+	pattern []string
+	handler restHandler
+}
+
+func (s *Server) routes() []restRoute {
+	return []restRoute{
+		{http.MethodGet, []string{"gateway"}, s.getGateway},
+		{http.MethodGet, []string{"gateway", "bot"}, s.getGateway},
+		{http.MethodGet, []string{"users", "@me", "guilds"}, s.getUserGuilds},
+		{http.MethodGet, []string{"users", "*"}, s.getUser},
+		{http.MethodGet, []string{"guilds", "*"}, s.getGuild},
+		{http.MethodGet, []string{"guilds", "*", "channels"}, s.getGuildChannels},
+		{http.MethodPost, []string{"guilds", "*", "channels"}, s.createChannel},
+		{http.MethodGet, []string{"guilds", "*", "members", "*"}, s.getMember},
+		{http.MethodGet, []string{"channels", "*"}, s.getChannel},
+		{http.MethodPatch, []string{"channels", "*"}, s.editChannel},
+		{http.MethodDelete, []string{"channels", "*"}, s.deleteChannel},
+		{http.MethodPut, []string{"channels", "*", "permissions", "*"}, s.setPermission},
+		{http.MethodDelete, []string{"channels", "*", "permissions", "*"}, s.deletePermission},
+		{http.MethodGet, []string{"channels", "*", "messages"}, s.getMessages},
+		{http.MethodPost, []string{"channels", "*", "messages"}, s.sendMessage},
+		{http.MethodPut, []string{"applications", "*", "commands"}, s.overwriteCommands},
+		{http.MethodPut, []string{"applications", "*", "guilds", "*", "commands"}, s.overwriteCommands},
+	}
+}
+
+func (r restRoute) matches(method string, path []string) bool {
+	if r.method != method || len(r.pattern) != len(path) {
+		return false
+	}
+
+	for i, part := range r.pattern {
+		if part != "*" && part != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *Server) serveREST(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v"+discordgo.APIVersion), "/"), "/")
+
+	user, authenticated := s.authenticate(r.Header.Get("Authorization"))
+	if !authenticated {
+		writeRESTError(w, http.StatusUnauthorized, "401: Unauthorized")
+		return
+	}
+
+	for _, route := range s.routes() {
+		if !route.matches(r.Method, path) {
+			continue
+		}
+
+		result, err := route.handler(user, r, path)
+		if err != nil {
+			statusCode := http.StatusInternalServerError
+			if restErr, ok := err.(*discordgo.RESTError); ok {
+				statusCode = restErr.Response.StatusCode
+			}
+
+			writeRESTError(w, statusCode, err.Error())
+			return
+		}
+
+		if result == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		s.mutex.Lock()
+		body, err := json.Marshal(result)
+		s.mutex.Unlock()
+		if err != nil {
+			writeRESTError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+		return
+	}
+
+	writeRESTError(w, http.StatusNotFound, fmt.Sprintf("404: %v %v isn't supported", r.Method, r.URL.Path))
+}
+
+func writeRESTError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"message": message, "code": 0})
+}
+
+// requirePermissions fails with 403 Forbidden unless the user has all of the given permissions in the channel.
+func (s *Server) requirePermissions(user *discordgo.User, guildID string, channelID string, permissions int64) error {
+	if s.Permissions(guildID, user.ID, channelID)&permissions != permissions {
+		return ForbiddenError()
+	}
+
+	return nil
+}
+
+func (s *Server) guildOfChannel(channelID string) (string, error) {
+	channel, err := s.Channel(channelID)
+	if err != nil {
+		return "", err
+	}
+
+	return channel.GuildID, nil
+}
+
+func (s *Server) getGateway(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	url := "ws" + strings.TrimPrefix(s.httpServer.URL, "http") + gatewayPath
+	return map[string]interface{}{"url": url, "shards": 1}, nil
+}
+
+func (s *Server) getUser(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	if path[1] == "@me" || path[1] == user.ID {
+		return user, nil
+	}
+
+	return s.User(path[1])
+}
+
+func (s *Server) getUserGuilds(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	userGuilds := []*discordgo.UserGuild{}
+	for guildID, guild := range s.guilds {
+		if s.memberNoLock(guildID, user.ID) == nil {
+			continue
+		}
+
+		userGuilds = append(userGuilds, &discordgo.UserGuild{
+			ID:          guildID,
+			Name:        guild.Name,
+			Owner:       guild.OwnerID == user.ID,
+			Permissions: s.permissionsNoLock(guildID, user.ID, ""),
+		})
+	}
+
+	return userGuilds, nil
+}
+
+func (s *Server) getGuild(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	guild, err := s.StateGuild(path[1])
+	if err != nil {
+		return nil, NotFoundError()
+	}
+
+	return guild, nil
+}
+
+func (s *Server) getGuildChannels(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	guild, err := s.StateGuild(path[1])
+	if err != nil {
+		return nil, NotFoundError()
+	}
+
+	return guild.Channels, nil
+}
+
+func (s *Server) getMember(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	return s.GuildMember(path[1], path[3])
+}
+
+func (s *Server) getChannel(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	return s.Channel(path[1])
+}
+
+func (s *Server) createChannel(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	data := discordgo.GuildChannelCreateData{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		return nil, restError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := s.requirePermissions(user, path[1], data.ParentID, discordgo.PermissionManageChannels); err != nil {
+		return nil, err
+	}
+
+	channel, err := s.GuildChannelCreateComplex(path[1], data)
+	if err != nil {
+		return nil, err
+	}
+
+	s.dispatch(channel.GuildID, "CHANNEL_CREATE", channel, nil)
+	return channel, nil
+}
+
+func (s *Server) editChannel(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	data := &discordgo.ChannelEdit{}
+	if err := json.NewDecoder(r.Body).Decode(data); err != nil {
+		return nil, restError(http.StatusBadRequest, err.Error())
+	}
+
+	guildID, err := s.guildOfChannel(path[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requirePermissions(user, guildID, path[1], discordgo.PermissionManageChannels); err != nil {
+		return nil, err
+	}
+
+	channel, err := s.ChannelEdit(path[1], data)
+	if err != nil {
+		return nil, err
+	}
+
+	s.dispatch(guildID, "CHANNEL_UPDATE", channel, nil)
+	return channel, nil
+}
+
+func (s *Server) deleteChannel(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	guildID, err := s.guildOfChannel(path[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requirePermissions(user, guildID, path[1], discordgo.PermissionManageChannels); err != nil {
+		return nil, err
+	}
+
+	channel, err := s.ChannelDelete(path[1])
+	if err != nil {
+		return nil, err
+	}
+
+	s.dispatch(guildID, "CHANNEL_DELETE", channel, nil)
+	return channel, nil
+}
+
+func (s *Server) setPermission(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	overwrite := &discordgo.PermissionOverwrite{}
+	if err := json.NewDecoder(r.Body).Decode(overwrite); err != nil {
+		return nil, restError(http.StatusBadRequest, err.Error())
+	}
+
+	return nil, s.changePermissions(user, path[1], func() error {
+		return s.ChannelPermissionSet(path[1], path[3], overwrite.Type, overwrite.Allow, overwrite.Deny)
+	})
+}
+
+func (s *Server) deletePermission(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	return nil, s.changePermissions(user, path[1], func() error {
+		return s.ChannelPermissionDelete(path[1], path[3])
+	})
+}
+
+func (s *Server) changePermissions(user *discordgo.User, channelID string, change func() error) error {
+	guildID, err := s.guildOfChannel(channelID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.requirePermissions(user, guildID, channelID, discordgo.PermissionManageRoles); err != nil {
+		return err
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	channel, err := s.Channel(channelID)
+	if err != nil {
+		return err
+	}
+
+	s.dispatch(guildID, "CHANNEL_UPDATE", channel, nil)
+	return nil
+}
+
+func (s *Server) getMessages(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	guildID, err := s.guildOfChannel(path[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requirePermissions(user, guildID, path[1], discordgo.PermissionViewChannel); err != nil {
+		return nil, err
+	}
+
+	// Like Discord, users without the Read Message History permission get no messages rather than an error
+	if s.Permissions(guildID, user.ID, path[1])&discordgo.PermissionReadMessageHistory == 0 {
+		return []*discordgo.Message{}, nil
+	}
+
+	limit := 50
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, restError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	query := r.URL.Query()
+	return s.ChannelMessages(path[1], limit, query.Get("before"), query.Get("after"), query.Get("around"))
+}
+
+func (s *Server) sendMessage(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	data, err := decodeMessageSend(r)
+	if err != nil {
+		return nil, restError(http.StatusBadRequest, err.Error())
+	}
+
+	guildID, err := s.guildOfChannel(path[1])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requirePermissions(user, guildID, path[1], discordgo.PermissionViewChannel|discordgo.PermissionSendMessages); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	event, err := s.sendMessageNoLock(path[1], user, data)
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	s.dispatch(guildID, "MESSAGE_CREATE", event.Message, func(userID string) bool {
+		return s.permissionsNoLock(guildID, userID, path[1])&discordgo.PermissionViewChannel != 0
+	})
+	return event.Message, nil
+}
+
+// decodeMessageSend reads a message from either a JSON body, or a multipart body with attached files.
+func decodeMessageSend(r *http.Request) (*discordgo.MessageSend, error) {
+	data := &discordgo.MessageSend{}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return data, json.NewDecoder(r.Body).Decode(data)
+	}
+
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(r.FormValue("payload_json")), data); err != nil {
+		return nil, err
+	}
+
+	for _, files := range r.MultipartForm.File {
+		for _, file := range files {
+			data.Files = append(data.Files, &discordgo.File{Name: file.Filename})
+		}
+	}
+
+	return data, nil
+}
+
+func (s *Server) overwriteCommands(user *discordgo.User, r *http.Request, path []string) (interface{}, error) {
+	commands := []*discordgo.ApplicationCommand{}
+	if err := json.NewDecoder(r.Body).Decode(&commands); err != nil {
+		return nil, restError(http.StatusBadRequest, err.Error())
+	}
+
+	return s.ApplicationCommandBulkOverwrite(path[1], "", commands)
+}
+
+func (s *Server) serveGateway(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed upgrading gateway connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	connection := &gatewayConnection{conn: conn}
+	if err := connection.send(gatewayOpHello, "", map[string]interface{}{"heartbeat_interval": gatewayHeartbeatInterval}); err != nil {
+		return
+	}
+
+	for {
+		payload := &gatewayPayload{}
+		if err := conn.ReadJSON(payload); err != nil {
+			s.removeConnection(connection)
+			return
+		}
+
+		if err := s.handleGatewayPayload(connection, payload); err != nil {
+			log.Printf("Gateway connection failed: %v", err)
+			s.removeConnection(connection)
+			return
+		}
+	}
+}
+
+func (s *Server) handleGatewayPayload(connection *gatewayConnection, payload *gatewayPayload) error {
+	switch payload.Operation {
+	case gatewayOpHeartbeat:
+		return connection.send(gatewayOpHeartbeatACK, "", nil)
+
+	case gatewayOpIdentify, gatewayOpResume:
+		identify := struct {
+			Token string `json:"token"`
+		}{}
+		if err := json.Unmarshal(payload.Data, &identify); err != nil {
+			return err
+		}
+
+		user, authenticated := s.authenticate(identify.Token)
+		if !authenticated {
+			message := websocket.FormatCloseMessage(gatewayCloseAuthenticationFailed, "Authentication failed.")
+			_ = connection.conn.WriteMessage(websocket.CloseMessage, message)
+			return fmt.Errorf("unknown token")
+		}
+
+		connection.user = user
+		return s.sendReady(connection)
+
+	case gatewayOpVoiceStateUpdate:
+		if connection.user == nil {
+			return fmt.Errorf("voice state update before identify")
+		}
+
+		update := struct {
+			GuildID   string `json:"guild_id"`
+			ChannelID string `json:"channel_id"`
+			SelfMute  bool   `json:"self_mute"`
+			SelfDeaf  bool   `json:"self_deaf"`
+		}{}
+		if err := json.Unmarshal(payload.Data, &update); err != nil {
+			return err
+		}
+
+		voiceState := s.SetVoiceState(update.GuildID, connection.user.ID, update.ChannelID).VoiceState
+		voiceState.SelfMute = update.SelfMute
+		voiceState.SelfDeaf = update.SelfDeaf
+		if member, err := s.StateMember(update.GuildID, connection.user.ID); err == nil {
+			voiceState.Member = member
+		}
+
+		s.dispatch(update.GuildID, "VOICE_STATE_UPDATE", voiceState, nil)
+		return nil
+	}
+
+	return nil
+}
+
+// sendReady sends READY with the user's guilds as unavailable, followed by a GUILD_CREATE for each of them, like Discord does.
+func (s *Server) sendReady(connection *gatewayConnection) error {
+	s.mutex.Lock()
+	guilds := []*discordgo.Guild{}
+	unavailableGuilds := []*discordgo.Guild{}
+	for guildID, guild := range s.guilds {
+		if s.memberNoLock(guildID, connection.user.ID) != nil {
+			guilds = append(guilds, guild)
+			unavailableGuilds = append(unavailableGuilds, &discordgo.Guild{ID: guildID, Unavailable: true})
+		}
+	}
+
+	ready := &discordgo.Ready{
+		Version:     9,
+		SessionID:   "session-" + connection.user.ID,
+		User:        connection.user,
+		Application: &discordgo.Application{ID: connection.user.ID},
+		Guilds:      unavailableGuilds,
+	}
+	readyData, err := json.Marshal(ready)
+	if err != nil {
+		s.mutex.Unlock()
+		return err
+	}
+
+	guildsData := [][]byte{}
+	for _, guild := range guilds {
+		guildData, err := json.Marshal(guild)
+		if err != nil {
+			s.mutex.Unlock()
+			return err
+		}
+
+		guildsData = append(guildsData, guildData)
+	}
+	s.mutex.Unlock()
+
+	s.connectionsMutex.Lock()
+	s.connections[connection] = true
+	s.connectionsMutex.Unlock()
+
+	if err := connection.sendRaw(gatewayOpDispatch, "READY", readyData); err != nil {
+		return err
+	}
+
+	for _, guildData := range guildsData {
+		if err := connection.sendRaw(gatewayOpDispatch, "GUILD_CREATE", guildData); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) removeConnection(connection *gatewayConnection) {
+	s.connectionsMutex.Lock()
+	defer s.connectionsMutex.Unlock()
+	delete(s.connections, connection)
+}
+
+// dispatch sends an event to every connected member of the guild that passes the filter, the filter may be nil.
+// The filter is called while the Session is locked.
+func (s *Server) dispatch(guildID string, eventType string, event interface{}, filter func(userID string) bool) {
+	s.mutex.Lock()
+	data, err := json.Marshal(event)
+	if err != nil {
+		s.mutex.Unlock()
+		log.Printf("Failed encoding %v event: %v", eventType, err)
+		return
+	}
+
+	s.connectionsMutex.Lock()
+	recipients := []*gatewayConnection{}
+	for connection := range s.connections {
+		if s.memberNoLock(guildID, connection.user.ID) == nil {
+			continue
+		}
+
+		if filter == nil || filter(connection.user.ID) {
+			recipients = append(recipients, connection)
+		}
+	}
+	s.connectionsMutex.Unlock()
+	s.mutex.Unlock()
+
+	for _, connection := range recipients {
+		if err := connection.sendRaw(gatewayOpDispatch, eventType, data); err != nil {
+			log.Printf("Failed dispatching %v event to %v: %v", eventType, connection.user.ID, err)
+		}
+	}
+}
+
+func (c *gatewayConnection) send(operation int, eventType string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return c.sendRaw(operation, eventType, encoded)
+}
+
+func (c *gatewayConnection) sendRaw(operation int, eventType string, data json.RawMessage) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	payload := &gatewayPayload{Operation: operation, Type: eventType, Data: data}
+	if operation == gatewayOpDispatch {
+		c.sequence++
+		payload.Sequence = c.sequence
+	}
+
+	return c.conn.WriteJSON(payload)
+}
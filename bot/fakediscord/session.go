@@ -0,0 +1,607 @@
+// Package fakediscord is an in-memory stand-in for the parts of Discord the bot uses.
+// Session lets the bot's handlers be unit tested without connecting to Discord,
+// and Server serves the same data over HTTP and a gateway websocket for end-to-end tests of discordgo clients.
+package fakediscord
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// DefaultEveryonePermissions are the permissions of the @everyone role of new guilds.
+const DefaultEveryonePermissions = discordgo.PermissionViewChannel | discordgo.PermissionSendMessages | discordgo.PermissionReadMessageHistory
+
+// Session is an in-memory Discord, holding guilds with their channels, roles, members and voice states.
+// Both its REST and state methods read and write the same data, as if the gateway dispatched every change immediately.
+type Session struct {
+	mutex sync.Mutex
+
+	BotUser *discordgo.User
+
+	guilds      map[string]*discordgo.Guild
+	channels    map[string]*discordgo.Channel
+	messages    map[string][]*discordgo.Message
+	attachments map[string][]byte
+	followups   []*discordgo.WebhookParams
+	commands    []*discordgo.ApplicationCommand
+	// removedFrom are the guilds the bot was removed from, whose data is kept for the tests to inspect.
+	removedFrom map[string]bool
+
+	lastID uint64
+}
+
+// NewSession initializes a new instance of Session, with a bot user of the given ID.
+func NewSession(botUserID string) *Session {
+	return &Session{
+		BotUser:     &discordgo.User{ID: botUserID, Username: "temp-chat", Bot: true},
+		guilds:      map[string]*discordgo.Guild{},
+		channels:    map[string]*discordgo.Channel{},
+		messages:    map[string][]*discordgo.Message{},
+		attachments: map[string][]byte{},
+		removedFrom: map[string]bool{},
+		lastID:      1000,
+	}
+}
+
+// NotFoundError is the error Discord's REST API returns for unknown objects.
+func NotFoundError() error {
+	return restError(http.StatusNotFound, "404 Not Found")
+}
+
+// ForbiddenError is the error Discord's REST API returns when the bot is missing permissions.
+func ForbiddenError() error {
+	return restError(http.StatusForbidden, "403 Forbidden")
+}
+
+func restError(statusCode int, status string) error {
+	return &discordgo.RESTError{Response: &http.Response{StatusCode: statusCode, Status: status}}
+}
+
+func (s *Session) newIDNoLock() string {
+	s.lastID++
+	return strconv.FormatUint(s.lastID, 10)
+}
+
+// NewID returns a new unique snowflake, for creating users that aren't members of any guild.
+func (s *Session) NewID() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.newIDNoLock()
+}
+
+// AddGuild creates a guild owned by the given user, with an @everyone role and the bot as a member.
+// As in Discord, the ID of the @everyone role is the ID of the guild.
+func (s *Session) AddGuild(ownerID string) *discordgo.Guild {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	guildID := s.newIDNoLock()
+	guild := &discordgo.Guild{
+		ID:      guildID,
+		Name:    "guild-" + guildID,
+		OwnerID: ownerID,
+		Roles:   []*discordgo.Role{{ID: guildID, Name: "@everyone", Permissions: DefaultEveryonePermissions}},
+	}
+	guild.Members = append(guild.Members, &discordgo.Member{GuildID: guildID, User: s.BotUser})
+	s.guilds[guildID] = guild
+
+	return guild
+}
+
+// RemoveBot removes the bot from the guild, as if it was kicked while it was down.
+// Fetching the channels of the guild fails with 403 Forbidden from then on.
+func (s *Session) RemoveBot(guildID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.removedFrom[guildID] = true
+}
+
+// AddRole creates a role with the given permissions.
+func (s *Session) AddRole(guildID string, name string, permissions int64) *discordgo.Role {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	role := &discordgo.Role{ID: s.newIDNoLock(), Name: name, Permissions: permissions}
+	guild := s.guilds[guildID]
+	guild.Roles = append(guild.Roles, role)
+
+	return role
+}
+
+// AddMember adds a user with the given roles to the guild.
+func (s *Session) AddMember(guildID string, userID string, roleIDs ...string) *discordgo.Member {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	member := &discordgo.Member{
+		GuildID: guildID,
+		User:    &discordgo.User{ID: userID, Username: "user-" + userID},
+		Roles:   roleIDs,
+	}
+	s.guilds[guildID].Members = append(s.guilds[guildID].Members, member)
+
+	return member
+}
+
+// AddChannel creates a channel of the given type, parentID may be empty for channels outside of a category.
+func (s *Session) AddChannel(guildID string, channelType discordgo.ChannelType, name string, parentID string, overwrites ...*discordgo.PermissionOverwrite) *discordgo.Channel {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.addChannelNoLock(guildID, &discordgo.Channel{
+		Type:                 channelType,
+		Name:                 name,
+		ParentID:             parentID,
+		PermissionOverwrites: overwrites,
+	})
+}
+
+func (s *Session) addChannelNoLock(guildID string, channel *discordgo.Channel) *discordgo.Channel {
+	channel.ID = s.newIDNoLock()
+	channel.GuildID = guildID
+	s.channels[channel.ID] = channel
+
+	guild := s.guilds[guildID]
+	guild.Channels = append(guild.Channels, channel)
+
+	return channel
+}
+
+// SetVoiceState moves a user to a voice channel, or out of voice chats if channelID is empty.
+// Returns the event the gateway would dispatch for the change.
+func (s *Session) SetVoiceState(guildID string, userID string, channelID string) *discordgo.VoiceStateUpdate {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	guild := s.guilds[guildID]
+	voiceState := &discordgo.VoiceState{GuildID: guildID, UserID: userID, ChannelID: channelID}
+
+	var before *discordgo.VoiceState
+	voiceStates := []*discordgo.VoiceState{}
+	for _, existing := range guild.VoiceStates {
+		if existing.UserID == userID {
+			before = existing
+			continue
+		}
+
+		voiceStates = append(voiceStates, existing)
+	}
+
+	if channelID != "" {
+		voiceStates = append(voiceStates, voiceState)
+	}
+	guild.VoiceStates = voiceStates
+
+	return &discordgo.VoiceStateUpdate{VoiceState: voiceState, BeforeUpdate: before}
+}
+
+// ChannelsInCategory returns the channels whose parent is the given category.
+func (s *Session) ChannelsInCategory(guildID string, categoryID string) []*discordgo.Channel {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	channels := []*discordgo.Channel{}
+	for _, channel := range s.guilds[guildID].Channels {
+		if channel.ParentID == categoryID {
+			channels = append(channels, channel)
+		}
+	}
+
+	return channels
+}
+
+// Messages returns the messages sent to a channel, from oldest to newest.
+func (s *Session) Messages(channelID string) []*discordgo.Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*discordgo.Message{}, s.messages[channelID]...)
+}
+
+// Followups returns the followup messages sent to interactions, from oldest to newest.
+func (s *Session) Followups() []*discordgo.WebhookParams {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*discordgo.WebhookParams{}, s.followups...)
+}
+
+// User returns the bot user for "@me", or a member of any guild.
+func (s *Session) User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if userID == "@me" || userID == s.BotUser.ID {
+		return s.BotUser, nil
+	}
+
+	for _, guild := range s.guilds {
+		for _, member := range guild.Members {
+			if member.User.ID == userID {
+				return member.User, nil
+			}
+		}
+	}
+
+	return nil, NotFoundError()
+}
+
+// GuildMember returns a member of the guild.
+func (s *Session) GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error) {
+	member, err := s.StateMember(guildID, userID)
+	if err != nil {
+		return nil, NotFoundError()
+	}
+
+	return member, nil
+}
+
+// Channel returns a channel.
+func (s *Session) Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	channel, found := s.channels[channelID]
+	if !found {
+		return nil, NotFoundError()
+	}
+
+	if s.removedFrom[channel.GuildID] {
+		return nil, ForbiddenError()
+	}
+
+	return channel, nil
+}
+
+// GuildChannelCreateComplex creates a channel in the guild.
+func (s *Session) GuildChannelCreateComplex(guildID string, data discordgo.GuildChannelCreateData, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.guilds[guildID]; !found {
+		return nil, NotFoundError()
+	}
+
+	return s.addChannelNoLock(guildID, &discordgo.Channel{
+		Type:                 data.Type,
+		Name:                 data.Name,
+		Topic:                data.Topic,
+		ParentID:             data.ParentID,
+		PermissionOverwrites: data.PermissionOverwrites,
+	}), nil
+}
+
+// ChannelEdit changes the name, topic, category or permission overwrites of a channel.
+func (s *Session) ChannelEdit(channelID string, data *discordgo.ChannelEdit, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	channel, found := s.channels[channelID]
+	if !found {
+		return nil, NotFoundError()
+	}
+
+	if data.Name != "" {
+		channel.Name = data.Name
+	}
+
+	if data.Topic != "" {
+		channel.Topic = data.Topic
+	}
+
+	if data.ParentID != "" {
+		channel.ParentID = data.ParentID
+	}
+
+	if data.PermissionOverwrites != nil {
+		channel.PermissionOverwrites = data.PermissionOverwrites
+	}
+
+	return channel, nil
+}
+
+// ChannelDelete deletes a channel.
+func (s *Session) ChannelDelete(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	channel, found := s.channels[channelID]
+	if !found {
+		return nil, NotFoundError()
+	}
+
+	delete(s.channels, channelID)
+	delete(s.messages, channelID)
+
+	guild := s.guilds[channel.GuildID]
+	channels := []*discordgo.Channel{}
+	for _, guildChannel := range guild.Channels {
+		if guildChannel.ID != channelID {
+			channels = append(channels, guildChannel)
+		}
+	}
+	guild.Channels = channels
+
+	return channel, nil
+}
+
+// ChannelPermissionSet creates or replaces the permission overwrite of a role or a member in a channel.
+func (s *Session) ChannelPermissionSet(channelID, targetID string, targetType discordgo.PermissionOverwriteType, allow, deny int64, options ...discordgo.RequestOption) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	channel, found := s.channels[channelID]
+	if !found {
+		return NotFoundError()
+	}
+
+	overwrite := &discordgo.PermissionOverwrite{ID: targetID, Type: targetType, Allow: allow, Deny: deny}
+	for i, existing := range channel.PermissionOverwrites {
+		if existing.ID == targetID {
+			channel.PermissionOverwrites[i] = overwrite
+			return nil
+		}
+	}
+
+	channel.PermissionOverwrites = append(channel.PermissionOverwrites, overwrite)
+	return nil
+}
+
+// ChannelPermissionDelete removes the permission overwrite of a role or a member from a channel.
+func (s *Session) ChannelPermissionDelete(channelID, targetID string, options ...discordgo.RequestOption) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	channel, found := s.channels[channelID]
+	if !found {
+		return NotFoundError()
+	}
+
+	overwrites := []*discordgo.PermissionOverwrite{}
+	for _, existing := range channel.PermissionOverwrites {
+		if existing.ID != targetID {
+			overwrites = append(overwrites, existing)
+		}
+	}
+	channel.PermissionOverwrites = overwrites
+
+	return nil
+}
+
+// ChannelMessages returns up to limit messages of a channel from newest to oldest, older than beforeID if given.
+func (s *Session) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.channels[channelID]; !found {
+		return nil, NotFoundError()
+	}
+
+	messages := s.messages[channelID]
+	result := []*discordgo.Message{}
+	reachedBefore := beforeID == ""
+	for i := len(messages) - 1; i >= 0 && len(result) < limit; i-- {
+		if !reachedBefore {
+			reachedBefore = messages[i].ID == beforeID
+			continue
+		}
+
+		result = append(result, messages[i])
+	}
+
+	return result, nil
+}
+
+// ChannelMessageSend sends a message to a channel as the bot.
+func (s *Session) ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{Content: content})
+}
+
+// ChannelMessageSendComplex sends a message to a channel as the bot.
+func (s *Session) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.addMessageNoLock(channelID, s.BotUser, data)
+}
+
+// SendMessage sends a message to a channel as the given user, as if the user typed it.
+// Returns the event the gateway would dispatch for the message.
+func (s *Session) SendMessage(channelID string, userID string, content string) (*discordgo.MessageCreate, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	author := &discordgo.User{ID: userID, Username: "user-" + userID}
+	return s.sendMessageNoLock(channelID, author, &discordgo.MessageSend{Content: content})
+}
+
+// SendMessageWithFiles sends a message with attached files to a channel as the given user.
+// Returns the event the gateway would dispatch for the message.
+func (s *Session) SendMessageWithFiles(channelID string, userID string, content string, files ...*discordgo.File) (*discordgo.MessageCreate, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	author := &discordgo.User{ID: userID, Username: "user-" + userID}
+	return s.sendMessageNoLock(channelID, author, &discordgo.MessageSend{Content: content, Files: files})
+}
+
+func (s *Session) sendMessageNoLock(channelID string, author *discordgo.User, data *discordgo.MessageSend) (*discordgo.MessageCreate, error) {
+	message, err := s.addMessageNoLock(channelID, author, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like the gateway, the member of the author is sent without its user
+	if member := s.memberNoLock(message.GuildID, author.ID); member != nil {
+		message.Member = &discordgo.Member{Nick: member.Nick, Roles: member.Roles}
+	}
+
+	return &discordgo.MessageCreate{Message: message}, nil
+}
+
+func (s *Session) addMessageNoLock(channelID string, author *discordgo.User, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	channel, found := s.channels[channelID]
+	if !found {
+		return nil, NotFoundError()
+	}
+
+	message := &discordgo.Message{
+		ID:        s.newIDNoLock(),
+		ChannelID: channelID,
+		GuildID:   channel.GuildID,
+		Content:   data.Content,
+		Author:    author,
+		Timestamp: time.Now().UTC(),
+	}
+
+	for _, file := range data.Files {
+		content, err := ioutil.ReadAll(file.Reader)
+		if err != nil {
+			return nil, restError(http.StatusBadRequest, err.Error())
+		}
+
+		attachmentID := s.newIDNoLock()
+		attachment := &discordgo.MessageAttachment{
+			ID:          attachmentID,
+			URL:         fmt.Sprintf("https://cdn.discordapp.com/attachments/%v/%v/%v", channelID, attachmentID, file.Name),
+			Filename:    file.Name,
+			ContentType: file.ContentType,
+			Size:        len(content),
+		}
+		s.attachments[attachment.URL] = content
+		message.Attachments = append(message.Attachments, attachment)
+	}
+
+	s.messages[channelID] = append(s.messages[channelID], message)
+	return message, nil
+}
+
+// AttachmentContent downloads the content of a file attached to a message.
+func (s *Session) AttachmentContent(attachment *discordgo.MessageAttachment) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	content, found := s.attachments[attachment.URL]
+	if !found {
+		return nil, NotFoundError()
+	}
+
+	return content, nil
+}
+
+// ApplicationCommandBulkOverwrite replaces the registered application commands.
+func (s *Session) ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.commands = commands
+	return commands, nil
+}
+
+// InteractionRespond acknowledges an interaction, responses aren't kept.
+func (s *Session) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error {
+	return nil
+}
+
+// InteractionResponseDelete deletes the response to an interaction.
+func (s *Session) InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error {
+	return nil
+}
+
+// FollowupMessageCreate sends a followup message to an interaction, kept in Followups.
+func (s *Session) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.followups = append(s.followups, data)
+	return &discordgo.Message{ID: s.newIDNoLock(), ChannelID: interaction.ChannelID, Content: data.Content, Author: s.BotUser}, nil
+}
+
+// StateGuild returns a guild, including its roles, channels and voice states.
+func (s *Session) StateGuild(guildID string) (*discordgo.Guild, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	guild, found := s.guilds[guildID]
+	if !found {
+		return nil, discordgo.ErrStateNotFound
+	}
+
+	return guild, nil
+}
+
+// StateChannel returns a channel.
+func (s *Session) StateChannel(channelID string) (*discordgo.Channel, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	channel, found := s.channels[channelID]
+	if !found {
+		return nil, discordgo.ErrStateNotFound
+	}
+
+	return channel, nil
+}
+
+// StateMember returns a member of a guild.
+func (s *Session) StateMember(guildID, userID string) (*discordgo.Member, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	guild, found := s.guilds[guildID]
+	if !found {
+		return nil, discordgo.ErrStateNotFound
+	}
+
+	for _, member := range guild.Members {
+		if member.User.ID == userID {
+			return member, nil
+		}
+	}
+
+	return nil, discordgo.ErrStateNotFound
+}
+
+// StateRole returns a role of a guild.
+func (s *Session) StateRole(guildID, roleID string) (*discordgo.Role, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	guild, found := s.guilds[guildID]
+	if !found {
+		return nil, discordgo.ErrStateNotFound
+	}
+
+	for _, role := range guild.Roles {
+		if role.ID == roleID {
+			return role, nil
+		}
+	}
+
+	return nil, discordgo.ErrStateNotFound
+}
+
+// StateMemberAdd adds or replaces a member of a guild.
+func (s *Session) StateMemberAdd(member *discordgo.Member) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	guild, found := s.guilds[member.GuildID]
+	if !found {
+		return discordgo.ErrStateNotFound
+	}
+
+	for i, existing := range guild.Members {
+		if existing.User.ID == member.User.ID {
+			guild.Members[i] = member
+			return nil
+		}
+	}
+
+	guild.Members = append(guild.Members, member)
+	return nil
+}
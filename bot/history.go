@@ -0,0 +1,196 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jonathroth/temp-chat/state"
+)
+
+// historyPageSize is the number of changes listed by a single history command.
+const historyPageSize = 10
+
+func (b *TempChannelBot) historyHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) > 1 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	page := 1
+	if len(context.CommandArgs) == 1 {
+		var err error
+		page, err = strconv.Atoi(context.CommandArgs[0])
+		if err != nil || page < 1 {
+			context.reply("Invalid page number, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+			return nil
+		}
+	}
+
+	changes, err := b.configChanges.ConfigChanges(context.ServerID, (page-1)*historyPageSize, historyPageSize)
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("ConfigChanges failed: %w", err)
+	}
+
+	if len(changes) == 0 {
+		if page == 1 {
+			context.reply("The settings weren't changed yet")
+		} else {
+			context.reply("There are no changes on page %v", page)
+		}
+		return nil
+	}
+
+	lines := make([]string, 0, len(changes)+1)
+	for _, change := range changes {
+		lines = append(lines, fmt.Sprintf("#%v %v by %v: %v", change.ChangeID, change.ChangedAt.UTC().Format("2006-01-02 15:04"),
+			context.userDisplayName(change.UserID), context.describeChange(change)))
+	}
+
+	if len(changes) == historyPageSize {
+		lines = append(lines, fmt.Sprintf("Use %vhistory %v for older changes", context.ServerData.CommandPrefix(), page+1))
+	}
+
+	context.reply("%v", strings.Join(lines, "\n"))
+	return nil
+}
+
+func (b *TempChannelBot) revertHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) < 1 {
+		context.reply("Missing change number, please check %vhistory to find it", context.ServerData.CommandPrefix())
+		return nil
+	} else if len(context.CommandArgs) > 1 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	changeID, err := strconv.ParseInt(strings.TrimPrefix(context.CommandArgs[0], "#"), 10, 64)
+	if err != nil {
+		context.reply("Invalid change number, please check %vhistory to find it", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	change, found, err := b.configChanges.ConfigChange(context.ServerID, changeID)
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("ConfigChange failed: %w", err)
+	}
+
+	if !found {
+		context.reply("There's no change #%v, please check %vhistory to find it", changeID, context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	reverted, err := state.IsConfigChangeReverted(context.ServerData, change)
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("IsConfigChangeReverted failed: %w", err)
+	}
+
+	if reverted {
+		context.reply("The %v is already back to what it was before change #%v", change.Setting, changeID)
+		return nil
+	}
+
+	if !context.revertedChannelExists(change) {
+		context.reply("The channel the %v was set to before change #%v no longer exists", change.Setting, changeID)
+		return nil
+	}
+
+	err = state.RevertConfigChange(context.ServerData, change)
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("RevertConfigChange failed: %w", err)
+	}
+
+	context.reply("Reverted change #%v: %v", changeID, context.describeChange(change))
+	return nil
+}
+
+// revertedChannelExists checks that the channel a change is reverted to still exists, for settings that are channels.
+func (c *CommandHandlerContext) revertedChannelExists(change *state.ConfigChange) bool {
+	if change.OldValue == "" {
+		return true
+	}
+
+	switch change.Setting {
+	case state.SettingTempChannelCategory:
+		return c.categoryExists(change.OldValue)
+	case state.SettingCommandChannel, state.SettingArchiveChannel:
+		return c.textChannelExists(change.OldValue)
+	case state.SettingCategoryRoutes:
+		routes, err := state.ParseCategoryRoutes(change.OldValue)
+		if err != nil {
+			return false
+		}
+
+		for _, route := range routes {
+			if !c.categoryExists(route.CategoryID.RESTAPIFormat()) {
+				return false
+			}
+		}
+	case state.SettingVoiceChannelPolicies:
+		policies, err := state.ParseVoiceChannelPolicies(change.OldValue)
+		if err != nil {
+			return false
+		}
+
+		for _, policy := range policies {
+			if !c.voiceChannelExists(policy.VoiceChannelID.RESTAPIFormat()) {
+				return false
+			}
+		}
+	case state.SettingAutoCreateChannels:
+		ids, err := state.ParseDiscordIDs(change.OldValue)
+		if err != nil {
+			return false
+		}
+
+		for _, id := range ids {
+			if !c.voiceChannelExists(id.RESTAPIFormat()) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (c *CommandHandlerContext) describeChange(change *state.ConfigChange) string {
+	if change.Setting == state.SettingCommandPermissionRule {
+		rule, added, err := change.CommandPermissionRule()
+		if err != nil {
+			return fmt.Sprintf("invalid permission rule change (%v)", err)
+		}
+
+		if added {
+			return fmt.Sprintf("allowed the %v to run %v", c.describeRule(rule), rule.Command)
+		}
+
+		return fmt.Sprintf("removed the rule allowing the %v to run %v", c.describeRule(rule), rule.Command)
+	}
+
+	return fmt.Sprintf("%v %v -> %v", change.Setting, describeSettingValue(change.OldValue), describeSettingValue(change.NewValue))
+}
+
+func describeSettingValue(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+
+	return value
+}
+
+func (c *CommandHandlerContext) userDisplayName(userID state.DiscordID) string {
+	if userID == c.BotUserID {
+		return "the bot"
+	}
+
+	member, err := c.Session.StateMember(c.GuildID, userID.RESTAPIFormat())
+	if err != nil {
+		return fmt.Sprintf("user %v", userID)
+	}
+
+	return memberDisplayName(member)
+}
@@ -0,0 +1,47 @@
+package bot
+
+func (s *BotTestSuite) TestHistoryListsChanges() {
+	s.setupServer()
+	s.runCommand(testOwnerID, "!set-prefix ?")
+	s.runCommand(testOwnerID, "?set-mkch tmp")
+
+	reply := s.runCommand(testOwnerID, "?history")
+	lines := splitLines(reply)
+	s.Require().Len(lines, 2, reply)
+	s.Contains(lines[0], "mkch-name (none) -> tmp")
+	s.Contains(lines[1], "prefix ! -> ?")
+}
+
+func (s *BotTestSuite) TestHistoryWithoutChanges() {
+	s.setupServer()
+	s.Contains(s.runCommand(testOwnerID, "!history"), "The settings weren't changed yet")
+}
+
+func (s *BotTestSuite) TestRevert() {
+	serverData := s.setupServer()
+	s.runCommand(testOwnerID, "!set-prefix ?")
+	changes, err := s.provider.ConfigChanges(s.serverID, 0, 1)
+	s.Require().NoError(err)
+	s.Require().Len(changes, 1)
+
+	reply := s.runCommand(testOwnerID, "?revert "+formatChangeID(changes[0].ChangeID))
+	s.Contains(reply, "Reverted change")
+	s.Equal("!", serverData.CommandPrefix())
+
+	reply = s.runCommand(testOwnerID, "!revert "+formatChangeID(changes[0].ChangeID))
+	s.Contains(reply, "already back")
+
+	reverts, err := s.provider.ConfigChanges(s.serverID, 0, 10)
+	s.Require().NoError(err)
+	s.Len(reverts, 2, "The revert wasn't recorded")
+}
+
+func (s *BotTestSuite) TestRevertMissingChange() {
+	s.setupServer()
+	s.Contains(s.runCommand(testOwnerID, "!revert 1000"), "There's no change #1000")
+}
+
+func (s *BotTestSuite) TestHistoryRequiresAdministrator() {
+	s.setupServer()
+	s.Contains(s.runCommand(testUser1ID, "!history"), `You must have "Administrator" permissions in order to run this command`)
+}
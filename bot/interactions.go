@@ -0,0 +1,205 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+// maxChannelOptions is the amount of channel options given to commands that accept a list of channels.
+const maxChannelOptions = 5
+
+// Ready is called when the bot connects to Discord, it registers the bot's application commands.
+func (b *TempChannelBot) Ready(_ *discordgo.Session, r *discordgo.Ready) {
+	defer recoverEvent("Ready")
+
+	// Unavailable servers are still listed, so only the servers the bot was removed from are missing
+	b.removeMissingServers(r.Guilds)
+
+	b.registerCommandsOnce.Do(func() {
+		_, err := b.session.ApplicationCommandBulkOverwrite(r.Application.ID, "", b.applicationCommands())
+		if err != nil {
+			log.Printf("Failed to register application commands: %v", err)
+			return
+		}
+
+		log.Printf("Registered %v application commands", len(b.commands))
+	})
+}
+
+// applicationCommands builds the application command definitions from the command registry.
+func (b *TempChannelBot) applicationCommands() []*discordgo.ApplicationCommand {
+	names := make([]string, 0, len(b.commands))
+	for name := range b.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	applicationCommands := make([]*discordgo.ApplicationCommand, 0, len(names))
+	for _, name := range names {
+		command := b.commands[name]
+		allowedInDM := name == "help"
+		applicationCommands = append(applicationCommands, &discordgo.ApplicationCommand{
+			Name:         name,
+			Description:  command.Description,
+			Options:      command.Options,
+			DMPermission: &allowedInDM,
+		})
+	}
+
+	return applicationCommands
+}
+
+// InteractionCreate is called whenever a user runs one of the bot's application commands.
+func (b *TempChannelBot) InteractionCreate(_ *discordgo.Session, i *discordgo.InteractionCreate) {
+	defer recoverEvent("InteractionCreate")
+
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+
+	err := b.session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	})
+	if err != nil {
+		log.Printf("Failed to acknowledge interaction: %v", err)
+		return
+	}
+
+	data := i.ApplicationCommandData()
+	context := NewInteractionCommandHandlerContext(b.session, i.Interaction, b.botUserID)
+	context.CommandName = data.Name
+
+	defer func() {
+		if context.replied {
+			return
+		}
+
+		// Removes the "thinking..." response left by the deferred response
+		err := b.session.InteractionResponseDelete(i.Interaction)
+		if err != nil {
+			log.Printf("Failed to delete deferred interaction response: %v", err)
+		}
+	}()
+
+	if command, found := b.commands[data.Name]; found {
+		context.CommandArgs = commandArgs(command, data.Options)
+	}
+
+	if i.Member != nil {
+		trackMember(b.session, i.GuildID, i.Member)
+	}
+
+	if context.isDM() {
+		b.handleDM(context)
+		return
+	}
+
+	serverID, err := state.ParseDiscordID(i.GuildID)
+	if err != nil {
+		log.Printf("Failed to parse discord server ID: %v", err)
+		return
+	}
+
+	serverData, serverIsSetup := b.store.Server(serverID)
+	context.ServerID = serverID
+	context.ServerData = serverData
+
+	prefix := consts.DefaultCommandPrefix
+	if serverIsSetup {
+		prefix = serverData.CommandPrefix()
+	}
+
+	b.handleCommand(context, prefix)
+}
+
+// commandArgs converts the options of an application command to the arguments a prefix command would have.
+// Options are ordered as defined by the command, options the user didn't give are skipped.
+func commandArgs(command *Command, options []*discordgo.ApplicationCommandInteractionDataOption) []string {
+	values := map[string]string{}
+	for _, option := range options {
+		values[option.Name] = fmt.Sprint(option.Value)
+	}
+
+	args := []string{}
+	for _, option := range command.Options {
+		value, given := values[option.Name]
+		if given {
+			args = append(args, value)
+		}
+	}
+
+	return args
+}
+
+func channelOption(name string, description string, required bool, channelTypes ...discordgo.ChannelType) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:         discordgo.ApplicationCommandOptionChannel,
+		Name:         name,
+		Description:  description,
+		Required:     required,
+		ChannelTypes: channelTypes,
+	}
+}
+
+// channelOptions creates a numbered list of optional channel options, for commands that accept a list of channels.
+func channelOptions(name string, description string, count int, channelType discordgo.ChannelType) []*discordgo.ApplicationCommandOption {
+	options := make([]*discordgo.ApplicationCommandOption, 0, count)
+	for i := 1; i <= count; i++ {
+		options = append(options, channelOption(fmt.Sprintf("%v-%v", name, i), description, false, channelType))
+	}
+
+	return options
+}
+
+func userOption(name string, description string, required bool) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionUser,
+		Name:        name,
+		Description: description,
+		Required:    required,
+	}
+}
+
+func attachmentOption(name string, description string, required bool) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionAttachment,
+		Name:        name,
+		Description: description,
+		Required:    required,
+	}
+}
+
+func stringOption(name string, description string, required bool, choices ...string) *discordgo.ApplicationCommandOption {
+	option := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        name,
+		Description: description,
+		Required:    required,
+	}
+
+	for _, choice := range choices {
+		option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{Name: choice, Value: choice})
+	}
+
+	return option
+}
+
+// permissionRuleOptions are the options of commands that add or remove a command permission rule.
+// Either a role/user or permissions are expected, giving both fails as too many arguments.
+func permissionRuleOptions() []*discordgo.ApplicationCommandOption {
+	return []*discordgo.ApplicationCommandOption{
+		stringOption("command", "The command name, e.g. mkch", true),
+		{
+			Type:        discordgo.ApplicationCommandOptionMentionable,
+			Name:        "target",
+			Description: "The role or user the rule applies to",
+		},
+		stringOption("permission", "The permission required by the rule", false, sortedPermissionNames()...),
+	}
+}
@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+// CreationLimits limit how often and how many temp channels are created in a server.
+type CreationLimits struct {
+	// Cooldown is how long a user waits between creating temp channels, 0 if there's no cooldown.
+	Cooldown time.Duration
+	// MaxChannels is the most temp channels the server may have at once, 0 if there's no limit.
+	MaxChannels int
+}
+
+// LimitError is returned when creating a temp channel would go over one of the server's creation limits.
+type LimitError struct {
+	// RetryAfter is how long the user has to wait before creating a temp channel, 0 if the server has too many temp channels.
+	RetryAfter time.Duration
+	// MaxChannels is the most temp channels the server may have, set if the server has too many temp channels.
+	MaxChannels int
+}
+
+func (e *LimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("The user has to wait %v before creating another temp channel", e.RetryAfter)
+	}
+
+	return fmt.Sprintf("The server already has the maximum of %v temp channels", e.MaxChannels)
+}
+
+// creator is a user that created temp channels in a server.
+type creator struct {
+	serverID state.DiscordID
+	userID   state.DiscordID
+}
+
+func (l *TempChannelList) checkLimitsNoLock(serverID state.DiscordID, userID state.DiscordID, limits CreationLimits, channelCount int, now time.Time) error {
+	if limits.MaxChannels > 0 && channelCount >= limits.MaxChannels {
+		return &LimitError{MaxChannels: limits.MaxChannels}
+	}
+
+	lastCreation, found := l.lastCreations[creator{serverID: serverID, userID: userID}]
+	if limits.Cooldown > 0 && found {
+		if retryAfter := lastCreation.Add(limits.Cooldown).Sub(now); retryAfter > 0 {
+			// Rounded up, so the user never retries too early
+			return &LimitError{RetryAfter: (retryAfter + time.Second - 1).Truncate(time.Second)}
+		}
+	}
+
+	return nil
+}
+
+// recordCreationNoLock starts the user's cooldown.
+// Creations older than the longest cooldown can't limit anyone anymore, so they're forgotten.
+func (l *TempChannelList) recordCreationNoLock(serverID state.DiscordID, userID state.DiscordID, now time.Time) {
+	for key, createdAt := range l.lastCreations {
+		if now.Sub(createdAt) > consts.MaxMkchCooldown {
+			delete(l.lastCreations, key)
+		}
+	}
+
+	l.lastCreations[creator{serverID: serverID, userID: userID}] = now
+}
+
+// ServerChannelCount returns the number of temp channels the server has.
+func (l *TempChannelList) ServerChannelCount(serverID state.DiscordID) int {
+	l.RLock()
+	defer l.RUnlock()
+	return l.serverChannelCountNoLock(serverID)
+}
+
+// limitReply returns the reply to a user whose temp channel wasn't created because of the limit error.
+func limitReply(err *LimitError, prefix string) string {
+	if err.RetryAfter > 0 {
+		return fmt.Sprintf("You're creating temp channels too often, please try again in %v", err.RetryAfter)
+	}
+
+	return fmt.Sprintf("This server already has the maximum of %v temp channels, please try again once one of them is deleted or ask an admin to run %vset-max-channels", err.MaxChannels, prefix)
+}
+
+// parseMkchCooldown parses a cooldown given to a command or imported.
+// Returns a message explaining the problem if the cooldown is invalid.
+func parseMkchCooldown(value string) (time.Duration, string) {
+	cooldown, err := parseDuration(value)
+	if err != nil || cooldown < time.Second {
+		return 0, "Invalid cooldown, please use a number of seconds or a duration such as 30s or 5m"
+	}
+
+	if cooldown > consts.MaxMkchCooldown {
+		return 0, fmt.Sprintf("The cooldown cannot be longer than %v", consts.MaxMkchCooldown)
+	}
+
+	return cooldown.Truncate(time.Second), ""
+}
+
+// parseMaxTempChannels parses a maximum number of temp channels given to a command or imported.
+// Returns a message explaining the problem if the number is invalid.
+func parseMaxTempChannels(value string) (int, string) {
+	maxChannels, err := strconv.Atoi(value)
+	if err != nil || maxChannels < 1 {
+		return 0, "Invalid number of temp channels, please use a positive number"
+	}
+
+	if maxChannels > consts.MaxGuildChannels {
+		return 0, fmt.Sprintf("A server cannot have more than %v channels", consts.MaxGuildChannels)
+	}
+
+	return maxChannels, ""
+}
@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"time"
+
+	"github.com/jonathroth/temp-chat/state"
+)
+
+func (s *BotTestSuite) TestMkchCooldown() {
+	serverData := s.setupServer()
+	s.updateConfig(serverData, func(config *state.ServerConfig) { config.MkchCooldown = time.Minute })
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	s.joinVoice(testUser1ID, s.voiceChannel2)
+	s.Contains(s.runCommand(testUser1ID, "!mkch"), "You're creating temp channels too often, please try again in 1m0s")
+	s.Empty(s.tempChannels())
+
+	s.joinVoice(testUser2ID, s.voiceChannel2)
+	s.Contains(s.runCommand(testUser2ID, "!mkch"), "The temporary channel was created", "Another user's cooldown applied to the user")
+	s.Contains(s.runCommand(testUser1ID, "!mkch"), "A temp channel already exists", "The cooldown applied to an existing channel")
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	creation := creator{serverID: s.serverID, userID: s.parseID(testUser1ID)}
+	s.bot.tempChannels.lastCreations[creation] = s.bot.tempChannels.lastCreations[creation].Add(-time.Minute)
+	s.Contains(s.runCommand(testUser1ID, "!mkch"), "The temporary channel was created")
+}
+
+func (s *BotTestSuite) TestMaxTempChannels() {
+	serverData := s.setupServer()
+	s.updateConfig(serverData, func(config *state.ServerConfig) { config.MaxTempChannels = 1 })
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	s.joinVoice(testUser2ID, s.voiceChannel2)
+	s.Contains(s.runCommand(testUser2ID, "!mkch"), "This server already has the maximum of 1 temp channels")
+	s.requireTempChannel()
+
+	s.Require().NoError(serverData.SetAutoCreate(true))
+	s.joinVoice(testUser3ID, s.voiceChannel2)
+	s.requireTempChannel()
+
+	s.joinVoice(testUser1ID, nil)
+	s.Contains(s.runCommand(testUser2ID, "!mkch"), "The temporary channel was created", "A deleted channel still counted")
+}
+
+func (s *BotTestSuite) TestSetCreationLimits() {
+	serverData := s.setupServer()
+	s.Contains(s.runCommand(testOwnerID, "!set-mkch-cooldown"), "already create temp channels without waiting")
+	s.Contains(s.runCommand(testOwnerID, "!set-mkch-cooldown 0"), "Invalid cooldown")
+	s.Contains(s.runCommand(testOwnerID, "!set-mkch-cooldown 2h"), "The cooldown cannot be longer than")
+	s.Contains(s.runCommand(testOwnerID, "!set-mkch-cooldown 30"), "Users will wait 30s between creating temp channels")
+	s.Equal(30*time.Second, serverData.Config().MkchCooldown)
+	s.Contains(s.runCommand(testOwnerID, "!set-mkch-cooldown"), "without waiting")
+	s.Zero(serverData.Config().MkchCooldown)
+
+	s.Contains(s.runCommand(testOwnerID, "!set-max-channels none"), "Invalid number of temp channels")
+	s.Contains(s.runCommand(testOwnerID, "!set-max-channels 501"), "A server cannot have more than 500 channels")
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	s.joinVoice(testUser2ID, s.voiceChannel2)
+	s.runCommand(testUser2ID, "!mkch")
+	s.Contains(s.runCommand(testOwnerID, "!set-max-channels 1"), "its 2 existing temp channels are kept")
+	s.Equal(1, serverData.Config().MaxTempChannels)
+	s.Contains(s.runCommand(testOwnerID, "!set-max-channels"), "no longer limited")
+	s.Zero(serverData.Config().MaxTempChannels)
+}
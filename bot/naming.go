@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Pallinder/go-randomdata"
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+var (
+	placeholderRegex    = regexp.MustCompile(`\{([^{}]*)\}`)
+	repeatedDashesRegex = regexp.MustCompile(`-{2,}`)
+)
+
+// channelNameParams are the values the placeholders of a channel name template are replaced with.
+type channelNameParams struct {
+	VoiceChannelName string
+	OwnerName        string
+	Date             time.Time
+	Number           int
+}
+
+func (p channelNameParams) placeholderValue(placeholder string) string {
+	switch placeholder {
+	case consts.PlaceholderVoice:
+		return p.VoiceChannelName
+	case consts.PlaceholderOwner:
+		return p.OwnerName
+	case consts.PlaceholderDate:
+		return p.Date.Format("2006-01-02")
+	case consts.PlaceholderNumber:
+		return strconv.Itoa(p.Number)
+	case consts.PlaceholderSilly:
+		return randomdata.SillyName()
+	default:
+		return ""
+	}
+}
+
+// formatChannelName creates a valid channel name from the template.
+// An empty template creates a random silly name, as does a template whose result has no valid characters.
+func formatChannelName(template string, params channelNameParams) string {
+	if template == "" {
+		return sanitizeChannelName(randomdata.SillyName())
+	}
+
+	name := placeholderRegex.ReplaceAllStringFunc(template, func(placeholder string) string {
+		return params.placeholderValue(strings.Trim(placeholder, "{}"))
+	})
+
+	name = sanitizeChannelName(name)
+	if name == "" {
+		return sanitizeChannelName(randomdata.SillyName())
+	}
+
+	return name
+}
+
+// sanitizeChannelName converts a name to follow Discord's text channel name rules:
+// lowercase, no spaces or special characters, and at most 100 characters.
+func sanitizeChannelName(name string) string {
+	var builder strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_' || r == '-':
+			builder.WriteRune(r)
+		case unicode.IsSpace(r) || r == '.' || r == '/':
+			builder.WriteRune('-')
+		}
+	}
+
+	sanitized := strings.Trim(repeatedDashesRegex.ReplaceAllString(builder.String(), "-"), "-")
+
+	runes := []rune(sanitized)
+	if len(runes) > consts.MaxChannelNameLength {
+		sanitized = strings.Trim(string(runes[:consts.MaxChannelNameLength]), "-")
+	}
+
+	return sanitized
+}
+
+// validateChannelNameTemplate returns a user readable error if the template has unknown placeholders.
+func validateChannelNameTemplate(template string) error {
+	if len([]rune(template)) > consts.MaxChannelNameLength {
+		return fmt.Errorf("The template cannot be longer than %v characters", consts.MaxChannelNameLength)
+	}
+
+	for _, match := range placeholderRegex.FindAllStringSubmatch(template, -1) {
+		if !isValidPlaceholder(match[1]) {
+			return fmt.Errorf("Unknown placeholder %v, valid placeholders are: {%v}", match[0], strings.Join(consts.ValidPlaceholders, "}, {"))
+		}
+	}
+
+	return nil
+}
+
+func isValidPlaceholder(placeholder string) bool {
+	for _, validPlaceholder := range consts.ValidPlaceholders {
+		if placeholder == validPlaceholder {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newChannelNameParams collects the template values from the state.
+// Values that can't be found are left empty.
+func newChannelNameParams(session Session, guildID string, voiceChannelID state.DiscordID, ownerID state.DiscordID, number int) channelNameParams {
+	params := channelNameParams{
+		Date:   time.Now().UTC(),
+		Number: number,
+	}
+
+	voiceChannel, err := session.StateChannel(voiceChannelID.RESTAPIFormat())
+	if err == nil {
+		params.VoiceChannelName = voiceChannel.Name
+	}
+
+	member, err := session.StateMember(guildID, ownerID.RESTAPIFormat())
+	if err == nil {
+		params.OwnerName = memberDisplayName(member)
+	}
+
+	return params
+}
+
+func memberDisplayName(member *discordgo.Member) string {
+	if member.Nick != "" {
+		return member.Nick
+	}
+
+	if member.User == nil {
+		return ""
+	}
+
+	if member.User.GlobalName != "" {
+		return member.User.GlobalName
+	}
+
+	return member.User.Username
+}
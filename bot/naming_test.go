@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeChannelName(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "valid", input: "chat_1-a", expected: "chat_1-a"},
+		{name: "uppercase", input: "Game Night", expected: "game-night"},
+		{name: "spaces", input: "  late   night\tchat ", expected: "late-night-chat"},
+		{name: "dots and slashes", input: "v1.2/notes", expected: "v1-2-notes"},
+		{name: "punctuation is dropped", input: "what's up?! (#1)", expected: "whats-up-1"},
+		{name: "repeated dashes", input: "a--b - - c---", expected: "a-b-c"},
+		{name: "unicode letters are kept", input: "Ünïcode Чат 日本", expected: "ünïcode-чат-日本"},
+		{name: "emoji are dropped", input: "party 🎉 time", expected: "party-time"},
+		{name: "nothing valid", input: "!?* ...", expected: ""},
+		{name: "truncated to 100 runes", input: strings.Repeat("é", 150), expected: strings.Repeat("é", consts.MaxChannelNameLength)},
+		{name: "no trailing dash after truncation", input: strings.Repeat("a", 99) + " b", expected: strings.Repeat("a", 99)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, sanitizeChannelName(test.input))
+		})
+	}
+}
+
+func TestFormatChannelName(t *testing.T) {
+	params := channelNameParams{
+		VoiceChannelName: "Game Room",
+		OwnerName:        "Some.User",
+		Date:             time.Date(2021, 3, 4, 12, 0, 0, 0, time.UTC),
+		Number:           7,
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{name: "voice channel", template: "{voice}-chat", expected: "game-room-chat"},
+		{name: "owner", template: "{owner}", expected: "some-user"},
+		{name: "date and number", template: "{date} #{n}", expected: "2021-03-04-7"},
+		{name: "no placeholders", template: "Lobby", expected: "lobby"},
+		{name: "unknown placeholders are removed", template: "{unknown}-{voice}", expected: "game-room"},
+		{name: "unclosed placeholders are kept", template: "{voice", expected: "voice"},
+		{name: "long values are truncated", template: "{voice}" + strings.Repeat("x", consts.MaxChannelNameLength), expected: ("game-room" + strings.Repeat("x", consts.MaxChannelNameLength))[:consts.MaxChannelNameLength]},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, formatChannelName(test.template, params))
+		})
+	}
+}
+
+func TestFormatChannelNameFallsBackToSillyName(t *testing.T) {
+	for _, template := range []string{"", "{unknown}", "!!!", "{owner}"} {
+		t.Run(template, func(t *testing.T) {
+			name := formatChannelName(template, channelNameParams{})
+			assert.NotEmpty(t, name)
+			assert.Equal(t, sanitizeChannelName(name), name, "The silly name isn't a valid channel name")
+		})
+	}
+}
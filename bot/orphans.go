@@ -0,0 +1,219 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+// reconcileOrphans handles text channels in the server's temp categories that aren't tracked by the bot.
+// Orphans are left behind when the bot crashes before it gets to delete its channels.
+// Each orphan is handled according to the server's orphan channel policy, and the actions taken are reported to the command channel.
+func (b *TempChannelBot) reconcileOrphans(s Session, guild *discordgo.Guild) {
+	serverID, err := state.ParseDiscordID(guild.ID)
+	if err != nil {
+		log.Printf("Failed to parse server ID %q: %v", guild.ID, err)
+		return
+	}
+
+	serverData, serverIsSetup := b.store.Server(serverID)
+	if !serverIsSetup {
+		return
+	}
+
+	config := serverData.Config()
+	categoryIDs := config.TempChannelCategoryIDs()
+
+	report := []string{}
+	for _, channel := range guild.Channels {
+		if channel.Type != discordgo.ChannelTypeGuildText || !isTempChannelCategory(categoryIDs, channel.ParentID) {
+			continue
+		}
+
+		channelID, err := state.ParseDiscordID(channel.ID)
+		if err != nil {
+			log.Printf("Failed to parse channel ID %q: %v", channel.ID, err)
+			continue
+		}
+
+		if b.tempChannels.IsTempChannel(channelID) {
+			continue
+		}
+
+		action, err := b.handleOrphan(s, guild, serverID, channel, serverData.OrphanChannelPolicy())
+		if err != nil {
+			log.Printf("Failed to handle orphan channel %v in server %v: %v", channel.ID, guild.ID, err)
+			action = fmt.Sprintf("Failed to handle #%v", channel.Name)
+		}
+
+		report = append(report, action)
+	}
+
+	if len(report) == 0 {
+		return
+	}
+
+	log.Printf("Reconciled orphan channels in server %v: %v", guild.ID, strings.Join(report, ", "))
+
+	if serverData.HasCommandChannelID() {
+		message := backtickReplyFormatter.Format("Found channels in the temp category that weren't created by the bot:\n" + strings.Join(report, "\n"))
+		_, err := s.ChannelMessageSend(serverData.CommandChannelID().RESTAPIFormat(), message)
+		if err != nil {
+			log.Printf("Failed to report orphan channels to server %v: %v", guild.ID, err)
+		}
+	}
+}
+
+// handleOrphan applies the orphan channel policy to a single channel, and returns a description of what was done.
+func (b *TempChannelBot) handleOrphan(s Session, guild *discordgo.Guild, serverID state.DiscordID, channel *discordgo.Channel, policy string) (string, error) {
+	switch policy {
+	case consts.OrphanPolicyAdopt:
+		voiceChannelID := orphanVoiceChannel(guild, channel)
+		if voiceChannelID != state.DiscordIDNone {
+			_, alreadyHasTempChannel := b.tempChannels.GetTempChannelForVoiceChat(voiceChannelID)
+			if !alreadyHasTempChannel {
+				err := b.adoptOrphan(s, guild, serverID, channel, voiceChannelID)
+				if err != nil {
+					return "", err
+				}
+
+				return fmt.Sprintf("Adopted #%v as the temp channel of <#%v>", channel.Name, voiceChannelID), nil
+			}
+		}
+
+		err := b.repermissionOrphan(s, guild, channel)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("Couldn't find a voice chat for #%v, removed the access of its members", channel.Name), nil
+	case consts.OrphanPolicyDelete:
+		_, err := s.ChannelDelete(channel.ID)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("Deleted #%v", channel.Name), nil
+	default:
+		err := b.repermissionOrphan(s, guild, channel)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("Removed the access of the members of #%v", channel.Name), nil
+	}
+}
+
+// isTempChannelCategory returns whether the channel ID is of one of the categories temp channels are created in.
+func isTempChannelCategory(categoryIDs []state.DiscordID, channelID string) bool {
+	for _, categoryID := range categoryIDs {
+		if categoryID.Equals(channelID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// orphanVoiceChannel finds the voice chat most of the orphan's members are currently in.
+func orphanVoiceChannel(guild *discordgo.Guild, channel *discordgo.Channel) state.DiscordID {
+	memberIDs := map[string]bool{}
+	for _, overwrite := range channel.PermissionOverwrites {
+		if overwrite.Type == discordgo.PermissionOverwriteTypeMember && overwrite.Allow&discordgo.PermissionViewChannel != 0 {
+			memberIDs[overwrite.ID] = true
+		}
+	}
+
+	voiceChannelCounts := map[string]int{}
+	bestVoiceChannel := ""
+	for _, voiceState := range guild.VoiceStates {
+		if !memberIDs[voiceState.UserID] {
+			continue
+		}
+
+		voiceChannelCounts[voiceState.ChannelID]++
+		if voiceChannelCounts[voiceState.ChannelID] > voiceChannelCounts[bestVoiceChannel] {
+			bestVoiceChannel = voiceState.ChannelID
+		}
+	}
+
+	voiceChannelID, err := state.ParseDiscordID(bestVoiceChannel)
+	if err != nil {
+		return state.DiscordIDNone
+	}
+
+	return voiceChannelID
+}
+
+// adoptOrphan tracks the orphan as the temp channel of the voice chat, and syncs its members with the voice chat's participants.
+func (b *TempChannelBot) adoptOrphan(s Session, guild *discordgo.Guild, serverID state.DiscordID, channel *discordgo.Channel, voiceChannelID state.DiscordID) error {
+	channelID, err := state.ParseDiscordID(channel.ID)
+	if err != nil {
+		return err
+	}
+
+	createdAt, err := discordgo.SnowflakeTimestamp(channel.ID)
+	if err != nil {
+		return err
+	}
+
+	participants := map[string]bool{}
+	for _, voiceState := range guild.VoiceStates {
+		if voiceChannelID.Equals(voiceState.ChannelID) {
+			participants[voiceState.UserID] = true
+		}
+	}
+
+	for _, overwrite := range channel.PermissionOverwrites {
+		if overwrite.Type == discordgo.PermissionOverwriteTypeMember && b.botUserID.NotEquals(overwrite.ID) && !participants[overwrite.ID] {
+			err := s.ChannelPermissionDelete(channel.ID, overwrite.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	tempChannel := restoreTempChannel(s, &state.TempChannelData{
+		ChannelID:      channelID,
+		VoiceChannelID: voiceChannelID,
+		ServerID:       serverID,
+		CreatedAt:      createdAt.UTC(),
+	}, channel, historyVisibility(b.store, serverID, voiceChannelID))
+
+	for participant := range participants {
+		userID, err := state.ParseDiscordID(participant)
+		if err != nil {
+			return err
+		}
+
+		err = tempChannel.AllowUserAccess(userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.tempChannels.AddTempChannel(tempChannel)
+}
+
+// repermissionOrphan removes the access of all members of the orphan, and makes sure it's hidden from @everyone.
+func (b *TempChannelBot) repermissionOrphan(s Session, guild *discordgo.Guild, channel *discordgo.Channel) error {
+	for _, overwrite := range channel.PermissionOverwrites {
+		if overwrite.Type == discordgo.PermissionOverwriteTypeMember && b.botUserID.NotEquals(overwrite.ID) {
+			err := s.ChannelPermissionDelete(channel.ID, overwrite.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	everyoneRoleID, err := findEveryoneRoleID(guild)
+	if err != nil {
+		return err
+	}
+
+	return s.ChannelPermissionSet(channel.ID, everyoneRoleID, discordgo.PermissionOverwriteTypeRole, 0, discordgo.PermissionViewChannel)
+}
@@ -0,0 +1,25 @@
+package bot
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+func (s *BotTestSuite) TestOrphanKeptByDefault() {
+	s.setupServer()
+	orphan := s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildText, "orphan", s.category.ID,
+		&discordgo.PermissionOverwrite{ID: testUser1ID, Type: discordgo.PermissionOverwriteTypeMember, Allow: discordgo.PermissionViewChannel},
+	)
+
+	s.bot.GuildCreate(nil, &discordgo.GuildCreate{Guild: s.guild})
+	s.Contains(s.tempChannels(), orphan, "An orphan was deleted without the server choosing to")
+	s.False(s.canView(orphan, testUser1ID), "The orphan's members kept their access")
+}
+
+func (s *BotTestSuite) TestOrphanDeletePolicy() {
+	s.setupServer()
+	s.runCommand(testOwnerID, "!set-orphan-policy delete")
+	s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildText, "orphan", s.category.ID)
+
+	s.bot.GuildCreate(nil, &discordgo.GuildCreate{Guild: s.guild})
+	s.Empty(s.tempChannels())
+}
@@ -0,0 +1,218 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+// ownedTempChannel returns the temp channel the command was run in, if the author may control it.
+// Server administrators may control every temp channel.
+// Replies with the reason if the author may not.
+func (b *TempChannelBot) ownedTempChannel(context *CommandHandlerContext) (*TempChannel, bool) {
+	channelID, err := state.ParseDiscordID(context.ChannelID)
+	if err != nil {
+		log.Printf("Failed to parse channel ID %q: %v", context.ChannelID, err)
+		return nil, false
+	}
+
+	tempChannel, found := b.tempChannels.GetTempChannel(channelID)
+	if !found {
+		context.reply("This command can only be used inside a temp channel")
+		return nil, false
+	}
+
+	authorID, err := state.ParseDiscordID(context.AuthorID)
+	if err != nil {
+		log.Printf("Failed to parse author ID %q: %v", context.AuthorID, err)
+		return nil, false
+	}
+
+	if b.tempChannels.Owner(tempChannel) == authorID {
+		return tempChannel, true
+	}
+
+	permissions, err := context.userPermissions(authorID, "")
+	if err == nil && permissions&discordgo.PermissionAdministrator != 0 {
+		return tempChannel, true
+	}
+
+	context.reply("Only the owner of this temp channel may use this command")
+	return nil, false
+}
+
+// parseUserArg parses the single user argument of an owner command.
+func parseUserArg(context *CommandHandlerContext) (state.DiscordID, bool) {
+	if len(context.CommandArgs) < 1 {
+		context.reply("Missing user, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return state.DiscordIDNone, false
+	} else if len(context.CommandArgs) > 1 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return state.DiscordIDNone, false
+	}
+
+	userID, err := parseUserMention(context.CommandArgs[0])
+	if err != nil {
+		context.reply("Invalid user, please mention the user or use their ID")
+		return state.DiscordIDNone, false
+	}
+
+	return userID, true
+}
+
+func (b *TempChannelBot) renameHandler(context *CommandHandlerContext) error {
+	tempChannel, allowed := b.ownedTempChannel(context)
+	if !allowed {
+		return nil
+	}
+
+	if len(context.CommandArgs) < 1 {
+		context.reply("Missing channel name, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	name := sanitizeChannelName(strings.Join(context.CommandArgs, " "))
+	if name == "" {
+		context.reply("Invalid channel name, please use letters or numbers")
+		return nil
+	}
+
+	err := b.tempChannels.RenameTempChannel(tempChannel, name)
+	if err != nil {
+		context.reply("Failed to rename the channel, please make sure the bot has the right permissions")
+		log.Printf("Couldn't rename temp channel %v: %v", tempChannel.channelID, err)
+		return nil
+	}
+
+	context.reply("The channel was renamed to %v", name)
+	return nil
+}
+
+func (b *TempChannelBot) lockHandler(context *CommandHandlerContext) error {
+	tempChannel, allowed := b.ownedTempChannel(context)
+	if !allowed {
+		return nil
+	}
+
+	if b.tempChannels.IsLocked(tempChannel) {
+		context.reply("The channel is already locked")
+		return nil
+	}
+
+	b.tempChannels.SetLocked(tempChannel, true)
+	context.reply("The channel is locked, users that join the voice chat will have to be invited")
+	return nil
+}
+
+func (b *TempChannelBot) unlockHandler(context *CommandHandlerContext) error {
+	tempChannel, allowed := b.ownedTempChannel(context)
+	if !allowed {
+		return nil
+	}
+
+	if !b.tempChannels.IsLocked(tempChannel) {
+		context.reply("The channel isn't locked")
+		return nil
+	}
+
+	b.tempChannels.SetLocked(tempChannel, false)
+	context.reply("The channel is unlocked, users that join the voice chat will get access to it")
+	return nil
+}
+
+func (b *TempChannelBot) inviteHandler(context *CommandHandlerContext) error {
+	tempChannel, allowed := b.ownedTempChannel(context)
+	if !allowed {
+		return nil
+	}
+
+	userID, valid := parseUserArg(context)
+	if !valid {
+		return nil
+	}
+
+	if !context.memberExists(userID) {
+		context.reply("This user isn't a member of the server")
+		return nil
+	}
+
+	if userTempChannel, found := b.tempChannels.GetTempChannelForUser(userID); found {
+		if userTempChannel == tempChannel {
+			context.reply("This user already has access to the channel")
+		} else {
+			context.reply("This user is in another temp channel's voice chat")
+		}
+		return nil
+	}
+
+	err := b.tempChannels.InviteUser(tempChannel, userID)
+	if err != nil {
+		context.reply("Failed to invite the user, please make sure the bot has the right permissions")
+		log.Printf("Couldn't invite user %v to temp channel %v: %v", userID, tempChannel.channelID, err)
+		return nil
+	}
+
+	context.replyUnformatted(fmt.Sprintf("`Invited` <@%v>", userID))
+	return nil
+}
+
+func (b *TempChannelBot) kickHandler(context *CommandHandlerContext) error {
+	tempChannel, allowed := b.ownedTempChannel(context)
+	if !allowed {
+		return nil
+	}
+
+	userID, valid := parseUserArg(context)
+	if !valid {
+		return nil
+	}
+
+	if userID == b.tempChannels.Owner(tempChannel) {
+		context.reply("The owner can't be kicked, please use %vtransfer first", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	if userTempChannel, found := b.tempChannels.GetTempChannelForUser(userID); !found || userTempChannel != tempChannel {
+		context.reply("This user doesn't have access to the channel")
+		return nil
+	}
+
+	err := b.tempChannels.KickUser(tempChannel, userID)
+	if err != nil {
+		context.reply("Failed to kick the user, please make sure the bot has the right permissions")
+		log.Printf("Couldn't kick user %v from temp channel %v: %v", userID, tempChannel.channelID, err)
+		return nil
+	}
+
+	context.reply("The user was kicked from the channel")
+	return nil
+}
+
+func (b *TempChannelBot) transferHandler(context *CommandHandlerContext) error {
+	tempChannel, allowed := b.ownedTempChannel(context)
+	if !allowed {
+		return nil
+	}
+
+	userID, valid := parseUserArg(context)
+	if !valid {
+		return nil
+	}
+
+	if userID == b.tempChannels.Owner(tempChannel) {
+		context.reply("This user already owns the channel")
+		return nil
+	}
+
+	if userTempChannel, found := b.tempChannels.GetTempChannelForUser(userID); !found || userTempChannel != tempChannel {
+		context.reply("The new owner must have access to the channel")
+		return nil
+	}
+
+	b.tempChannels.SetOwner(tempChannel, userID)
+	context.replyUnformatted(fmt.Sprintf("<@%v> `now owns the channel`", userID))
+	return nil
+}
@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// basePermissions computes the server wide permissions of a member, following Discord's permission hierarchy:
+// the @everyone role's permissions, combined with the permissions of all of the member's roles.
+// The server owner and members with the "Administrator" permission have all permissions.
+// Roles that can't be found are skipped, as the state may lag behind a role deletion.
+func basePermissions(guild *discordgo.Guild, member *discordgo.Member) int64 {
+	if member.User != nil && member.User.ID == guild.OwnerID {
+		return discordgo.PermissionAll
+	}
+
+	roles := make(map[string]*discordgo.Role, len(guild.Roles))
+	for _, role := range guild.Roles {
+		roles[role.ID] = role
+	}
+
+	var permissions int64
+	// The ID of the @everyone role is the ID of the server
+	if everyone, found := roles[guild.ID]; found {
+		permissions = everyone.Permissions
+	}
+
+	for _, roleID := range member.Roles {
+		if role, found := roles[roleID]; found {
+			permissions |= role.Permissions
+		}
+	}
+
+	if permissions&discordgo.PermissionAdministrator != 0 {
+		return discordgo.PermissionAll
+	}
+
+	return permissions
+}
+
+// applyOverwrites applies the channel's permission overwrites to the member's base permissions.
+// Overwrites are applied in Discord's order: the @everyone overwrite, then all role overwrites together (denies before allows),
+// and finally the member's own overwrite.
+func applyOverwrites(base int64, guild *discordgo.Guild, member *discordgo.Member, channel *discordgo.Channel) int64 {
+	if base&discordgo.PermissionAdministrator != 0 {
+		return discordgo.PermissionAll
+	}
+
+	memberRoles := make(map[string]bool, len(member.Roles))
+	for _, roleID := range member.Roles {
+		memberRoles[roleID] = true
+	}
+
+	memberID := ""
+	if member.User != nil {
+		memberID = member.User.ID
+	}
+
+	permissions := base
+	var roleAllow, roleDeny int64
+	var memberOverwrite *discordgo.PermissionOverwrite
+
+	for _, overwrite := range channel.PermissionOverwrites {
+		switch {
+		case overwrite.Type == discordgo.PermissionOverwriteTypeRole && overwrite.ID == guild.ID:
+			permissions &^= overwrite.Deny
+			permissions |= overwrite.Allow
+		case overwrite.Type == discordgo.PermissionOverwriteTypeRole && memberRoles[overwrite.ID]:
+			roleAllow |= overwrite.Allow
+			roleDeny |= overwrite.Deny
+		case overwrite.Type == discordgo.PermissionOverwriteTypeMember && overwrite.ID == memberID:
+			memberOverwrite = overwrite
+		}
+	}
+
+	permissions &^= roleDeny
+	permissions |= roleAllow
+
+	if memberOverwrite != nil {
+		permissions &^= memberOverwrite.Deny
+		permissions |= memberOverwrite.Allow
+	}
+
+	return permissions
+}
+
+// resolvePermissions computes the permissions of a member in a channel, or in the server if the channel is nil.
+func resolvePermissions(guild *discordgo.Guild, member *discordgo.Member, channel *discordgo.Channel) int64 {
+	permissions := basePermissions(guild, member)
+	if channel == nil {
+		return permissions
+	}
+
+	return applyOverwrites(permissions, guild, member, channel)
+}
@@ -0,0 +1,236 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	permissionsGuildID  = "1"
+	permissionsOwnerID  = "2"
+	permissionsMemberID = "3"
+	permissionsRole1ID  = "4"
+	permissionsRole2ID  = "5"
+	// A role the member has that was deleted, but not yet removed from the member in the state
+	permissionsDeletedRoleID = "6"
+)
+
+func permissionsGuild() *discordgo.Guild {
+	return &discordgo.Guild{
+		ID:      permissionsGuildID,
+		OwnerID: permissionsOwnerID,
+		Roles: []*discordgo.Role{
+			{ID: permissionsGuildID, Permissions: discordgo.PermissionViewChannel | discordgo.PermissionSendMessages},
+			{ID: permissionsRole1ID, Permissions: discordgo.PermissionManageChannels},
+			{ID: permissionsRole2ID, Permissions: discordgo.PermissionManageRoles},
+		},
+	}
+}
+
+func permissionsMember(userID string, roles ...string) *discordgo.Member {
+	return &discordgo.Member{User: &discordgo.User{ID: userID}, Roles: roles}
+}
+
+func roleOverwrite(roleID string, allow int64, deny int64) *discordgo.PermissionOverwrite {
+	return &discordgo.PermissionOverwrite{ID: roleID, Type: discordgo.PermissionOverwriteTypeRole, Allow: allow, Deny: deny}
+}
+
+func memberOverwrite(userID string, allow int64, deny int64) *discordgo.PermissionOverwrite {
+	return &discordgo.PermissionOverwrite{ID: userID, Type: discordgo.PermissionOverwriteTypeMember, Allow: allow, Deny: deny}
+}
+
+func TestBasePermissions(t *testing.T) {
+	everyone := int64(discordgo.PermissionViewChannel | discordgo.PermissionSendMessages)
+	tests := []struct {
+		name     string
+		member   *discordgo.Member
+		roles    []*discordgo.Role
+		expected int64
+	}{
+		{
+			name:     "@everyone only",
+			member:   permissionsMember(permissionsMemberID),
+			expected: everyone,
+		},
+		{
+			name:     "roles are combined",
+			member:   permissionsMember(permissionsMemberID, permissionsRole1ID, permissionsRole2ID),
+			expected: everyone | discordgo.PermissionManageChannels | discordgo.PermissionManageRoles,
+		},
+		{
+			name:     "missing roles are skipped",
+			member:   permissionsMember(permissionsMemberID, permissionsDeletedRoleID, permissionsRole1ID),
+			expected: everyone | discordgo.PermissionManageChannels,
+		},
+		{
+			name:     "owner",
+			member:   permissionsMember(permissionsOwnerID),
+			expected: discordgo.PermissionAll,
+		},
+		{
+			name:   "administrator role",
+			member: permissionsMember(permissionsMemberID, permissionsRole1ID),
+			roles: []*discordgo.Role{
+				{ID: permissionsRole1ID, Permissions: discordgo.PermissionAdministrator},
+			},
+			expected: discordgo.PermissionAll,
+		},
+		{
+			name:   "no @everyone role",
+			member: permissionsMember(permissionsMemberID, permissionsRole1ID),
+			roles: []*discordgo.Role{
+				{ID: permissionsRole1ID, Permissions: discordgo.PermissionManageChannels},
+			},
+			expected: discordgo.PermissionManageChannels,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			guild := permissionsGuild()
+			if test.roles != nil {
+				guild.Roles = test.roles
+			}
+
+			assert.Equal(t, test.expected, basePermissions(guild, test.member))
+		})
+	}
+}
+
+func TestApplyOverwrites(t *testing.T) {
+	base := int64(discordgo.PermissionViewChannel | discordgo.PermissionSendMessages)
+	tests := []struct {
+		name       string
+		base       int64
+		member     *discordgo.Member
+		overwrites []*discordgo.PermissionOverwrite
+		expected   int64
+	}{
+		{
+			name:     "no overwrites",
+			base:     base,
+			member:   permissionsMember(permissionsMemberID),
+			expected: base,
+		},
+		{
+			name:   "@everyone overwrite",
+			base:   base,
+			member: permissionsMember(permissionsMemberID),
+			overwrites: []*discordgo.PermissionOverwrite{
+				roleOverwrite(permissionsGuildID, discordgo.PermissionAttachFiles, discordgo.PermissionViewChannel),
+			},
+			expected: discordgo.PermissionSendMessages | discordgo.PermissionAttachFiles,
+		},
+		{
+			name:   "a role's allow beats another role's deny",
+			base:   base,
+			member: permissionsMember(permissionsMemberID, permissionsRole1ID, permissionsRole2ID),
+			overwrites: []*discordgo.PermissionOverwrite{
+				roleOverwrite(permissionsRole1ID, discordgo.PermissionViewChannel, 0),
+				roleOverwrite(permissionsRole2ID, 0, discordgo.PermissionViewChannel),
+			},
+			expected: base,
+		},
+		{
+			name:   "role overwrites apply after the @everyone overwrite",
+			base:   base,
+			member: permissionsMember(permissionsMemberID, permissionsRole1ID),
+			overwrites: []*discordgo.PermissionOverwrite{
+				roleOverwrite(permissionsRole1ID, discordgo.PermissionViewChannel, 0),
+				roleOverwrite(permissionsGuildID, 0, discordgo.PermissionViewChannel),
+			},
+			expected: base,
+		},
+		{
+			name:   "overwrites of roles the member doesn't have are ignored",
+			base:   base,
+			member: permissionsMember(permissionsMemberID, permissionsRole1ID),
+			overwrites: []*discordgo.PermissionOverwrite{
+				roleOverwrite(permissionsRole2ID, 0, discordgo.PermissionViewChannel),
+			},
+			expected: base,
+		},
+		{
+			name:   "the member's overwrite beats role overwrites",
+			base:   base,
+			member: permissionsMember(permissionsMemberID, permissionsRole1ID),
+			overwrites: []*discordgo.PermissionOverwrite{
+				memberOverwrite(permissionsMemberID, 0, discordgo.PermissionSendMessages),
+				roleOverwrite(permissionsRole1ID, discordgo.PermissionSendMessages, 0),
+			},
+			expected: discordgo.PermissionViewChannel,
+		},
+		{
+			name:   "other members' overwrites are ignored",
+			base:   base,
+			member: permissionsMember(permissionsMemberID),
+			overwrites: []*discordgo.PermissionOverwrite{
+				memberOverwrite(permissionsOwnerID, 0, discordgo.PermissionViewChannel),
+			},
+			expected: base,
+		},
+		{
+			name:   "administrators ignore overwrites",
+			base:   discordgo.PermissionAdministrator,
+			member: permissionsMember(permissionsMemberID),
+			overwrites: []*discordgo.PermissionOverwrite{
+				roleOverwrite(permissionsGuildID, 0, discordgo.PermissionViewChannel),
+				memberOverwrite(permissionsMemberID, 0, discordgo.PermissionViewChannel),
+			},
+			expected: discordgo.PermissionAll,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			channel := &discordgo.Channel{PermissionOverwrites: test.overwrites}
+			assert.Equal(t, test.expected, applyOverwrites(test.base, permissionsGuild(), test.member, channel))
+		})
+	}
+}
+
+func TestResolvePermissions(t *testing.T) {
+	hidden := &discordgo.Channel{PermissionOverwrites: []*discordgo.PermissionOverwrite{
+		roleOverwrite(permissionsGuildID, 0, discordgo.PermissionViewChannel),
+		roleOverwrite(permissionsRole1ID, discordgo.PermissionViewChannel, 0),
+	}}
+
+	tests := []struct {
+		name     string
+		member   *discordgo.Member
+		channel  *discordgo.Channel
+		expected int64
+	}{
+		{
+			name:     "server permissions without a channel",
+			member:   permissionsMember(permissionsMemberID),
+			expected: discordgo.PermissionViewChannel | discordgo.PermissionSendMessages,
+		},
+		{
+			name:     "denied by the @everyone overwrite",
+			member:   permissionsMember(permissionsMemberID),
+			channel:  hidden,
+			expected: discordgo.PermissionSendMessages,
+		},
+		{
+			name:     "allowed back by a role overwrite",
+			member:   permissionsMember(permissionsMemberID, permissionsRole1ID),
+			channel:  hidden,
+			expected: discordgo.PermissionViewChannel | discordgo.PermissionSendMessages | discordgo.PermissionManageChannels,
+		},
+		{
+			name:     "the owner ignores overwrites",
+			member:   permissionsMember(permissionsOwnerID),
+			channel:  hidden,
+			expected: discordgo.PermissionAll,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, resolvePermissions(permissionsGuild(), test.member, test.channel))
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+// permissionNames maps the names admins give to permission rules to their permission bits.
+var permissionNames = map[string]int64{
+	"administrator":    discordgo.PermissionAdministrator,
+	"manage_server":    discordgo.PermissionManageServer,
+	"manage_channels":  discordgo.PermissionManageChannels,
+	"manage_roles":     discordgo.PermissionManageRoles,
+	"manage_messages":  discordgo.PermissionManageMessages,
+	"kick_members":     discordgo.PermissionKickMembers,
+	"ban_members":      discordgo.PermissionBanMembers,
+	"moderate_members": discordgo.PermissionModerateMembers,
+	"move_members":     discordgo.PermissionVoiceMoveMembers,
+	"mute_members":     discordgo.PermissionVoiceMuteMembers,
+}
+
+// sortedPermissionNames returns the names of permissionNames in alphabetical order.
+func sortedPermissionNames() []string {
+	names := make([]string, 0, len(permissionNames))
+	for name := range permissionNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// parsePermissionNames converts names joined by "+" (e.g. manage_channels+move_members) to permission bits.
+func parsePermissionNames(value string) (int64, bool) {
+	var permissions int64
+	for _, name := range strings.Split(strings.ToLower(value), "+") {
+		permission, found := permissionNames[name]
+		if !found {
+			return 0, false
+		}
+
+		permissions |= permission
+	}
+
+	return permissions, true
+}
+
+// formatPermissionNames converts permission bits back to the names given by the admin.
+func formatPermissionNames(permissions int64) string {
+	names := []string{}
+	for _, name := range sortedPermissionNames() {
+		if permissions&permissionNames[name] == permissionNames[name] {
+			names = append(names, name)
+		}
+	}
+
+	return strings.Join(names, "+")
+}
+
+// commandRules returns the permission rules of a single command.
+func commandRules(serverData state.ServerData, commandName string) []state.CommandPermissionRule {
+	if serverData == nil {
+		return nil
+	}
+
+	rules := []state.CommandPermissionRule{}
+	for _, rule := range serverData.CommandPermissionRules() {
+		if rule.Command == commandName {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
+
+// ruleMatches tells whether a rule allows the member to run its command.
+func ruleMatches(rule state.CommandPermissionRule, userID state.DiscordID, roleIDs []string, permissions int64) bool {
+	switch rule.Type {
+	case consts.PermissionRuleRole:
+		for _, roleID := range roleIDs {
+			if rule.TargetID.Equals(roleID) {
+				return true
+			}
+		}
+
+		return false
+	case consts.PermissionRuleUser:
+		return rule.TargetID == userID
+	case consts.PermissionRulePermission:
+		return permissions&rule.Permissions == rule.Permissions
+	default:
+		return false
+	}
+}
+
+// canRunCommand tells whether the author may run the command, and replies with the reason if they may not.
+// Commands without rules keep their defaults: admin only commands require the "Administrator" permission, the rest are open to everyone.
+// Commands with rules may be run by anyone matching at least one of them.
+// Permission rules are checked against the author's permissions in the channel the command was run in.
+// The server owner and administrators may run every command, so a server can't be locked out of its own configuration.
+func (c *CommandHandlerContext) canRunCommand(command *Command) bool {
+	rules := commandRules(c.ServerData, c.CommandName)
+	if len(rules) == 0 && !command.AdminOnly {
+		return true
+	}
+
+	authorID, err := state.ParseDiscordID(c.AuthorID)
+	if err != nil {
+		log.Printf("Failed to parse author ID %q: %v", c.AuthorID, err)
+		return false
+	}
+
+	permissions, err := c.userPermissions(authorID, c.ChannelID)
+	if err != nil {
+		log.Printf("Failed to get the permissions of %v: %v", authorID, err)
+		c.reply("An internal error has occurred")
+		return false
+	}
+
+	if permissions&discordgo.PermissionAdministrator != 0 {
+		return true
+	}
+
+	if len(rules) == 0 {
+		c.reply(`You must have "Administrator" permissions in order to run this command`)
+		return false
+	}
+
+	member, err := c.Session.StateMember(c.GuildID, c.AuthorID)
+	if err != nil {
+		log.Printf("Failed to find member %v: %v", authorID, err)
+		c.reply("An internal error has occurred")
+		return false
+	}
+
+	for _, rule := range rules {
+		if ruleMatches(rule, authorID, member.Roles, permissions) {
+			return true
+		}
+	}
+
+	c.reply("You don't have permission to run this command")
+	return false
+}
+
+// parseRuleTarget converts a role mention, user mention, ID or permission names to a rule of the command.
+// A raw ID is treated as a role if the server has a role with that ID, and as a user otherwise.
+func (c *CommandHandlerContext) parseRuleTarget(commandName string, target string) (state.CommandPermissionRule, bool) {
+	rule := state.CommandPermissionRule{Command: commandName}
+
+	permissions, isPermission := parsePermissionNames(target)
+	if isPermission {
+		rule.Type = consts.PermissionRulePermission
+		rule.Permissions = permissions
+		return rule, true
+	}
+
+	rule.Type = consts.PermissionRuleUser
+	if strings.HasPrefix(target, "<@&") && strings.HasSuffix(target, ">") {
+		rule.Type = consts.PermissionRuleRole
+		target = strings.TrimSuffix(strings.TrimPrefix(target, "<@&"), ">")
+	} else if _, err := c.Session.StateRole(c.GuildID, target); err == nil {
+		rule.Type = consts.PermissionRuleRole
+	}
+
+	targetID, err := parseUserMention(target)
+	if err != nil {
+		return rule, false
+	}
+
+	rule.TargetID = targetID
+	return rule, true
+}
+
+// describeRule returns a readable description of the rule's target.
+func (c *CommandHandlerContext) describeRule(rule state.CommandPermissionRule) string {
+	switch rule.Type {
+	case consts.PermissionRuleRole:
+		role, err := c.Session.StateRole(c.GuildID, rule.TargetID.RESTAPIFormat())
+		if err != nil {
+			return fmt.Sprintf("role %v (deleted)", rule.TargetID)
+		}
+
+		return fmt.Sprintf("role @%v", role.Name)
+	case consts.PermissionRuleUser:
+		member, err := c.Session.StateMember(c.GuildID, rule.TargetID.RESTAPIFormat())
+		if err != nil {
+			return fmt.Sprintf("user %v", rule.TargetID)
+		}
+
+		return fmt.Sprintf("user %v", memberDisplayName(member))
+	default:
+		return fmt.Sprintf("members with %v", formatPermissionNames(rule.Permissions))
+	}
+}
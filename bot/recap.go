@@ -0,0 +1,164 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+// postRecap re-posts the temp channel's recent messages for a member that just got access to it, if its history mode is recap.
+// Only the last page of messages is recapped, and messages of bots, including earlier recaps, are left out.
+// Failures are only logged, the member has access to the channel either way.
+func (c *TempChannel) postRecap(userID state.DiscordID) {
+	if c.history.Mode != consts.HistoryModeRecap {
+		return
+	}
+
+	messages, err := c.session.ChannelMessages(c.channel.ID, consts.MaxMessagesPerRequest, "", "", "")
+	if err != nil {
+		log.Printf("Failed fetching the messages of %v for a recap: %v", c.channelID, err)
+		return
+	}
+
+	cutoff := time.Now().Add(-c.history.RecapPeriod)
+	lines := []string{}
+	// Messages are ordered from newest to oldest
+	for i := len(messages) - 1; i >= 0; i-- {
+		message := messages[i]
+		if message.Author == nil || message.Author.Bot || message.Timestamp.Before(cutoff) {
+			continue
+		}
+
+		lines = append(lines, recapLine(message))
+	}
+
+	if len(lines) == 0 {
+		return
+	}
+
+	header := fmt.Sprintf("<@%v>, here's what was sent in the last %v:", userID, formatRecapPeriod(c.history.RecapPeriod))
+	for _, content := range splitMessage(header, lines) {
+		_, err := c.session.ChannelMessageSendComplex(c.channel.ID, &discordgo.MessageSend{
+			Content:         content,
+			AllowedMentions: &discordgo.MessageAllowedMentions{Users: []string{userID.RESTAPIFormat()}},
+		})
+		if err != nil {
+			log.Printf("Failed posting a recap to %v: %v", c.channelID, err)
+			return
+		}
+	}
+}
+
+func recapLine(message *discordgo.Message) string {
+	line := fmt.Sprintf("**%v**: %v", message.Author.Username, message.Content)
+	for _, attachment := range message.Attachments {
+		line += " " + attachment.URL
+	}
+
+	return line
+}
+
+func formatRecapPeriod(period time.Duration) string {
+	minutes := int(period / time.Minute)
+	if minutes == 1 {
+		return "minute"
+	}
+
+	return fmt.Sprintf("%v minutes", minutes)
+}
+
+// splitMessage joins the header and lines into as few messages as Discord's message length allows.
+// Lines that are too long for a message on their own are cut.
+// Lengths are counted in characters, as Discord counts them, so multi-byte characters are never cut in half.
+func splitMessage(header string, lines []string) []string {
+	messages := []string{}
+	current := header
+	currentLength := utf8.RuneCountInString(current)
+	for _, line := range lines {
+		lineLength := utf8.RuneCountInString(line)
+		if lineLength > consts.MaxMessageLength {
+			line = string([]rune(line)[:consts.MaxMessageLength])
+			lineLength = consts.MaxMessageLength
+		}
+
+		if currentLength+len("\n")+lineLength <= consts.MaxMessageLength {
+			current += "\n" + line
+			currentLength += len("\n") + lineLength
+			continue
+		}
+
+		messages = append(messages, current)
+		current = line
+		currentLength = lineLength
+	}
+
+	return append(messages, current)
+}
+
+// parseHistoryArgs parses the arguments of the commands that set a history visibility:
+// a mode, followed by a number of minutes or a duration such as 1h for the recap mode.
+// Returns a message explaining the problem if the arguments are invalid.
+func (c *CommandHandlerContext) parseHistoryArgs(args []string) (state.HistoryVisibility, string) {
+	mode := strings.ToLower(args[0])
+	if !isValidHistoryMode(mode) {
+		return state.HistoryVisibility{}, fmt.Sprintf("Invalid history mode, please use one of the following: %v", strings.Join(consts.ValidHistoryModes, ", "))
+	}
+
+	if mode != consts.HistoryModeRecap {
+		if len(args) > 1 {
+			return state.HistoryVisibility{}, fmt.Sprintf("Too many arguments, please check %vhelp to see how to use the command", c.ServerData.CommandPrefix())
+		}
+
+		return state.HistoryVisibility{Mode: mode}, ""
+	}
+
+	if len(args) != 2 {
+		return state.HistoryVisibility{}, fmt.Sprintf("Please specify how far back the recap goes, e.g. %v 15 for the last 15 minutes", consts.HistoryModeRecap)
+	}
+
+	period, err := parseRecapPeriod(args[1])
+	if err != nil || period < time.Minute || period%time.Minute != 0 {
+		return state.HistoryVisibility{}, "Invalid recap period, please use a number of minutes or a duration such as 1h"
+	}
+
+	if period > consts.MaxHistoryRecapPeriod {
+		return state.HistoryVisibility{}, fmt.Sprintf("The recap cannot go back more than %v", consts.MaxHistoryRecapPeriod)
+	}
+
+	return state.HistoryVisibility{Mode: mode, RecapPeriod: period}, ""
+}
+
+// parseRecapPeriod parses either a whole number of minutes, or a Go duration string.
+func parseRecapPeriod(value string) (time.Duration, error) {
+	minutes, err := strconv.Atoi(value)
+	if err == nil {
+		return time.Duration(minutes) * time.Minute, nil
+	}
+
+	return time.ParseDuration(value)
+}
+
+func isValidHistoryMode(mode string) bool {
+	for _, validMode := range consts.ValidHistoryModes {
+		if mode == validMode {
+			return true
+		}
+	}
+
+	return false
+}
+
+func describeHistoryVisibility(visibility state.HistoryVisibility) string {
+	if visibility.Mode != consts.HistoryModeRecap {
+		return visibility.Mode
+	}
+
+	return fmt.Sprintf("%v of the last %v", visibility.Mode, formatRecapPeriod(visibility.RecapPeriod))
+}
@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// canReadHistory tells whether the user can read the messages sent to the channel before they joined.
+func (s *BotTestSuite) canReadHistory(channel *discordgo.Channel, userID string) bool {
+	member, err := s.session.StateMember(s.guild.ID, userID)
+	s.Require().NoError(err)
+	return resolvePermissions(s.guild, member, channel)&discordgo.PermissionReadMessageHistory != 0
+}
+
+func (s *BotTestSuite) TestHistoryRecap() {
+	s.setupServer()
+	s.Contains(s.runCommand(testOwnerID, "!set-history recap 10"), "recap of the last 10 minutes")
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+
+	oldMessage, err := s.session.SendMessage(tempChannel.ID, testUser1ID, "an old message")
+	s.Require().NoError(err)
+	oldMessage.Timestamp = time.Now().Add(-time.Hour)
+	_, err = s.session.SendMessage(tempChannel.ID, testUser1ID, "https://example.com/link")
+	s.Require().NoError(err)
+
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	s.False(s.canReadHistory(tempChannel, testUser2ID), "The newcomer can read the history instead of getting a recap")
+
+	messages := s.session.Messages(tempChannel.ID)
+	recap := messages[len(messages)-1]
+	s.Equal(testBotUserID, recap.Author.ID)
+	s.Contains(recap.Content, "<@"+testUser2ID+">, here's what was sent in the last 10 minutes:")
+	s.Contains(recap.Content, "https://example.com/link")
+	s.NotContains(recap.Content, "an old message", "A message older than the recap period was recapped")
+
+	s.joinVoice(testUser3ID, s.voiceChannel1)
+	messages = s.session.Messages(tempChannel.ID)
+	s.Equal(1, strings.Count(messages[len(messages)-1].Content, "https://example.com/link"), "An earlier recap was recapped")
+}
+
+func (s *BotTestSuite) TestRecapPostedOnce() {
+	s.setupServer()
+	s.runCommand(testOwnerID, "!set-history recap 10")
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+	_, err := s.session.SendMessage(tempChannel.ID, testUser1ID, "a recent message")
+	s.Require().NoError(err)
+
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	// Muting or streaming sends the same voice state again
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+
+	recaps := 0
+	for _, message := range s.session.Messages(tempChannel.ID) {
+		if strings.Contains(message.Content, "<@"+testUser2ID+">, here's what was sent") {
+			recaps++
+		}
+	}
+	s.Equal(1, recaps, "The recap was posted again without the user rejoining")
+}
+
+func (s *BotTestSuite) TestHistoryVisible() {
+	s.setupServer()
+	s.runCommand(testOwnerID, "!set-history visible")
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	tempChannel := s.requireTempChannel()
+	s.Contains(s.runCommandIn(tempChannel, testUser1ID, "!invite "+testUser3ID), "Invited")
+
+	s.True(s.canReadHistory(tempChannel, testUser1ID))
+	s.True(s.canReadHistory(tempChannel, testUser3ID), "An invited user can't read the history")
+}
+
+func (s *BotTestSuite) TestSetHistory() {
+	serverData := s.setupServer()
+	s.Contains(s.runCommand(testOwnerID, "!set-history"), "already hidden")
+	s.Contains(s.runCommand(testOwnerID, "!set-history shown"), "Invalid history mode")
+	s.Contains(s.runCommand(testOwnerID, "!set-history recap"), "Please specify how far back the recap goes")
+	s.Contains(s.runCommand(testOwnerID, "!set-history recap 0"), "Invalid recap period")
+	s.Contains(s.runCommand(testOwnerID, "!set-history recap 48h"), "The recap cannot go back more than")
+	s.Contains(s.runCommand(testOwnerID, "!set-history visible 5"), "Too many arguments")
+
+	s.runCommand(testOwnerID, "!set-history recap 1h")
+	s.Equal(time.Hour, serverData.Config().HistoryVisibility.RecapPeriod)
+
+	s.Contains(s.runCommand(testOwnerID, "!set-history"), "will be hidden")
+	s.Equal("hidden", serverData.Config().HistoryVisibility.Mode)
+}
+
+func (s *BotTestSuite) TestVoicePolicyOverridesHistory() {
+	s.setupServer()
+	s.runCommand(testOwnerID, "!set-history visible")
+	s.runCommand(testOwnerID, "!voice-policy "+s.voiceChannel1.ID+" history hidden")
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	s.False(s.canReadHistory(s.requireTempChannel(), testUser1ID), "The voice channel's history mode didn't override the server's")
+}
+
+func (s *BotTestSuite) TestSplitMessage() {
+	lines := []string{strings.Repeat("a", 1500), strings.Repeat("b", 1500), strings.Repeat("c", 3000)}
+
+	messages := splitMessage("header", lines)
+	s.Len(messages, 3)
+	for _, message := range messages {
+		s.LessOrEqual(len(message), 2000)
+	}
+	s.True(strings.HasPrefix(messages[0], "header\naaa"))
+}
+
+func (s *BotTestSuite) TestSplitMessageCountsCharacters() {
+	lines := []string{strings.Repeat("é", 1500), strings.Repeat("日", 3000)}
+
+	messages := splitMessage("header", lines)
+	s.Require().Len(messages, 2)
+	s.Equal("header\n"+lines[0], messages[0], "A line that fits in characters was moved to another message")
+	s.Equal(strings.Repeat("日", 2000), messages[1])
+	for _, message := range messages {
+		s.True(utf8.ValidString(message), "A character was cut in half")
+	}
+}
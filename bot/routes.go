@@ -0,0 +1,159 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+func (b *TempChannelBot) routeAddHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) < 2 {
+		context.reply("Missing arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	} else if len(context.CommandArgs) > 2 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	sourceID, err := state.ParseDiscordID(context.CommandArgs[0])
+	if err != nil || (!context.voiceChannelExists(context.CommandArgs[0]) && !context.categoryExists(context.CommandArgs[0])) {
+		context.reply(`The voice channel or category doesn't exist, please right click it and click "Copy ID"`)
+		return nil
+	}
+
+	categoryID, problem := context.checkTempChannelCategory(context.CommandArgs[1])
+	if problem != "" {
+		context.reply("%v", problem)
+		return nil
+	}
+
+	// Untracked text channels in temp categories are handled as orphans on startup
+	if categoryID != context.ServerData.TempChannelCategoryID() && b.hasUntrackedTextChannels(context, categoryID) {
+		context.reply("%v has text channels that weren't created by the bot, please route temp channels to a category of their own", context.channelName(categoryID))
+		return nil
+	}
+
+	route := state.CategoryRoute{SourceID: sourceID, CategoryID: categoryID}
+	for _, existingRoute := range context.ServerData.Config().CategoryRoutes {
+		if existingRoute == route {
+			context.reply("The temp channels of %v are already created in %v", context.describeRouteSource(sourceID), context.channelName(categoryID))
+			return nil
+		}
+	}
+
+	err = context.ServerData.Update(func(config *state.ServerConfig) error {
+		config.SetCategoryRoute(route)
+		return nil
+	})
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("Update failed: %w", err)
+	}
+
+	context.reply("The temp channels of %v will be created in %v", context.describeRouteSource(sourceID), context.channelName(categoryID))
+	return nil
+}
+
+// hasUntrackedTextChannels returns whether the category has text channels that aren't temp channels.
+func (b *TempChannelBot) hasUntrackedTextChannels(context *CommandHandlerContext, categoryID state.DiscordID) bool {
+	guild, err := context.Session.StateGuild(context.GuildID)
+	if err != nil {
+		// Refused, as the channels of the category can't be checked
+		return true
+	}
+
+	for _, channel := range guild.Channels {
+		if channel.Type != discordgo.ChannelTypeGuildText || !categoryID.Equals(channel.ParentID) {
+			continue
+		}
+
+		channelID, err := state.ParseDiscordID(channel.ID)
+		if err != nil || !b.tempChannels.IsTempChannel(channelID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *TempChannelBot) routeRemoveHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) < 1 {
+		context.reply("Missing voice channel or category ID, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	} else if len(context.CommandArgs) > 1 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	// The source isn't required to exist, so routes of deleted channels can be removed
+	sourceID, err := state.ParseDiscordID(context.CommandArgs[0])
+	if err != nil {
+		context.reply(`Invalid voice channel or category ID, please right click it and click "Copy ID"`)
+		return nil
+	}
+
+	if !hasCategoryRoute(context.ServerData, sourceID) {
+		context.reply("There's no route for %v, please check %vroute-list", context.describeRouteSource(sourceID), context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	err = context.ServerData.Update(func(config *state.ServerConfig) error { return config.RemoveCategoryRoute(sourceID) })
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("Update failed: %w", err)
+	}
+
+	context.reply("The temp channels of %v will be created in the default category", context.describeRouteSource(sourceID))
+	return nil
+}
+
+func (b *TempChannelBot) routeListHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) > 0 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	lines := []string{fmt.Sprintf("default: %v", context.channelName(context.ServerData.TempChannelCategoryID()))}
+	for _, route := range context.ServerData.Config().CategoryRoutes {
+		lines = append(lines, fmt.Sprintf("%v: %v", context.describeRouteSource(route.SourceID), context.channelName(route.CategoryID)))
+	}
+
+	context.reply("%v", strings.Join(lines, "\n"))
+	return nil
+}
+
+func hasCategoryRoute(serverData state.ServerData, sourceID state.DiscordID) bool {
+	for _, route := range serverData.Config().CategoryRoutes {
+		if route.SourceID == sourceID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// describeRouteSource describes the voice channel or the category of voice channels a route applies to.
+func (c *CommandHandlerContext) describeRouteSource(sourceID state.DiscordID) string {
+	channel, err := c.Session.StateChannel(sourceID.RESTAPIFormat())
+	if !existsInState(err) || channel.GuildID != c.GuildID {
+		return fmt.Sprintf("the deleted channel %v", sourceID)
+	}
+
+	if channel.Type == discordgo.ChannelTypeGuildCategory {
+		return fmt.Sprintf("the voice channels in %v", channel.Name)
+	}
+
+	return fmt.Sprintf("#%v", channel.Name)
+}
+
+// channelName returns the name of a channel or category of the server, marking channels that don't exist anymore.
+func (c *CommandHandlerContext) channelName(channelID state.DiscordID) string {
+	channel, err := c.Session.StateChannel(channelID.RESTAPIFormat())
+	if !existsInState(err) || channel.GuildID != c.GuildID {
+		return fmt.Sprintf("%v (doesn't exist)", channelID)
+	}
+
+	return channel.Name
+}
@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// addTempCategory adds another category the bot may create temp channels in.
+func (s *BotTestSuite) addTempCategory(name string) *discordgo.Channel {
+	return s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildCategory, name, "",
+		&discordgo.PermissionOverwrite{ID: s.guild.ID, Type: discordgo.PermissionOverwriteTypeRole, Deny: discordgo.PermissionViewChannel},
+		&discordgo.PermissionOverwrite{ID: testBotUserID, Type: discordgo.PermissionOverwriteTypeMember, Allow: discordgo.PermissionViewChannel | discordgo.PermissionManageChannels},
+	)
+}
+
+func (s *BotTestSuite) TestRouteVoiceChannel() {
+	s.setupServer()
+	gamingCategory := s.addTempCategory("gaming")
+
+	reply := s.runCommand(testOwnerID, "!route-add "+s.voiceChannel1.ID+" "+gamingCategory.ID)
+	s.Contains(reply, "The temp channels of #voice-1 will be created in gaming")
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	s.Len(s.session.ChannelsInCategory(s.guild.ID, gamingCategory.ID), 1, "The temp channel wasn't created in the routed category")
+
+	s.joinVoice(testUser2ID, s.voiceChannel2)
+	s.runCommand(testUser2ID, "!mkch")
+	s.requireTempChannel()
+}
+
+func (s *BotTestSuite) TestRouteVoiceCategory() {
+	s.setupServer()
+	gamingCategory := s.addTempCategory("gaming")
+	voiceCategory := s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildCategory, "games", "")
+	gameVoiceChannel := s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildVoice, "game-1", voiceCategory.ID)
+	otherCategory := s.addTempCategory("other")
+
+	s.runCommand(testOwnerID, "!route-add "+voiceCategory.ID+" "+gamingCategory.ID)
+	s.joinVoice(testUser1ID, gameVoiceChannel)
+	s.runCommand(testUser1ID, "!mkch")
+	s.Len(s.session.ChannelsInCategory(s.guild.ID, gamingCategory.ID), 1, "The temp channel wasn't created in the category's route")
+
+	s.runCommand(testOwnerID, "!route-add "+gameVoiceChannel.ID+" "+otherCategory.ID)
+	s.joinVoice(testUser1ID, nil)
+	s.joinVoice(testUser1ID, gameVoiceChannel)
+	s.runCommand(testUser1ID, "!mkch")
+	s.Len(s.session.ChannelsInCategory(s.guild.ID, otherCategory.ID), 1, "The voice channel's route wasn't preferred over its category's route")
+}
+
+func (s *BotTestSuite) TestRouteRefusesSharedCategory() {
+	serverData := s.setupServer()
+	voiceCategory := s.addTempCategory("games")
+	gameVoiceChannel := s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildVoice, "game-1", voiceCategory.ID)
+	s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildText, "game-chat", voiceCategory.ID)
+
+	reply := s.runCommand(testOwnerID, "!route-add "+gameVoiceChannel.ID+" "+voiceCategory.ID)
+	s.Contains(reply, "games has text channels that weren't created by the bot")
+	s.Empty(serverData.Config().CategoryRoutes)
+}
+
+func (s *BotTestSuite) TestRouteRemove() {
+	serverData := s.setupServer()
+	gamingCategory := s.addTempCategory("gaming")
+	s.runCommand(testOwnerID, "!route-add "+s.voiceChannel1.ID+" "+gamingCategory.ID)
+
+	s.Contains(s.runCommand(testOwnerID, "!route-remove "+s.voiceChannel1.ID), "will be created in the default category")
+	s.Empty(serverData.Config().CategoryRoutes)
+	s.Contains(s.runCommand(testOwnerID, "!route-remove "+s.voiceChannel1.ID), "There's no route for #voice-1")
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	s.requireTempChannel()
+}
+
+func (s *BotTestSuite) TestRouteAddInvalidArguments() {
+	serverData := s.setupServer()
+	gamingCategory := s.addTempCategory("gaming")
+	noPermissionCategory := s.session.AddChannel(s.guild.ID, discordgo.ChannelTypeGuildCategory, "private", "")
+
+	s.Contains(s.runCommand(testOwnerID, "!route-add "+s.textChannel.ID+" "+gamingCategory.ID), "The voice channel or category doesn't exist")
+	s.Contains(s.runCommand(testOwnerID, "!route-add "+s.voiceChannel1.ID+" "+s.voiceChannel2.ID), "The given ID isn't of a category")
+	s.Contains(s.runCommand(testOwnerID, "!route-add "+s.voiceChannel1.ID+" "+noPermissionCategory.ID), `The bot doesn't have the "Manage Channels" permission`)
+	s.Contains(s.runCommand(testOwnerID, "!route-add "+s.voiceChannel1.ID), "Missing arguments")
+	s.Empty(serverData.Config().CategoryRoutes)
+}
+
+func (s *BotTestSuite) TestRouteList() {
+	s.setupServer()
+	gamingCategory := s.addTempCategory("gaming")
+	s.runCommand(testOwnerID, "!route-add "+s.voiceChannel2.ID+" "+gamingCategory.ID)
+
+	s.Equal([]string{"default: temp", "#voice-2: gaming"}, splitLines(s.runCommand(testOwnerID, "!route-list")))
+}
+
+func (s *BotTestSuite) TestMissingRoutedCategory() {
+	s.setupServer()
+	gamingCategory := s.addTempCategory("gaming")
+	s.runCommand(testOwnerID, "!route-add "+s.voiceChannel1.ID+" "+gamingCategory.ID)
+	_, err := s.session.ChannelDelete(gamingCategory.ID)
+	s.Require().NoError(err)
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.Contains(s.runCommand(testUser1ID, "!mkch"), "The temp channel category of this voice chat doesn't exist")
+}
@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/consts"
+)
+
+// Session is the subset of the Discord REST API and state the bot uses.
+// The REST methods have the signatures of *discordgo.Session, so a real session only has to add the state methods,
+// and handlers can run against an in-memory fake in tests.
+type Session interface {
+	User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error)
+	GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error)
+
+	Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	GuildChannelCreateComplex(guildID string, data discordgo.GuildChannelCreateData, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	ChannelEdit(channelID string, data *discordgo.ChannelEdit, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	ChannelDelete(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	ChannelPermissionSet(channelID, targetID string, targetType discordgo.PermissionOverwriteType, allow, deny int64, options ...discordgo.RequestOption) error
+	ChannelPermissionDelete(channelID, targetID string, options ...discordgo.RequestOption) error
+
+	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error)
+	ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	// AttachmentContent downloads the content of a file attached to a message.
+	AttachmentContent(attachment *discordgo.MessageAttachment) ([]byte, error)
+
+	ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error)
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+	InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error
+	FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error)
+
+	// StateGuild returns a server from the state, including its roles, channels and voice states.
+	StateGuild(guildID string) (*discordgo.Guild, error)
+	// StateChannel returns a channel from the state.
+	StateChannel(channelID string) (*discordgo.Channel, error)
+	// StateMember returns a server member from the state.
+	StateMember(guildID, userID string) (*discordgo.Member, error)
+	// StateRole returns a server role from the state.
+	StateRole(guildID, roleID string) (*discordgo.Role, error)
+	// StateMemberAdd adds or updates a member in the state.
+	StateMemberAdd(member *discordgo.Member) error
+}
+
+// discordSession is a Session backed by a real Discord connection.
+type discordSession struct {
+	*discordgo.Session
+}
+
+// NewDiscordSession wraps a discordgo session in a Session.
+func NewDiscordSession(session *discordgo.Session) Session {
+	return &discordSession{Session: session}
+}
+
+// StateGuild returns a server from the state, including its roles, channels and voice states.
+func (s *discordSession) StateGuild(guildID string) (*discordgo.Guild, error) {
+	return s.State.Guild(guildID)
+}
+
+// StateChannel returns a channel from the state.
+func (s *discordSession) StateChannel(channelID string) (*discordgo.Channel, error) {
+	return s.State.Channel(channelID)
+}
+
+// StateMember returns a server member from the state.
+func (s *discordSession) StateMember(guildID, userID string) (*discordgo.Member, error) {
+	return s.State.Member(guildID, userID)
+}
+
+// StateRole returns a server role from the state.
+func (s *discordSession) StateRole(guildID, roleID string) (*discordgo.Role, error) {
+	return s.State.Role(guildID, roleID)
+}
+
+// StateMemberAdd adds or updates a member in the state.
+func (s *discordSession) StateMemberAdd(member *discordgo.Member) error {
+	return s.State.MemberAdd(member)
+}
+
+// AttachmentContent downloads the content of a file attached to a message.
+// Files larger than consts.MaxAttachmentSize are cut at that size.
+func (s *discordSession) AttachmentContent(attachment *discordgo.MessageAttachment) ([]byte, error) {
+	response, err := s.Client.Get(attachment.URL)
+	if err != nil {
+		return nil, fmt.Errorf("Failed downloading attachment %v: %w", attachment.ID, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed downloading attachment %v: %v", attachment.ID, response.Status)
+	}
+
+	return ioutil.ReadAll(io.LimitReader(response.Body, consts.MaxAttachmentSize))
+}
@@ -0,0 +1,191 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+)
+
+const (
+	// voicePolicyMkch is the voice-policy setting that turns temp channels on/off for the voice channel.
+	voicePolicyMkch = "mkch"
+	// voicePolicyAutoCreate is the voice-policy setting that forces automatic creation for the voice channel.
+	voicePolicyAutoCreate = "auto-create"
+	// voicePolicyName is the voice-policy setting that overrides the name template.
+	voicePolicyName = "name"
+	// voicePolicyHistory is the voice-policy setting that overrides how late joiners see the messages sent before they joined.
+	voicePolicyHistory = "history"
+	// voicePolicyReset is the voice-policy argument that removes all of the voice channel's overrides.
+	voicePolicyReset = "reset"
+
+	// voicePolicyDefault is the value that makes a voice-policy setting follow the server's setting again.
+	voicePolicyDefault = "default"
+	autoCreateForce    = "force"
+)
+
+var voicePolicySettings = []string{voicePolicyMkch, voicePolicyAutoCreate, voicePolicyName, voicePolicyHistory, voicePolicyReset}
+
+func (b *TempChannelBot) voicePolicyHandler(context *CommandHandlerContext) error {
+	if len(context.CommandArgs) == 0 {
+		return b.listVoicePolicies(context)
+	}
+
+	voiceChannelID, err := state.ParseDiscordID(context.CommandArgs[0])
+	if err != nil || !context.voiceChannelExists(context.CommandArgs[0]) {
+		context.reply(`The voice channel doesn't exist, please right click it and click "Copy ID"`)
+		return nil
+	}
+
+	config := context.ServerData.Config()
+	policy := config.VoiceChannelPolicy(voiceChannelID)
+	if len(context.CommandArgs) == 1 {
+		context.reply("%v:\n%v", context.channelName(voiceChannelID), strings.Join(describeVoicePolicy(policy), "\n"))
+		return nil
+	}
+
+	setting := strings.ToLower(context.CommandArgs[1])
+	values := context.CommandArgs[2:]
+	if setting != voicePolicyName && setting != voicePolicyHistory && len(values) > 1 {
+		context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	value := ""
+	if len(values) == 1 {
+		value = strings.ToLower(values[0])
+	}
+
+	switch setting {
+	case voicePolicyMkch:
+		if value != "on" && value != "off" {
+			context.reply("Please specify on or off")
+			return nil
+		}
+
+		if value == "off" && policy.ForceAutoCreate {
+			context.reply("Temp channels are created automatically for this voice chat, please run %vvoice-policy %v %v %v first",
+				context.ServerData.CommandPrefix(), voiceChannelID, voicePolicyAutoCreate, voicePolicyDefault)
+			return nil
+		}
+
+		policy.MkchDisabled = value == "off"
+	case voicePolicyAutoCreate:
+		if value != autoCreateForce && value != voicePolicyDefault {
+			context.reply("Please specify %v or %v", autoCreateForce, voicePolicyDefault)
+			return nil
+		}
+
+		if value == autoCreateForce && policy.MkchDisabled {
+			context.reply("Temp channels are disabled for this voice chat, please run %vvoice-policy %v %v on first",
+				context.ServerData.CommandPrefix(), voiceChannelID, voicePolicyMkch)
+			return nil
+		}
+
+		policy.ForceAutoCreate = value == autoCreateForce
+	case voicePolicyName:
+		// Templates keep their case, and may contain spaces
+		template := strings.Join(values, " ")
+		if strings.ToLower(template) == voicePolicyDefault {
+			template = ""
+		}
+
+		if template != "" {
+			if err := validateChannelNameTemplate(template); err != nil {
+				context.reply("%v", err)
+				return nil
+			}
+		}
+
+		policy.ChannelNameTemplate = template
+	case voicePolicyHistory:
+		// A slash command passes the mode and the recap period as a single value
+		args := strings.Fields(strings.Join(values, " "))
+		if len(args) == 0 {
+			context.reply("Please specify %v or %v", strings.Join(consts.ValidHistoryModes, ", "), voicePolicyDefault)
+			return nil
+		}
+
+		if len(args) == 1 && strings.ToLower(args[0]) == voicePolicyDefault {
+			policy.HistoryVisibility = state.HistoryVisibility{}
+			break
+		}
+
+		visibility, problem := context.parseHistoryArgs(args)
+		if problem != "" {
+			context.reply("%v", problem)
+			return nil
+		}
+
+		policy.HistoryVisibility = visibility
+	case voicePolicyReset:
+		if len(values) > 0 {
+			context.reply("Too many arguments, please check %vhelp to see how to use the command", context.ServerData.CommandPrefix())
+			return nil
+		}
+
+		policy = state.VoiceChannelPolicy{VoiceChannelID: voiceChannelID}
+	default:
+		context.reply("Unknown setting %v, please use one of the following: %v", setting, strings.Join(voicePolicySettings, ", "))
+		return nil
+	}
+
+	err = context.ServerData.Update(func(config *state.ServerConfig) error {
+		config.SetVoiceChannelPolicy(policy)
+		return nil
+	})
+	if err != nil {
+		context.reply("An internal error has occurred")
+		return fmt.Errorf("Update failed: %w", err)
+	}
+
+	context.reply("%v:\n%v\nThe changes apply to temp channels created from now on", context.channelName(voiceChannelID), strings.Join(describeVoicePolicy(policy), "\n"))
+	return nil
+}
+
+func (b *TempChannelBot) listVoicePolicies(context *CommandHandlerContext) error {
+	policies := context.ServerData.Config().VoiceChannelPolicies
+	if len(policies) == 0 {
+		context.reply("No voice channel overrides the server's settings, please check %vhelp to see how to add one", context.ServerData.CommandPrefix())
+		return nil
+	}
+
+	lines := []string{}
+	for _, policy := range policies {
+		lines = append(lines, fmt.Sprintf("%v: %v", context.channelName(policy.VoiceChannelID), strings.Join(describeVoicePolicy(policy), ", ")))
+	}
+
+	context.reply("%v", strings.Join(lines, "\n"))
+	return nil
+}
+
+// describeVoicePolicy describes each of the settings of the policy, including the ones it doesn't override.
+func describeVoicePolicy(policy state.VoiceChannelPolicy) []string {
+	lines := []string{}
+	if policy.MkchDisabled {
+		lines = append(lines, "mkch: off")
+	} else {
+		lines = append(lines, "mkch: on")
+	}
+
+	if policy.ForceAutoCreate {
+		lines = append(lines, "auto-create: force")
+	} else {
+		lines = append(lines, "auto-create: server default")
+	}
+
+	if policy.ChannelNameTemplate != "" {
+		lines = append(lines, fmt.Sprintf("name: %v", policy.ChannelNameTemplate))
+	} else {
+		lines = append(lines, "name: server default")
+	}
+
+	if policy.HistoryVisibility.Mode != "" {
+		lines = append(lines, fmt.Sprintf("history: %v", describeHistoryVisibility(policy.HistoryVisibility)))
+	} else {
+		lines = append(lines, "history: server default")
+	}
+
+	return lines
+}
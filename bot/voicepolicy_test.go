@@ -0,0 +1,87 @@
+package bot
+
+func (s *BotTestSuite) TestVoicePolicyDisablesMkch() {
+	s.setupServer()
+	s.Contains(s.runCommand(testOwnerID, "!voice-policy "+s.voiceChannel1.ID+" mkch off"), "mkch: off")
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.Contains(s.runCommand(testUser1ID, "!mkch"), "Temp channels are disabled for this voice chat")
+	s.Empty(s.tempChannels())
+
+	s.joinVoice(testUser2ID, s.voiceChannel2)
+	s.runCommand(testUser2ID, "!mkch")
+	s.requireTempChannel()
+}
+
+func (s *BotTestSuite) TestVoicePolicyDisablesAutoCreate() {
+	serverData := s.setupServer()
+	s.Require().NoError(serverData.SetAutoCreate(true))
+	s.runCommand(testOwnerID, "!voice-policy "+s.voiceChannel1.ID+" mkch off")
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.Empty(s.tempChannels(), "A temp channel was created automatically for a disabled voice channel")
+}
+
+func (s *BotTestSuite) TestVoicePolicyForcesAutoCreate() {
+	s.setupServer()
+	s.runCommand(testOwnerID, "!voice-policy "+s.voiceChannel1.ID+" auto-create force")
+
+	s.joinVoice(testUser1ID, s.voiceChannel2)
+	s.Empty(s.tempChannels(), "A temp channel was created automatically while the server's automatic creation is off")
+
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+	s.requireTempChannel()
+}
+
+func (s *BotTestSuite) TestVoicePolicyNameTemplate() {
+	serverData := s.setupServer()
+	s.Require().NoError(serverData.SetChannelNameTemplate("server-{n}"))
+	s.runCommand(testOwnerID, "!voice-policy "+s.voiceChannel1.ID+" name {voice} chat")
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	s.Equal("voice-1-chat", s.requireTempChannel().Name)
+
+	s.runCommand(testOwnerID, "!voice-policy "+s.voiceChannel1.ID+" name")
+	s.Empty(serverData.Config().VoiceChannelPolicies, "Resetting the only override didn't remove the policy")
+
+	s.runCommand(testOwnerID, "!voice-policy "+s.voiceChannel1.ID+" name {voice} chat")
+	s.runCommand(testOwnerID, "!voice-policy "+s.voiceChannel1.ID+" name Default")
+	s.Empty(serverData.Config().VoiceChannelPolicies, "The default value was used as a template instead of resetting it")
+}
+
+func (s *BotTestSuite) TestVoicePolicyHistory() {
+	s.setupServer()
+	s.runCommand(testOwnerID, "!voice-policy "+s.voiceChannel1.ID+" history visible")
+
+	s.joinVoice(testUser1ID, s.voiceChannel1)
+	s.runCommand(testUser1ID, "!mkch")
+	s.joinVoice(testUser2ID, s.voiceChannel1)
+
+	tempChannel := s.requireTempChannel()
+	for _, userID := range []string{testUser1ID, testUser2ID} {
+		s.True(s.canReadHistory(tempChannel, userID), "A member can't read the channel's history")
+	}
+}
+
+func (s *BotTestSuite) TestVoicePolicyConflicts() {
+	serverData := s.setupServer()
+	s.runCommand(testOwnerID, "!voice-policy "+s.voiceChannel1.ID+" auto-create force")
+
+	s.Contains(s.runCommand(testOwnerID, "!voice-policy "+s.voiceChannel1.ID+" mkch off"), "Temp channels are created automatically for this voice chat")
+	s.Contains(s.runCommand(testOwnerID, "!voice-policy "+s.voiceChannel1.ID+" colour red"), "Unknown setting colour")
+	s.Contains(s.runCommand(testOwnerID, "!voice-policy "+s.textChannel.ID+" mkch off"), "The voice channel doesn't exist")
+	s.True(serverData.Config().VoiceChannelPolicies[0].ForceAutoCreate)
+	s.False(serverData.Config().VoiceChannelPolicies[0].MkchDisabled)
+}
+
+func (s *BotTestSuite) TestVoicePolicyList() {
+	s.setupServer()
+	s.Contains(s.runCommand(testOwnerID, "!voice-policy"), "No voice channel overrides the server's settings")
+
+	s.runCommand(testOwnerID, "!voice-policy "+s.voiceChannel2.ID+" history recap 15")
+	s.Equal([]string{"voice-2: mkch: on, auto-create: server default, name: server default, history: recap of the last 15 minutes"}, splitLines(s.runCommand(testOwnerID, "!voice-policy")))
+
+	s.runCommand(testOwnerID, "!voice-policy "+s.voiceChannel2.ID+" reset")
+	s.Contains(s.runCommand(testOwnerID, "!voice-policy"), "No voice channel overrides the server's settings")
+}
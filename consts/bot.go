@@ -1,6 +1,9 @@
 package consts
 
-import "regexp"
+import (
+	"regexp"
+	"time"
+)
 
 const (
 	// DefaultMakeChannelCommand is the default command name for the create-temp-channel command.
@@ -19,9 +22,109 @@ const (
 	MinCommandNameLength = 2
 	// MaxCommandNameLength is the maximum amount of allowed letters.
 	MaxCommandNameLength = 32
+
+	// OrphanPolicyDelete deletes text channels in the temp category that aren't tracked by the bot.
+	OrphanPolicyDelete = "delete"
+	// OrphanPolicyAdopt tracks untracked text channels as temp channels of the voice chat their members are in.
+	// Channels whose voice chat can't be found are re-permissioned instead.
+	OrphanPolicyAdopt = "adopt"
+	// OrphanPolicyRepermission keeps untracked text channels, but removes the access of all their members.
+	OrphanPolicyRepermission = "repermission"
+	// DefaultOrphanPolicy is the default policy for untracked text channels in the temp category.
+	// Channels the bot doesn't know about may not be its own, so they aren't deleted unless the server chose to.
+	DefaultOrphanPolicy = OrphanPolicyRepermission
+
+	// PlaceholderVoice is replaced with the voice channel name in channel name templates.
+	PlaceholderVoice = "voice"
+	// PlaceholderOwner is replaced with the name of the user the temp channel was created for.
+	PlaceholderOwner = "owner"
+	// PlaceholderDate is replaced with the creation date.
+	PlaceholderDate = "date"
+	// PlaceholderNumber is replaced with the number of the temp channel among the server's temp channels.
+	PlaceholderNumber = "n"
+	// PlaceholderSilly is replaced with a random silly name.
+	PlaceholderSilly = "silly"
+
+	// MaxChannelNameLength is the maximum length of a Discord channel name.
+	MaxChannelNameLength = 100
+
+	// ArchiveFormatText archives transcripts as plain text.
+	ArchiveFormatText = "text"
+	// ArchiveFormatJSON archives transcripts as a JSON list of messages.
+	ArchiveFormatJSON = "json"
+	// ArchiveFormatHTML archives transcripts as an HTML page.
+	ArchiveFormatHTML = "html"
+
+	// MaxMessagesPerRequest is the maximum amount of messages Discord returns for a single history request.
+	MaxMessagesPerRequest = 100
+	// MaxMessageLength is the maximum length of a Discord message.
+	MaxMessageLength = 2000
+
+	// MaxDeletionGracePeriod is the longest time an empty temp channel may be kept before it's deleted.
+	MaxDeletionGracePeriod = time.Hour
+
+	// ExpiryActionDelete deletes temp channels that reached their server's maximum age or idle time.
+	ExpiryActionDelete = "delete"
+	// ExpiryActionRotate replaces temp channels that reached their server's maximum age or idle time with new ones, with the same members.
+	// The transcript of the old channel is archived if the server archives transcripts.
+	ExpiryActionRotate = "rotate"
+	// DefaultExpiryAction is the default action taken on expired temp channels.
+	DefaultExpiryAction = ExpiryActionDelete
+	// MinChannelExpiry is the shortest maximum age or idle time of temp channels, longer than the warning before they expire.
+	MinChannelExpiry = 15 * time.Minute
+	// MaxChannelExpiry is the longest maximum age or idle time of temp channels.
+	MaxChannelExpiry = 30 * 24 * time.Hour
+	// ChannelExpiryWarning is how long before a temp channel expires its members are warned.
+	ChannelExpiryWarning = 5 * time.Minute
+	// ChannelExpiryCheckInterval is how often temp channels are checked for expiry.
+	ChannelExpiryCheckInterval = time.Minute
+
+	// MaxMkchCooldown is the longest time a user may have to wait between creating temp channels.
+	MaxMkchCooldown = time.Hour
+
+	// RemovedServerRetention is how long the settings of a server the bot was removed from are kept, in case it's invited back.
+	RemovedServerRetention = 30 * 24 * time.Hour
+	// RemovedServerPurgeInterval is how often the data of servers removed before the retention period is deleted.
+	RemovedServerPurgeInterval = time.Hour
+
+	// MaxAttachmentSize is the size of the largest file the bot downloads, such as a settings file to import.
+	MaxAttachmentSize = 1024 * 1024
+
+	// HistoryModeHidden hides the messages sent before a member joined the temp channel.
+	HistoryModeHidden = "hidden"
+	// HistoryModeVisible lets members read the messages sent before they joined the temp channel.
+	HistoryModeVisible = "visible"
+	// HistoryModeRecap hides the messages sent before a member joined, but the bot re-posts the recent ones for the newcomer.
+	HistoryModeRecap = "recap"
+	// DefaultHistoryMode is the default history mode of temp channels.
+	DefaultHistoryMode = HistoryModeHidden
+	// MaxHistoryRecapPeriod is how far back the longest recap goes.
+	MaxHistoryRecapPeriod = 24 * time.Hour
+
+	// PermissionRuleRole allows the members of a role to run a command.
+	PermissionRuleRole = "role"
+	// PermissionRuleUser allows a specific user to run a command.
+	PermissionRuleUser = "user"
+	// PermissionRulePermission allows anyone with all of the rule's permissions to run a command.
+	PermissionRulePermission = "permission"
 )
 
 var (
+	// ValidOrphanPolicies is the list of all valid orphan channel policies.
+	ValidOrphanPolicies = []string{OrphanPolicyDelete, OrphanPolicyAdopt, OrphanPolicyRepermission}
+
+	// ValidPlaceholders is the list of all placeholders channel name templates may use.
+	ValidPlaceholders = []string{PlaceholderVoice, PlaceholderOwner, PlaceholderDate, PlaceholderNumber, PlaceholderSilly}
+
+	// ValidHistoryModes is the list of all valid history modes.
+	ValidHistoryModes = []string{HistoryModeHidden, HistoryModeVisible, HistoryModeRecap}
+
+	// ValidExpiryActions is the list of all valid actions taken on expired temp channels.
+	ValidExpiryActions = []string{ExpiryActionDelete, ExpiryActionRotate}
+
+	// ValidArchiveFormats is the list of all valid transcript formats.
+	ValidArchiveFormats = []string{ArchiveFormatText, ArchiveFormatJSON, ArchiveFormatHTML}
+
 	// ValidCommandLettersRegex is the regexp of valid command letters.
 	ValidCommandLettersRegex = regexp.MustCompile("^[A-Za-z-_]{2,32}$")
 )
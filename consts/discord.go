@@ -4,8 +4,6 @@ const (
 	// EveryoneRoleName is the @everyone role name.
 	EveryoneRoleName = "@everyone"
 
-	// PermissionTypeMember means the receiver of the permission is a server member.
-	PermissionTypeMember = "member"
-	// PermissionTypeRole means the receiver of the permission is a server role.
-	PermissionTypeRole = "role"
+	// MaxGuildChannels is the maximum number of channels, including categories, a Discord server may have.
+	MaxGuildChannels = 500
 )
@@ -5,8 +5,9 @@ go 1.14
 
 require (
 	github.com/Pallinder/go-randomdata v1.2.0
-	github.com/bwmarrin/discordgo v0.20.2
+	github.com/bwmarrin/discordgo v0.29.0
+	github.com/gorilla/websocket v1.4.2
 	github.com/lib/pq v1.3.0
+	github.com/mattn/go-sqlite3 v1.14.14
 	github.com/stretchr/testify v1.5.1
-	golang.org/x/text v0.3.2 // indirect
 )
@@ -5,10 +5,10 @@ import (
 	"os"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/jonathroth/temp-chat/bot"
-	"github.com/jonathroth/temp-chat/consts"
 	"github.com/jonathroth/temp-chat/state"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -18,16 +18,19 @@ var IDRegex = regexp.MustCompile(`<#(\d+)>`)
 
 /*
 IntegrationTestSuite runs all bot commands using two bot clients.
-To run the integ tests, you must first define the $INTEG_TESTS env variable.
 
+TestIntegrationTestSuite runs it against Discord. To run it, you must first define the $INTEG_TESTS env variable.
 It requires a total of 4 accounts with 4 access tokens, accessed through env variables:
 
-1. The bot itself - $DISCORD_TOKEN
-2. Admin accounts - $ADMIN_TOKEN - Used to create the different channels/categories.
-3. Client #1 - CLIENT1_TOKEN
-4. Client #2 - CLIENT2_TOKEN
+1. The bot itself - $INTEG_TEST_BOT_TOKEN
+2. Admin accounts - $INTEG_TEST_ADMIN_TOKEN - Used to create the different channels/categories.
+3. Client #1 - $INTEG_TEST_CLIENT1_TOKEN
+4. Client #2 - $INTEG_TEST_CLIENT2_TOKEN
 
 The suite assumes all bots are in a single server, and that the admin bot has the Administrator privilege.
+Setting $INTEG_TEST_DISCORD_URL points the suite at another server implementing Discord's API instead.
+
+TestOfflineIntegrationTestSuite runs it against a local fakediscord.Server, and needs no configuration.
 */
 type IntegrationTestSuite struct {
 	suite.Suite
@@ -42,24 +45,50 @@ type IntegrationTestSuite struct {
 	server      *discordgo.Guild
 	textChannel *discordgo.Channel
 
+	tokens   integrationTokens
 	cleanups []func()
 }
 
+type integrationTokens struct {
+	bot     string
+	admin   string
+	client1 string
+	client2 string
+}
+
 func TestIntegrationTestSuite(t *testing.T) {
 	_, exists := os.LookupEnv("INTEG_TESTS")
 	if !exists {
 		t.Skip("INTEG_TESTS is not defined, skipping")
 	}
-	suite.Run(t, &IntegrationTestSuite{})
+
+	if baseURL, exists := os.LookupEnv("INTEG_TEST_DISCORD_URL"); exists {
+		defer setDiscordBaseURL(baseURL)()
+	}
+
+	suite.Run(t, &IntegrationTestSuite{tokens: integrationTokens{
+		bot:     os.Getenv("INTEG_TEST_BOT_TOKEN"),
+		admin:   os.Getenv("INTEG_TEST_ADMIN_TOKEN"),
+		client1: os.Getenv("INTEG_TEST_CLIENT1_TOKEN"),
+		client2: os.Getenv("INTEG_TEST_CLIENT2_TOKEN"),
+	}})
+}
+
+func TestOfflineIntegrationTestSuite(t *testing.T) {
+	server, tokens := newFakeDiscordServer()
+	defer server.Close()
+	defer setDiscordBaseURL(server.URL())()
+
+	suite.Run(t, &IntegrationTestSuite{tokens: tokens})
 }
 
 func (s *IntegrationTestSuite) SetupSuite() {
 	var err error
-	s.admin = NewTestClientSession(s.T(), os.Getenv("INTEG_TEST_ADMIN_TOKEN"))
-	s.client1 = NewTestClientSession(s.T(), os.Getenv("INTEG_TEST_CLIENT1_TOKEN"))
-	s.client2 = NewTestClientSession(s.T(), os.Getenv("INTEG_TEST_CLIENT2_TOKEN"))
+	s.admin = NewTestClientSession(s.T(), s.tokens.admin)
+	s.client1 = NewTestClientSession(s.T(), s.tokens.client1)
+	s.client2 = NewTestClientSession(s.T(), s.tokens.client2)
 
-	servers, err := s.admin.UserGuilds(1, "", "")
+	servers, err := s.admin.UserGuilds(1, "", "", false)
 	failOnErr(s.T(), err, "Failed getting servers")
 
 	if !assert.Len(s.T(), servers, 1, "More than 1 guild found") {
@@ -78,17 +107,20 @@ func (s *IntegrationTestSuite) TearDownSuite() {
 }
 
 func (s *IntegrationTestSuite) SetupTest() {
-	s.bot = NewTestBotSession(s.T(), os.Getenv("INTEG_TEST_BOT_TOKEN"))
+	s.bot = NewTestBotSession(s.T(), s.tokens.bot)
 
-	store, err := state.NewSyncServerStore(NewMemoryDataProvider())
+	provider := state.NewMemoryServersProvider()
+	store, err := state.NewSyncServerStore(provider)
 	failOnErr(s.T(), err, "Failed initializing server store")
-	s.tempChannelBot, err = bot.NewTempChannelBot(s.bot.Session, store)
+	s.tempChannelBot, err = bot.NewTempChannelBot(bot.NewDiscordSession(s.bot.Session), store, provider, provider, provider)
 	failOnErr(s.T(), err, "Failed initializing bot")
 
 	s.tempChannelBot.AllowBots = true
 
 	s.cleanups = []func(){}
+	s.cleanups = append(s.cleanups, s.bot.AddHandler(s.tempChannelBot.GuildCreate))
 	s.cleanups = append(s.cleanups, s.bot.AddHandler(s.tempChannelBot.MessageCreate))
+	s.cleanups = append(s.cleanups, s.bot.AddHandler(s.tempChannelBot.InteractionCreate))
 	s.cleanups = append(s.cleanups, s.bot.AddHandler(s.tempChannelBot.ChannelDelete))
 	s.cleanups = append(s.cleanups, s.bot.AddHandler(s.tempChannelBot.VoiceStatusUpdate))
 
@@ -107,6 +139,7 @@ func (s *IntegrationTestSuite) TearDownTest() {
 
 	s.deleteChannel(s.textChannel)
 	s.tempChannelBot.CleanChannels()
+	s.tempChannelBot.Close()
 	assert.NoError(s.T(), s.bot.Close(), "Bot session Close() returned error")
 }
 
@@ -114,7 +147,7 @@ func (s *IntegrationTestSuite) TestMkch() {
 	category := s.createChannel("category", discordgo.ChannelTypeGuildCategory)
 	defer s.deleteChannel(category)
 
-	err := s.admin.ChannelPermissionSet(category.ID, s.bot.Me.ID, consts.PermissionTypeMember, discordgo.PermissionManageChannels, 0)
+	err := s.admin.ChannelPermissionSet(category.ID, s.bot.Me.ID, discordgo.PermissionOverwriteTypeMember, discordgo.PermissionManageChannels, 0)
 	failOnErr(s.T(), err, "Failed giving temp-bot permissions")
 
 	setupCommand := fmt.Sprintf("!setup %v", category.ID)
@@ -125,12 +158,8 @@ func (s *IntegrationTestSuite) TestMkch() {
 	voiceChannel2 := s.createChannel("voice2", discordgo.ChannelTypeGuildVoice)
 	defer s.deleteChannel(voiceChannel2)
 
-	voiceConn1, err := s.client1.ChannelVoiceJoin(s.server.ID, voiceChannel1.ID, true, true)
-	failOnErr(s.T(), err, "Failed joining voice chat")
-	defer func() {
-		err := voiceConn1.Disconnect()
-		assert.NoError(s.T(), err, "Disconnecting from VC failed")
-	}()
+	s.client1.JoinVoice(s.server.ID, voiceChannel1.ID)
+	defer s.client1.JoinVoice(s.server.ID, "")
 
 	response := s.client1.Command(s.textChannel.ID, "!mkch", s.bot.Me, "temporary channel was created")
 	s.T().Logf("response: %q", response.Content)
@@ -143,22 +172,18 @@ func (s *IntegrationTestSuite) TestMkch() {
 	_, err = s.admin.State.Channel(tempChatID)
 	failOnErr(s.T(), err, "Created temp chat not found")
 
-	if !s.True(s.client1.HasPermissions(tempChatID, discordgo.PermissionReadMessages), "No read permissions for tempchat creator") {
+	if !s.True(s.client1.HasPermissions(tempChatID, discordgo.PermissionViewChannel), "No read permissions for tempchat creator") {
 		return
 	}
-	if !s.False(s.client2.HasPermissions(tempChatID, discordgo.PermissionReadMessages), "User outside vc has permissions for tempchat") {
+	if !s.False(s.client2.HasPermissions(tempChatID, discordgo.PermissionViewChannel), "User outside vc has permissions for tempchat") {
 		return
 	}
 
 	content := "hi"
 	s.client1.SendMessage(tempChatID, content)
 
-	voiceConn2, err := s.client2.ChannelVoiceJoin(s.server.ID, voiceChannel1.ID, true, true)
-	failOnErr(s.T(), err, "Failed joining voice chat")
-	defer func() {
-		err := voiceConn2.Disconnect()
-		assert.NoError(s.T(), err, "Disconnecting from VC failed")
-	}()
+	s.client2.JoinVoice(s.server.ID, voiceChannel1.ID)
+	defer s.client2.JoinVoice(s.server.ID, "")
 
 	messages, err := s.client2.ChannelMessages(tempChatID, 1, "", "", "")
 	failOnErr(s.T(), err, "Failed getting messages from text chat the bot is in")
@@ -166,12 +191,39 @@ func (s *IntegrationTestSuite) TestMkch() {
 		assert.NotEqual(s.T(), content, message.Content, "Didn't expect seeing message sent before joining")
 	}
 
-	if !s.True(s.client1.HasPermissions(tempChatID, discordgo.PermissionReadMessages), "No read permissions for tempchat creator") {
+	if !s.True(s.client1.HasPermissions(tempChatID, discordgo.PermissionViewChannel), "No read permissions for tempchat creator") {
+		return
+	}
+	hasPermissions := func() bool { return s.client2.HasPermissions(tempChatID, discordgo.PermissionViewChannel) }
+	if !s.Eventually(hasPermissions, 5*time.Second, 25*time.Millisecond, "User didn't get read permissions") {
 		return
 	}
-	if !s.True(s.client2.HasPermissions(tempChatID, discordgo.PermissionReadMessages), "User didn't get read permissions") {
+}
+
+func (s *IntegrationTestSuite) TestAutoCreate() {
+	category := s.createChannel("category", discordgo.ChannelTypeGuildCategory)
+	defer s.deleteChannel(category)
+
+	err := s.admin.ChannelPermissionSet(category.ID, s.bot.Me.ID, discordgo.PermissionOverwriteTypeMember, discordgo.PermissionManageChannels, 0)
+	failOnErr(s.T(), err, "Failed giving temp-bot permissions")
+
+	setupCommand := fmt.Sprintf("!setup %v", category.ID)
+	s.admin.Command(s.textChannel.ID, setupCommand, s.bot.Me, "Server was setup successfully")
+	s.admin.Command(s.textChannel.ID, "!set-auto-create on", s.bot.Me, "will now be created automatically")
+
+	voiceChannel := s.createChannel("voice", discordgo.ChannelTypeGuildVoice)
+	defer s.deleteChannel(voiceChannel)
+
+	s.client1.JoinVoice(s.server.ID, voiceChannel.ID)
+	defer s.client1.JoinVoice(s.server.ID, "")
+
+	response := s.client1.Command(s.textChannel.ID, "!mkch", s.bot.Me, "temp channel already exists")
+	submatches := IDRegex.FindStringSubmatch(response.Content)
+	if !s.Len(submatches, 2, "Expected 1 submatch") {
 		return
 	}
+
+	s.True(s.client1.HasPermissions(submatches[1], discordgo.PermissionViewChannel), "No read permissions for the automatically created tempchat")
 }
 
 func (s *IntegrationTestSuite) TestSetupRequired() {
@@ -180,7 +232,7 @@ func (s *IntegrationTestSuite) TestSetupRequired() {
 	category := s.createChannel("temp", discordgo.ChannelTypeGuildCategory)
 	defer s.deleteChannel(category)
 
-	err := s.admin.ChannelPermissionSet(category.ID, s.bot.Me.ID, consts.PermissionTypeMember, discordgo.PermissionManageChannels, 0)
+	err := s.admin.ChannelPermissionSet(category.ID, s.bot.Me.ID, discordgo.PermissionOverwriteTypeMember, discordgo.PermissionManageChannels, 0)
 	failOnErr(s.T(), err, "Failed giving temp-bot permissions")
 
 	setupCommand := fmt.Sprintf("!setup %v", category.ID)
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/bot"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -37,6 +38,7 @@ func NewTestClientSession(t *testing.T, token string) *TestSession {
 func NewTestBotSession(t *testing.T, token string) *TestSession {
 	discordSession, err := discordgo.New("Bot " + token)
 	failOnErr(t, err, "Failed to create discord session")
+	discordSession.Identify.Intents = bot.Intents
 
 	me, err := discordSession.User("@me")
 	failOnErr(t, err, "Failed to get @me")
@@ -108,9 +110,72 @@ func (s *TestSession) findMessage(channel *discordgo.Channel, from *discordgo.Us
 	return nil
 }
 
-func (s *TestSession) HasPermissions(channelID string, permission int) bool {
+// JoinVoice moves the session's user to a voice channel, or out of voice chats if channelID is empty.
+// Only the voice state is changed, no voice connection is made. Waits until the gateway reports the change.
+func (s *TestSession) JoinVoice(guildID string, channelID string) {
+	err := s.ChannelVoiceJoinManual(guildID, channelID, true, true)
+	failOnErr(s.t, err, "Failed changing voice state")
+
+	timeout := time.After(5 * time.Second)
+	for {
+		if s.voiceChannelID(guildID) == channelID {
+			return
+		}
+
+		select {
+		case <-timeout:
+			failOnErr(s.t, errors.New("voice state update timed out"), "Failed changing voice state within timeout")
+			return
+		case <-time.After(25 * time.Millisecond):
+		}
+	}
+}
+
+// voiceChannelID returns the voice channel the session's user is in according to the state, or "" if it isn't in any.
+// State.VoiceState reads the guild's voice states without locking the state, so they're scanned here instead.
+func (s *TestSession) voiceChannelID(guildID string) string {
+	guild, err := s.State.Guild(guildID)
+	if err != nil {
+		return ""
+	}
+
+	s.State.RLock()
+	defer s.State.RUnlock()
+	for _, voiceState := range guild.VoiceStates {
+		if voiceState.UserID == s.Me.ID {
+			return voiceState.ChannelID
+		}
+	}
+
+	return ""
+}
+
+func (s *TestSession) HasPermissions(channelID string, permission int64) bool {
 	permissions, err := s.Session.UserChannelPermissions(s.Me.ID, channelID)
 	failOnErr(s.t, err, "Failed to get permissions")
 
 	return permissions&permission != 0
 }
+
+// setDiscordBaseURL points discordgo at another Discord API server, e.g. "http://localhost:8080/".
+// Returns a function restoring the previous endpoints.
+func setDiscordBaseURL(baseURL string) (restore func()) {
+	previous := discordgo.EndpointDiscord
+	setDiscordEndpoints(baseURL)
+	return func() { setDiscordEndpoints(previous) }
+}
+
+// setDiscordEndpoints recomputes the discordgo endpoints that are derived from EndpointDiscord when the package is initialized.
+// The rest of the endpoints are functions building on these, so they follow.
+func setDiscordEndpoints(baseURL string) {
+	discordgo.EndpointDiscord = baseURL
+	discordgo.EndpointAPI = discordgo.EndpointDiscord + "api/v" + discordgo.APIVersion + "/"
+	discordgo.EndpointGuilds = discordgo.EndpointAPI + "guilds/"
+	discordgo.EndpointChannels = discordgo.EndpointAPI + "channels/"
+	discordgo.EndpointUsers = discordgo.EndpointAPI + "users/"
+	discordgo.EndpointGateway = discordgo.EndpointAPI + "gateway"
+	discordgo.EndpointGatewayBot = discordgo.EndpointGateway + "/bot"
+	discordgo.EndpointWebhooks = discordgo.EndpointAPI + "webhooks/"
+	discordgo.EndpointGuildCreate = discordgo.EndpointAPI + "guilds"
+	discordgo.EndpointApplications = discordgo.EndpointAPI + "applications"
+}
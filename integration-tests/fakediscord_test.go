@@ -0,0 +1,35 @@
+package integration_test
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/jonathroth/temp-chat/bot/fakediscord"
+)
+
+const fakeBotPermissions = discordgo.PermissionViewChannel | discordgo.PermissionSendMessages | discordgo.PermissionReadMessageHistory |
+	discordgo.PermissionManageChannels | discordgo.PermissionManageRoles
+
+// newFakeDiscordServer starts a local Discord with the single server IntegrationTestSuite expects:
+// owned by the admin, with the bot, both clients, and a role giving the bot the permissions it's invited with.
+func newFakeDiscordServer() (*fakediscord.Server, integrationTokens) {
+	tokens := integrationTokens{
+		bot:     "bot-token",
+		admin:   "admin-token",
+		client1: "client1-token",
+		client2: "client2-token",
+	}
+
+	server := fakediscord.NewServer("100", tokens.bot)
+	admin := server.AddUser(tokens.admin, true)
+	client1 := server.AddUser(tokens.client1, true)
+	client2 := server.AddUser(tokens.client2, true)
+
+	guild := server.AddGuild(admin.ID)
+	botRole := server.AddRole(guild.ID, "temp-chat", fakeBotPermissions)
+	_ = server.StateMemberAdd(&discordgo.Member{GuildID: guild.ID, User: server.BotUser, Roles: []string{botRole.ID}})
+
+	for _, user := range []*discordgo.User{admin, client1, client2} {
+		server.AddMember(guild.ID, user.ID)
+	}
+
+	return server, tokens
+}
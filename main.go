@@ -8,21 +8,35 @@ import (
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/jonathroth/temp-chat/bot"
+	"github.com/jonathroth/temp-chat/consts"
 	"github.com/jonathroth/temp-chat/state"
 )
 
+const (
+	databaseTypePostgres = "postgres"
+	databaseTypeSQLite   = "sqlite"
+
+	defaultSQLitePath = "temp-chat.db"
+
+	// migrateCommand applies the database migrations and exits, without connecting to discord.
+	// The bot also applies them on startup, the command allows migrating before a new version is deployed.
+	migrateCommand = "migrate"
+)
+
 var (
 	discordToken = os.Getenv("DISCORD_TOKEN")
-	postgresAddr = os.Getenv("DATABASE_URL")
+	databaseType = os.Getenv("DATABASE_TYPE")
+	databaseURL  = os.Getenv("DATABASE_URL")
 )
 
 func main() {
-	if discordToken == "" {
-		log.Fatalf("A discord token is required to run")
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1])
+		return
 	}
 
-	if postgresAddr == "" {
-		log.Fatalf("A PostgreSQL database URL is required to run")
+	if discordToken == "" {
+		log.Fatalf("A discord token is required to run")
 	}
 
 	session, err := discordgo.New("Bot " + discordToken)
@@ -30,7 +44,9 @@ func main() {
 		log.Fatalf("Failed initializing discord connection: %v", err)
 	}
 
-	serversProvider, err := state.NewPostgresServersProvider(postgresAddr)
+	session.Identify.Intents = bot.Intents
+
+	serversProvider, err := newServersProvider()
 	if err != nil {
 		log.Fatalf("Failed connecting to the database: %v", err)
 	}
@@ -40,12 +56,18 @@ func main() {
 		log.Fatalf("Failed initializing server store: %v", err)
 	}
 
-	tempChannelBot, err := bot.NewTempChannelBot(session, store)
+	tempChannelBot, err := bot.NewTempChannelBot(bot.NewDiscordSession(session), store, serversProvider, serversProvider, serversProvider)
 	if err != nil {
 		log.Fatalf("Failed initializing bot: %v", err)
 	}
 
+	stopServerPurge := state.StartServerPurge(serversProvider, consts.RemovedServerRetention, consts.RemovedServerPurgeInterval)
+	stopChannelExpiry := tempChannelBot.StartChannelExpiry(consts.ChannelExpiryCheckInterval)
+
 	waitForBot(session, tempChannelBot)
+	stopChannelExpiry()
+	tempChannelBot.Close()
+	stopServerPurge()
 
 	err = session.Close()
 	if err != nil {
@@ -53,9 +75,50 @@ func main() {
 	}
 }
 
-func waitForBot(session *discordgo.Session, tempChannelBot *bot.TempChannelBot) {
-	defer tempChannelBot.CleanChannels()
+// runCommand runs a command line subcommand instead of the bot.
+func runCommand(command string) {
+	switch command {
+	case migrateCommand:
+		serversProvider, err := newServersProvider()
+		if err != nil {
+			log.Fatalf("Failed migrating the database: %v", err)
+		}
+
+		log.Printf("The database schema is at version %v", serversProvider.SchemaVersion())
 
+	default:
+		log.Fatalf("Unknown command %q, expected %q or no command to run the bot", command, migrateCommand)
+	}
+}
+
+// newServersProvider connects to the database chosen by $DATABASE_TYPE, PostgreSQL by default.
+// $DATABASE_URL is the PostgreSQL database URL, or the path of the SQLite database file.
+func newServersProvider() (*state.SQLServersProvider, error) {
+	switch databaseType {
+	case "", databaseTypePostgres:
+		if databaseURL == "" {
+			log.Fatalf("A PostgreSQL database URL is required to run")
+		}
+
+		return state.NewPostgresServersProvider(databaseURL)
+
+	case databaseTypeSQLite:
+		if databaseURL == "" {
+			databaseURL = defaultSQLitePath
+		}
+
+		return state.NewSQLiteServersProvider(databaseURL)
+	}
+
+	log.Fatalf("Unknown database type %q, expected %q or %q", databaseType, databaseTypePostgres, databaseTypeSQLite)
+	return nil, nil
+}
+
+func waitForBot(session *discordgo.Session, tempChannelBot *bot.TempChannelBot) {
+	session.AddHandler(tempChannelBot.Ready)
+	session.AddHandler(tempChannelBot.GuildCreate)
+	session.AddHandler(tempChannelBot.GuildDelete)
+	session.AddHandler(tempChannelBot.InteractionCreate)
 	session.AddHandler(tempChannelBot.MessageCreate)
 	session.AddHandler(tempChannelBot.ChannelDelete)
 	session.AddHandler(tempChannelBot.VoiceStatusUpdate)
@@ -0,0 +1,598 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The names of the settings in the audit log.
+const (
+	SettingTempChannelCategory   = "category"
+	SettingCommandPrefix         = "prefix"
+	SettingCommandChannel        = "command-channel"
+	SettingCustomCommand         = "mkch-name"
+	SettingOrphanChannelPolicy   = "orphan-policy"
+	SettingAutoCreate            = "auto-create"
+	SettingAutoCreateChannels    = "auto-create-channels"
+	SettingChannelNameTemplate   = "channel-name"
+	SettingArchiveFormat         = "archive"
+	SettingArchiveChannel        = "archive-channel"
+	SettingDeletionGracePeriod   = "grace-period"
+	SettingMaxChannelAge         = "max-age"
+	SettingMaxIdleTime           = "max-idle"
+	SettingExpiryAction          = "expiry-action"
+	SettingMkchCooldown          = "mkch-cooldown"
+	SettingMaxTempChannels       = "max-channels"
+	SettingCategoryRoutes        = "category-routes"
+	SettingHistoryVisibility     = "history"
+	SettingVoiceChannelPolicies  = "voice-channel-policies"
+	SettingCommandPermissionRule = "permission-rule"
+)
+
+// ConfigChange is a single change to the settings of a server, as kept in the audit log.
+// Values are formatted as text, an empty value means the setting was unset.
+// A permission rule change has the rule as its new value if it was added, or as its old value if it was removed.
+type ConfigChange struct {
+	ChangeID  int64
+	ServerID  DiscordID
+	UserID    DiscordID
+	Setting   string
+	OldValue  string
+	NewValue  string
+	ChangedAt time.Time
+}
+
+// CommandPermissionRule returns the rule a permission rule change added or removed.
+func (c *ConfigChange) CommandPermissionRule() (rule CommandPermissionRule, added bool, err error) {
+	if c.Setting != SettingCommandPermissionRule {
+		return CommandPermissionRule{}, false, fmt.Errorf("Change %v isn't of a permission rule", c.ChangeID)
+	}
+
+	if c.OldValue == "" {
+		rule, err = parseCommandPermissionRule(c.NewValue)
+		return rule, true, err
+	}
+
+	rule, err = parseCommandPermissionRule(c.OldValue)
+	return rule, false, err
+}
+
+// auditedSetting reads and writes a setting as the text kept in the audit log.
+type auditedSetting struct {
+	get func(config *ServerConfig) string
+	set func(config *ServerConfig, value string) error
+}
+
+var auditedSettings = map[string]auditedSetting{
+	SettingTempChannelCategory: {
+		get: func(config *ServerConfig) string { return formatOptionalID(config.TempChannelCategoryID) },
+		set: func(config *ServerConfig, value string) error {
+			id, err := ParseDiscordID(value)
+			if err != nil {
+				return err
+			}
+
+			config.TempChannelCategoryID = id
+			return nil
+		},
+	},
+	SettingCommandPrefix: {
+		get: func(config *ServerConfig) string { return config.CommandPrefix },
+		set: func(config *ServerConfig, value string) error {
+			config.CommandPrefix = value
+			return nil
+		},
+	},
+	SettingCommandChannel: {
+		get: func(config *ServerConfig) string { return formatOptionalID(config.CommandChannelID) },
+		set: func(config *ServerConfig, value string) error {
+			return parseOptionalID(value, &config.CommandChannelID)
+		},
+	},
+	SettingCustomCommand: {
+		get: func(config *ServerConfig) string { return config.CustomCommand },
+		set: func(config *ServerConfig, value string) error {
+			config.CustomCommand = value
+			return nil
+		},
+	},
+	SettingOrphanChannelPolicy: {
+		get: func(config *ServerConfig) string { return config.OrphanChannelPolicy },
+		set: func(config *ServerConfig, value string) error {
+			config.OrphanChannelPolicy = value
+			return nil
+		},
+	},
+	SettingAutoCreate: {
+		get: func(config *ServerConfig) string { return strconv.FormatBool(config.AutoCreate) },
+		set: func(config *ServerConfig, value string) error {
+			autoCreate, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+
+			config.AutoCreate = autoCreate
+			return nil
+		},
+	},
+	SettingAutoCreateChannels: {
+		get: func(config *ServerConfig) string { return FormatDiscordIDs(config.AutoCreateVoiceChannelIDs) },
+		set: func(config *ServerConfig, value string) error {
+			ids, err := ParseDiscordIDs(value)
+			if err != nil {
+				return err
+			}
+
+			config.AutoCreateVoiceChannelIDs = ids
+			return nil
+		},
+	},
+	SettingChannelNameTemplate: {
+		get: func(config *ServerConfig) string { return config.ChannelNameTemplate },
+		set: func(config *ServerConfig, value string) error {
+			config.ChannelNameTemplate = value
+			return nil
+		},
+	},
+	SettingArchiveFormat: {
+		get: func(config *ServerConfig) string { return config.ArchiveFormat },
+		set: func(config *ServerConfig, value string) error {
+			config.ArchiveFormat = value
+			return nil
+		},
+	},
+	SettingArchiveChannel: {
+		get: func(config *ServerConfig) string { return formatOptionalID(config.ArchiveChannelID) },
+		set: func(config *ServerConfig, value string) error {
+			return parseOptionalID(value, &config.ArchiveChannelID)
+		},
+	},
+	SettingDeletionGracePeriod: {
+		get: func(config *ServerConfig) string { return formatOptionalDuration(config.DeletionGracePeriod) },
+		set: func(config *ServerConfig, value string) error {
+			return parseOptionalDuration(value, &config.DeletionGracePeriod)
+		},
+	},
+	SettingMaxChannelAge: {
+		get: func(config *ServerConfig) string { return formatOptionalDuration(config.MaxChannelAge) },
+		set: func(config *ServerConfig, value string) error {
+			return parseOptionalDuration(value, &config.MaxChannelAge)
+		},
+	},
+	SettingMaxIdleTime: {
+		get: func(config *ServerConfig) string { return formatOptionalDuration(config.MaxIdleTime) },
+		set: func(config *ServerConfig, value string) error {
+			return parseOptionalDuration(value, &config.MaxIdleTime)
+		},
+	},
+	SettingExpiryAction: {
+		get: func(config *ServerConfig) string { return config.ExpiryAction },
+		set: func(config *ServerConfig, value string) error {
+			config.ExpiryAction = value
+			return nil
+		},
+	},
+	SettingMkchCooldown: {
+		get: func(config *ServerConfig) string { return formatOptionalDuration(config.MkchCooldown) },
+		set: func(config *ServerConfig, value string) error {
+			return parseOptionalDuration(value, &config.MkchCooldown)
+		},
+	},
+	SettingMaxTempChannels: {
+		get: func(config *ServerConfig) string {
+			if config.MaxTempChannels == 0 {
+				return ""
+			}
+
+			return strconv.Itoa(config.MaxTempChannels)
+		},
+		set: func(config *ServerConfig, value string) error {
+			if value == "" {
+				config.MaxTempChannels = 0
+				return nil
+			}
+
+			count, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+
+			config.MaxTempChannels = count
+			return nil
+		},
+	},
+	SettingCategoryRoutes: {
+		get: func(config *ServerConfig) string { return FormatCategoryRoutes(config.CategoryRoutes) },
+		set: func(config *ServerConfig, value string) error {
+			routes, err := ParseCategoryRoutes(value)
+			if err != nil {
+				return err
+			}
+
+			config.CategoryRoutes = routes
+			return nil
+		},
+	},
+	SettingHistoryVisibility: {
+		get: func(config *ServerConfig) string { return config.HistoryVisibility.String() },
+		set: func(config *ServerConfig, value string) error {
+			visibility, err := ParseHistoryVisibility(value)
+			if err != nil {
+				return err
+			}
+
+			config.HistoryVisibility = visibility
+			return nil
+		},
+	},
+	SettingVoiceChannelPolicies: {
+		get: func(config *ServerConfig) string { return FormatVoiceChannelPolicies(config.VoiceChannelPolicies) },
+		set: func(config *ServerConfig, value string) error {
+			policies, err := ParseVoiceChannelPolicies(value)
+			if err != nil {
+				return err
+			}
+
+			config.VoiceChannelPolicies = policies
+			return nil
+		},
+	},
+}
+
+// ConfigSettings returns the values of all the settings of a server, formatted as in the audit log.
+// Permission rules aren't included, they're returned by CommandPermissionRules.
+func ConfigSettings(data ServerData) map[string]string {
+	config := data.Config()
+	return config.Settings()
+}
+
+// SetConfigSetting sets a setting of a server from a value formatted as in the audit log.
+func SetConfigSetting(data ServerData, name string, value string) error {
+	return data.Update(func(config *ServerConfig) error { return config.SetSetting(name, value) })
+}
+
+// IsConfigSetting returns whether the name is of a setting returned by ConfigSettings.
+func IsConfigSetting(name string) bool {
+	_, found := auditedSettings[name]
+	return found
+}
+
+// Settings returns the values of all the settings, formatted as in the audit log.
+func (c *ServerConfig) Settings() map[string]string {
+	values := make(map[string]string, len(auditedSettings))
+	for name, setting := range auditedSettings {
+		values[name] = setting.get(c)
+	}
+
+	return values
+}
+
+// SetSetting sets a setting from a value formatted as in the audit log.
+func (c *ServerConfig) SetSetting(name string, value string) error {
+	setting, found := auditedSettings[name]
+	if !found {
+		return fmt.Errorf("Unknown setting %q", name)
+	}
+
+	return setting.set(c, value)
+}
+
+func formatOptionalID(id DiscordID) string {
+	if id == DiscordIDNone {
+		return ""
+	}
+
+	return id.RESTAPIFormat()
+}
+
+func parseOptionalID(value string, id *DiscordID) error {
+	if value == "" {
+		*id = DiscordIDNone
+		return nil
+	}
+
+	parsed, err := ParseDiscordID(value)
+	if err != nil {
+		return err
+	}
+
+	*id = parsed
+	return nil
+}
+
+func formatCommandPermissionRule(rule CommandPermissionRule) string {
+	return fmt.Sprintf("%v %v %v %v", rule.Command, rule.Type, rule.TargetID, rule.Permissions)
+}
+
+func parseCommandPermissionRule(value string) (CommandPermissionRule, error) {
+	parts := strings.Split(value, " ")
+	if len(parts) != 4 {
+		return CommandPermissionRule{}, fmt.Errorf("Invalid permission rule %q", value)
+	}
+
+	targetID, err := ParseDiscordID(parts[2])
+	if err != nil {
+		return CommandPermissionRule{}, err
+	}
+
+	permissions, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return CommandPermissionRule{}, err
+	}
+
+	return CommandPermissionRule{Command: parts[0], Type: parts[1], TargetID: targetID, Permissions: permissions}, nil
+}
+
+// IsConfigChangeReverted returns whether the changed setting already has the value it had before the change.
+func IsConfigChangeReverted(data ServerData, change *ConfigChange) (bool, error) {
+	if change.Setting == SettingCommandPermissionRule {
+		rule, added, err := change.CommandPermissionRule()
+		if err != nil {
+			return false, err
+		}
+
+		for _, existingRule := range data.CommandPermissionRules() {
+			if existingRule == rule {
+				return !added, nil
+			}
+		}
+
+		return added, nil
+	}
+
+	setting, found := auditedSettings[change.Setting]
+	if !found {
+		return false, fmt.Errorf("Unknown setting %q", change.Setting)
+	}
+
+	config := data.Config()
+	return setting.get(&config) == change.OldValue, nil
+}
+
+// RevertConfigChange sets the changed setting back to the value it had before the change.
+func RevertConfigChange(data ServerData, change *ConfigChange) error {
+	if change.Setting == SettingCommandPermissionRule {
+		rule, added, err := change.CommandPermissionRule()
+		if err != nil {
+			return err
+		}
+
+		if added {
+			return data.RemoveCommandPermissionRule(rule)
+		}
+
+		return data.AddCommandPermissionRule(rule)
+	}
+
+	return SetConfigSetting(data, change.Setting, change.OldValue)
+}
+
+// AuditedServerData records the changes a user makes to the server's settings in the audit log.
+// Changes that don't change the setting's value aren't recorded.
+type AuditedServerData struct {
+	ServerData
+
+	changes ConfigChangeStore
+	userID  DiscordID
+}
+
+// NewAuditedServerData initializes a new instance of AuditedServerData, for changes made by the given user.
+func NewAuditedServerData(data ServerData, changes ConfigChangeStore, userID DiscordID) *AuditedServerData {
+	return &AuditedServerData{ServerData: data, changes: changes, userID: userID}
+}
+
+// exclusiveServerData is server data that synchronizes its access, such as SyncServerData.
+type exclusiveServerData interface {
+	// Exclusive runs the function with the wrapped data, while no one else reads or changes it.
+	Exclusive(run func(data ServerData) error) error
+}
+
+// audit runs a setter, and records the changes to the settings if it succeeded.
+// The settings are read before and after the setter without other changes in between, so concurrent changes aren't mixed up.
+func (d *AuditedServerData) audit(set func(data ServerData) error) error {
+	var oldValues, newValues map[string]string
+	run := func(data ServerData) error {
+		oldValues = ConfigSettings(data)
+		err := set(data)
+		if err != nil {
+			return err
+		}
+
+		newValues = ConfigSettings(data)
+		return nil
+	}
+
+	var err error
+	if exclusive, ok := d.ServerData.(exclusiveServerData); ok {
+		err = exclusive.Exclusive(run)
+	} else {
+		err = run(d.ServerData)
+	}
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(newValues))
+	for name := range newValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if newValues[name] != oldValues[name] {
+			d.record(name, oldValues[name], newValues[name])
+		}
+	}
+
+	return nil
+}
+
+// Update changes several settings at once, each changed setting is recorded as its own change.
+func (d *AuditedServerData) Update(update func(config *ServerConfig) error) error {
+	return d.audit(func(data ServerData) error { return data.Update(update) })
+}
+
+// The setting was already changed, so failing to record it only affects the audit log.
+func (d *AuditedServerData) record(setting string, oldValue string, newValue string) {
+	err := d.changes.AddConfigChange(&ConfigChange{
+		ServerID:  d.ServerID(),
+		UserID:    d.userID,
+		Setting:   setting,
+		OldValue:  oldValue,
+		NewValue:  newValue,
+		ChangedAt: time.Now(),
+	})
+	if err != nil {
+		log.Printf("Failed to record the change of %v in server %v: %v", setting, d.ServerID(), err)
+	}
+}
+
+// SetTempChannelCategoryID sets a new channel category.
+func (d *AuditedServerData) SetTempChannelCategoryID(value DiscordID) error {
+	return d.audit(func(data ServerData) error { return data.SetTempChannelCategoryID(value) })
+}
+
+// SetCustomCommandPrefix changes the command prefix to the a custom prefix.
+func (d *AuditedServerData) SetCustomCommandPrefix(value string) error {
+	return d.audit(func(data ServerData) error { return data.SetCustomCommandPrefix(value) })
+}
+
+// ResetCommandPrefix resets the prefix to the default value.
+func (d *AuditedServerData) ResetCommandPrefix() error {
+	return d.audit(ServerData.ResetCommandPrefix)
+}
+
+// SetCommandChannelID sets a specific command channel.
+func (d *AuditedServerData) SetCommandChannelID(value DiscordID) error {
+	return d.audit(func(data ServerData) error { return data.SetCommandChannelID(value) })
+}
+
+// ClearCommandChannelID removes the specific command channel.
+func (d *AuditedServerData) ClearCommandChannelID() error {
+	return d.audit(ServerData.ClearCommandChannelID)
+}
+
+// SetCustomCommand sets the replacement name for the make-temp-channel command.
+func (d *AuditedServerData) SetCustomCommand(value string) error {
+	return d.audit(func(data ServerData) error { return data.SetCustomCommand(value) })
+}
+
+// ResetCustomCommand resets the make-temp-channel command name to default.
+func (d *AuditedServerData) ResetCustomCommand() error {
+	return d.audit(ServerData.ResetCustomCommand)
+}
+
+// SetOrphanChannelPolicy sets the policy for untracked text channels in the temp category.
+func (d *AuditedServerData) SetOrphanChannelPolicy(value string) error {
+	return d.audit(func(data ServerData) error { return data.SetOrphanChannelPolicy(value) })
+}
+
+// ResetOrphanChannelPolicy resets the orphan channel policy to the default value.
+func (d *AuditedServerData) ResetOrphanChannelPolicy() error {
+	return d.audit(ServerData.ResetOrphanChannelPolicy)
+}
+
+// SetAutoCreate turns the automatic temp channel creation on or off.
+func (d *AuditedServerData) SetAutoCreate(value bool) error {
+	return d.audit(func(data ServerData) error { return data.SetAutoCreate(value) })
+}
+
+// SetAutoCreateVoiceChannelIDs limits the automatic creation to specific voice channels.
+func (d *AuditedServerData) SetAutoCreateVoiceChannelIDs(value []DiscordID) error {
+	return d.audit(func(data ServerData) error { return data.SetAutoCreateVoiceChannelIDs(value) })
+}
+
+// ClearAutoCreateVoiceChannelIDs makes the automatic creation apply to all voice channels.
+func (d *AuditedServerData) ClearAutoCreateVoiceChannelIDs() error {
+	return d.audit(ServerData.ClearAutoCreateVoiceChannelIDs)
+}
+
+// SetChannelNameTemplate sets the template temp channel names are created from.
+func (d *AuditedServerData) SetChannelNameTemplate(value string) error {
+	return d.audit(func(data ServerData) error { return data.SetChannelNameTemplate(value) })
+}
+
+// ResetChannelNameTemplate resets the temp channel names to random silly names.
+func (d *AuditedServerData) ResetChannelNameTemplate() error {
+	return d.audit(ServerData.ResetChannelNameTemplate)
+}
+
+// SetArchiveFormat sets the transcript format, and enables archiving.
+func (d *AuditedServerData) SetArchiveFormat(value string) error {
+	return d.audit(func(data ServerData) error { return data.SetArchiveFormat(value) })
+}
+
+// DisableArchive stops archiving temp channel transcripts.
+func (d *AuditedServerData) DisableArchive() error {
+	return d.audit(ServerData.DisableArchive)
+}
+
+// SetArchiveChannelID sets the channel transcripts are posted to.
+func (d *AuditedServerData) SetArchiveChannelID(value DiscordID) error {
+	return d.audit(func(data ServerData) error { return data.SetArchiveChannelID(value) })
+}
+
+// ClearArchiveChannelID makes transcripts be kept in the database instead of posted to a channel.
+func (d *AuditedServerData) ClearArchiveChannelID() error {
+	return d.audit(ServerData.ClearArchiveChannelID)
+}
+
+// SetDeletionGracePeriod sets how long an empty temp channel is kept before it's deleted.
+func (d *AuditedServerData) SetDeletionGracePeriod(value time.Duration) error {
+	return d.audit(func(data ServerData) error { return data.SetDeletionGracePeriod(value) })
+}
+
+// ResetDeletionGracePeriod makes empty temp channels be deleted immediately.
+func (d *AuditedServerData) ResetDeletionGracePeriod() error {
+	return d.audit(ServerData.ResetDeletionGracePeriod)
+}
+
+// AddCommandPermissionRule allows the rule's target to run the rule's command.
+func (d *AuditedServerData) AddCommandPermissionRule(rule CommandPermissionRule) error {
+	err := d.ServerData.AddCommandPermissionRule(rule)
+	if err != nil {
+		return err
+	}
+
+	d.record(SettingCommandPermissionRule, "", formatCommandPermissionRule(rule))
+	return nil
+}
+
+// RemoveCommandPermissionRule removes a rule added by AddCommandPermissionRule.
+func (d *AuditedServerData) RemoveCommandPermissionRule(rule CommandPermissionRule) error {
+	err := d.ServerData.RemoveCommandPermissionRule(rule)
+	if err != nil {
+		return err
+	}
+
+	d.record(SettingCommandPermissionRule, formatCommandPermissionRule(rule), "")
+	return nil
+}
+
+// formatOptionalDuration formats a duration that's turned off when it's 0, such as the grace period.
+func formatOptionalDuration(value time.Duration) string {
+	if value == 0 {
+		return ""
+	}
+
+	return value.String()
+}
+
+func parseOptionalDuration(value string, duration *time.Duration) error {
+	if value == "" {
+		*duration = 0
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	*duration = parsed
+	return nil
+}
@@ -0,0 +1,165 @@
+package state_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	testServerID   = state.DiscordID(1)
+	testCategoryID = state.DiscordID(2)
+	testUserID     = state.DiscordID(3)
+)
+
+type AuditedServerDataTestSuite struct {
+	suite.Suite
+
+	provider *state.MemoryServersProvider
+	data     *state.AuditedServerData
+}
+
+func TestAuditedServerDataTestSuite(t *testing.T) {
+	suite.Run(t, &AuditedServerDataTestSuite{})
+}
+
+func (s *AuditedServerDataTestSuite) SetupTest() {
+	s.provider = state.NewMemoryServersProvider()
+	serverData, err := s.provider.AddServer(testServerID, testCategoryID)
+	s.Require().NoError(err)
+	s.data = state.NewAuditedServerData(serverData, s.provider, testUserID)
+}
+
+func (s *AuditedServerDataTestSuite) changes() []*state.ConfigChange {
+	changes, err := s.provider.ConfigChanges(testServerID, 0, 100)
+	s.Require().NoError(err)
+	return changes
+}
+
+func (s *AuditedServerDataTestSuite) TestRecordsChanges() {
+	s.Require().NoError(s.data.SetCustomCommandPrefix("?"))
+	s.Require().NoError(s.data.SetCommandChannelID(10))
+	s.Require().NoError(s.data.SetDeletionGracePeriod(90 * time.Second))
+
+	changes := s.changes()
+	s.Require().Len(changes, 3)
+	s.Equal(state.SettingDeletionGracePeriod, changes[0].Setting)
+	s.Equal("", changes[0].OldValue)
+	s.Equal("1m30s", changes[0].NewValue)
+	s.Equal(state.SettingCommandChannel, changes[1].Setting)
+	s.Equal("10", changes[1].NewValue)
+	s.Equal(state.SettingCommandPrefix, changes[2].Setting)
+	s.Equal(consts.DefaultCommandPrefix, changes[2].OldValue)
+	s.Equal("?", changes[2].NewValue)
+	s.Equal(testUserID, changes[2].UserID)
+	s.Equal(testServerID, changes[2].ServerID)
+}
+
+func (s *AuditedServerDataTestSuite) TestRecordsEachSettingOfUpdate() {
+	s.Require().NoError(s.data.Update(func(config *state.ServerConfig) error {
+		config.CommandPrefix = "?"
+		config.ArchiveFormat = consts.ArchiveFormatHTML
+		return nil
+	}))
+
+	changes := s.changes()
+	s.Require().Len(changes, 2)
+	s.Equal(state.SettingCommandPrefix, changes[0].Setting)
+	s.Equal(state.SettingArchiveFormat, changes[1].Setting)
+	s.Equal("", changes[1].OldValue)
+	s.Equal(consts.ArchiveFormatHTML, changes[1].NewValue)
+}
+
+func (s *AuditedServerDataTestSuite) TestUnchangedValueIsNotRecorded() {
+	s.Require().NoError(s.data.ResetCommandPrefix())
+	s.Require().NoError(s.data.SetAutoCreate(false))
+	s.Empty(s.changes())
+}
+
+func (s *AuditedServerDataTestSuite) TestRevertSetting() {
+	s.Require().NoError(s.data.SetCustomCommandPrefix("?"))
+	s.Require().NoError(s.data.SetCustomCommandPrefix("$"))
+	change := s.changes()[1]
+
+	reverted, err := state.IsConfigChangeReverted(s.data, change)
+	s.Require().NoError(err)
+	s.False(reverted)
+
+	s.Require().NoError(state.RevertConfigChange(s.data, change))
+	s.Equal(consts.DefaultCommandPrefix, s.data.CommandPrefix())
+	s.False(s.data.HasDifferentPrefix())
+
+	reverted, err = state.IsConfigChangeReverted(s.data, change)
+	s.Require().NoError(err)
+	s.True(reverted)
+	s.Len(s.changes(), 3, "The revert wasn't recorded")
+}
+
+func (s *AuditedServerDataTestSuite) TestRevertPermissionRule() {
+	rule := state.CommandPermissionRule{Command: "mkch", Type: consts.PermissionRuleRole, TargetID: 20}
+	s.Require().NoError(s.data.AddCommandPermissionRule(rule))
+	added := s.changes()[0]
+
+	addedRule, isAdded, err := added.CommandPermissionRule()
+	s.Require().NoError(err)
+	s.True(isAdded)
+	s.Equal(rule, addedRule)
+
+	s.Require().NoError(state.RevertConfigChange(s.data, added))
+	s.Empty(s.data.CommandPermissionRules())
+
+	removed := s.changes()[0]
+	_, isAdded, err = removed.CommandPermissionRule()
+	s.Require().NoError(err)
+	s.False(isAdded)
+
+	s.Require().NoError(state.RevertConfigChange(s.data, removed))
+	s.Equal([]state.CommandPermissionRule{rule}, s.data.CommandPermissionRules())
+}
+
+func (s *AuditedServerDataTestSuite) TestConcurrentChangesAreRecordedByTheirUser() {
+	store, err := state.NewSyncServerStore(s.provider)
+	s.Require().NoError(err)
+	serverData, found := store.Server(testServerID)
+	s.Require().True(found)
+
+	const otherUserID = state.DiscordID(4)
+	maxChannels := state.NewAuditedServerData(serverData, s.provider, testUserID)
+	commandChannels := state.NewAuditedServerData(serverData, s.provider, otherUserID)
+
+	const changes = 200
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= changes; i++ {
+			maxTempChannels := i
+			s.NoError(maxChannels.Update(func(config *state.ServerConfig) error {
+				config.MaxTempChannels = maxTempChannels
+				return nil
+			}))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= changes; i++ {
+			s.NoError(commandChannels.SetCommandChannelID(state.DiscordID(10 + i)))
+		}
+	}()
+	wg.Wait()
+
+	recorded, err := s.provider.ConfigChanges(testServerID, 0, 2*changes)
+	s.Require().NoError(err)
+	s.Len(recorded, 2*changes)
+	for _, change := range recorded {
+		if change.UserID == testUserID {
+			s.Equal(state.SettingMaxTempChannels, change.Setting, "Another user's change was recorded")
+		} else {
+			s.Equal(state.SettingCommandChannel, change.Setting, "Another user's change was recorded")
+		}
+	}
+}
@@ -0,0 +1,582 @@
+package state
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jonathroth/temp-chat/consts"
+)
+
+// MemoryServersProvider is a ServerProvider implementation that keeps everything in memory.
+// Nothing survives a restart, it's meant for tests and for trying the bot out.
+type MemoryServersProvider struct {
+	mutex        sync.Mutex
+	servers      map[DiscordID]*MemoryServerData
+	removed      map[DiscordID]*removedMemoryServer
+	tempChannels map[DiscordID]*TempChannelData
+	transcripts  []*TranscriptData
+
+	configChanges      []*ConfigChange
+	lastConfigChangeID int64
+}
+
+// NewMemoryServersProvider initializes a new instance of MemoryServersProvider.
+func NewMemoryServersProvider() *MemoryServersProvider {
+	return &MemoryServersProvider{
+		servers:      map[DiscordID]*MemoryServerData{},
+		removed:      map[DiscordID]*removedMemoryServer{},
+		tempChannels: map[DiscordID]*TempChannelData{},
+	}
+}
+
+// Servers returns the list of all servers managed by the bot.
+func (p *MemoryServersProvider) Servers() (ServersData, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	result := ServersData{}
+	for serverID, serverData := range p.servers {
+		result[serverID] = serverData
+	}
+
+	return result, nil
+}
+
+// AddServer adds a new server to the store.
+// A server that was removed and not purged yet gets its settings back, with the new category.
+func (p *MemoryServersProvider) AddServer(serverID DiscordID, tempChannelCategoryID DiscordID) (ServerData, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, found := p.servers[serverID]; found {
+		return nil, errors.New("Server already exists")
+	}
+
+	serverData := newMemoryServerData(serverID, tempChannelCategoryID)
+	if removed, found := p.removed[serverID]; found {
+		serverData = removed.data
+		serverData.config.TempChannelCategoryID = tempChannelCategoryID
+		delete(p.removed, serverID)
+	}
+
+	p.servers[serverID] = serverData
+	return serverData, nil
+}
+
+// removedMemoryServer is a server the bot was removed from, kept until it's purged.
+type removedMemoryServer struct {
+	data      *MemoryServerData
+	removedAt time.Time
+}
+
+// RemoveServer marks a server the bot was removed from, its data is kept until it's purged.
+func (p *MemoryServersProvider) RemoveServer(serverID DiscordID) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	serverData, found := p.servers[serverID]
+	if !found {
+		return errors.New("Server not in store")
+	}
+
+	delete(p.servers, serverID)
+	p.removed[serverID] = &removedMemoryServer{data: serverData, removedAt: time.Now()}
+	return nil
+}
+
+// RestoreServer returns a removed server to the store, if it wasn't purged yet.
+func (p *MemoryServersProvider) RestoreServer(serverID DiscordID) (ServerData, bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	removed, found := p.removed[serverID]
+	if !found {
+		return nil, false, nil
+	}
+
+	delete(p.removed, serverID)
+	p.servers[serverID] = removed.data
+	return removed.data, true, nil
+}
+
+// PurgeRemovedServers deletes all the data of the servers removed before the given time.
+// Returns the number of purged servers.
+func (p *MemoryServersProvider) PurgeRemovedServers(removedBefore time.Time) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	purged := 0
+	for serverID, removed := range p.removed {
+		if !removed.removedAt.Before(removedBefore) {
+			continue
+		}
+
+		delete(p.removed, serverID)
+		for channelID, data := range p.tempChannels {
+			if data.ServerID == serverID {
+				delete(p.tempChannels, channelID)
+			}
+		}
+
+		configChanges := []*ConfigChange{}
+		for _, change := range p.configChanges {
+			if change.ServerID != serverID {
+				configChanges = append(configChanges, change)
+			}
+		}
+
+		p.configChanges = configChanges
+		transcripts := []*TranscriptData{}
+		for _, transcript := range p.transcripts {
+			if transcript.ServerID != serverID {
+				transcripts = append(transcripts, transcript)
+			}
+		}
+
+		p.transcripts = transcripts
+		purged++
+	}
+
+	return purged, nil
+}
+
+// TempChannels returns all the temp channels that were active when the bot last ran.
+func (p *MemoryServersProvider) TempChannels() ([]*TempChannelData, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	result := []*TempChannelData{}
+	for _, data := range p.tempChannels {
+		copied := *data
+		copied.Members = append([]DiscordID{}, data.Members...)
+		copied.Invited = append([]DiscordID{}, data.Invited...)
+		result = append(result, &copied)
+	}
+
+	return result, nil
+}
+
+// AddTempChannel saves a newly created temp channel.
+func (p *MemoryServersProvider) AddTempChannel(data *TempChannelData) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, found := p.tempChannels[data.ChannelID]; found {
+		return errors.New("Temp channel already exists")
+	}
+
+	copied := *data
+	copied.Members = append([]DiscordID{}, data.Members...)
+	copied.Invited = append([]DiscordID{}, data.Invited...)
+	p.tempChannels[data.ChannelID] = &copied
+	return nil
+}
+
+// SetTempChannelMembers replaces the list of users that have access to the temp channel.
+func (p *MemoryServersProvider) SetTempChannelMembers(channelID DiscordID, members []DiscordID) error {
+	return p.updateTempChannel(channelID, func(data *TempChannelData) {
+		data.Members = append([]DiscordID{}, members...)
+	})
+}
+
+// SetTempChannelOwner changes the user that controls the temp channel.
+func (p *MemoryServersProvider) SetTempChannelOwner(channelID DiscordID, ownerID DiscordID) error {
+	return p.updateTempChannel(channelID, func(data *TempChannelData) {
+		data.OwnerID = ownerID
+	})
+}
+
+// SetTempChannelLocked changes whether the temp channel gives access to users that join its voice chat.
+func (p *MemoryServersProvider) SetTempChannelLocked(channelID DiscordID, locked bool) error {
+	return p.updateTempChannel(channelID, func(data *TempChannelData) {
+		data.Locked = locked
+	})
+}
+
+// SetTempChannelInvited replaces the list of members the owner invited to the temp channel.
+func (p *MemoryServersProvider) SetTempChannelInvited(channelID DiscordID, invited []DiscordID) error {
+	return p.updateTempChannel(channelID, func(data *TempChannelData) {
+		data.Invited = append([]DiscordID{}, invited...)
+	})
+}
+
+func (p *MemoryServersProvider) updateTempChannel(channelID DiscordID, update func(data *TempChannelData)) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	data, found := p.tempChannels[channelID]
+	if !found {
+		return errors.New("Temp channel not in store")
+	}
+
+	update(data)
+	return nil
+}
+
+// RemoveTempChannel removes a deleted temp channel.
+func (p *MemoryServersProvider) RemoveTempChannel(channelID DiscordID) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	delete(p.tempChannels, channelID)
+	return nil
+}
+
+// AddTranscript saves the transcript of a deleted temp channel.
+func (p *MemoryServersProvider) AddTranscript(data *TranscriptData) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.transcripts = append(p.transcripts, data)
+	return nil
+}
+
+// Transcripts returns the saved transcripts, from oldest to newest.
+func (p *MemoryServersProvider) Transcripts() []*TranscriptData {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]*TranscriptData{}, p.transcripts...)
+}
+
+// AddConfigChange records a change to the settings of a server, and sets its ChangeID.
+func (p *MemoryServersProvider) AddConfigChange(change *ConfigChange) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.lastConfigChangeID++
+	change.ChangeID = p.lastConfigChangeID
+	copied := *change
+	p.configChanges = append(p.configChanges, &copied)
+	return nil
+}
+
+// ConfigChanges returns the changes to the settings of a server from newest to oldest, skipping the newest offset changes.
+func (p *MemoryServersProvider) ConfigChanges(serverID DiscordID, offset int, limit int) ([]*ConfigChange, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	result := []*ConfigChange{}
+	for i := len(p.configChanges) - 1; i >= 0 && len(result) < limit; i-- {
+		if p.configChanges[i].ServerID != serverID {
+			continue
+		}
+
+		if offset > 0 {
+			offset--
+			continue
+		}
+
+		copied := *p.configChanges[i]
+		result = append(result, &copied)
+	}
+
+	return result, nil
+}
+
+// ConfigChange returns a specific change to the settings of a server.
+func (p *MemoryServersProvider) ConfigChange(serverID DiscordID, changeID int64) (*ConfigChange, bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, change := range p.configChanges {
+		if change.ServerID == serverID && change.ChangeID == changeID {
+			copied := *change
+			return &copied, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// MemoryServerData is the data of a single server, kept in memory only.
+type MemoryServerData struct {
+	serverID           DiscordID
+	config             ServerConfig
+	commandPermissions []CommandPermissionRule
+}
+
+func newMemoryServerData(serverID, categoryID DiscordID) *MemoryServerData {
+	return &MemoryServerData{serverID: serverID, config: defaultServerConfig(categoryID)}
+}
+
+// ServerID returns the ID of the server whose data is saved in this object.
+func (d *MemoryServerData) ServerID() DiscordID {
+	return d.serverID
+}
+
+// Config returns a copy of the server's settings.
+func (d *MemoryServerData) Config() ServerConfig {
+	return d.config.copy()
+}
+
+// Update changes several settings at once. The update function changes a copy of the settings,
+// which is checked and kept as a whole only if the function succeeds.
+func (d *MemoryServerData) Update(update func(config *ServerConfig) error) error {
+	config := d.config.copy()
+	err := update(&config)
+	if err != nil {
+		return err
+	}
+
+	err = config.check()
+	if err != nil {
+		return err
+	}
+
+	d.config = config
+	return nil
+}
+
+// TempChannelCategoryID is the category Discord ID of the category to create temporary chat channels in.
+func (d *MemoryServerData) TempChannelCategoryID() DiscordID {
+	return d.config.TempChannelCategoryID
+}
+
+// SetTempChannelCategoryID sets a new channel category.
+func (d *MemoryServerData) SetTempChannelCategoryID(value DiscordID) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.TempChannelCategoryID = value
+		return nil
+	})
+}
+
+// CommandPrefix returns the server's specific command prefix.
+func (d *MemoryServerData) CommandPrefix() string {
+	return d.config.CommandPrefix
+}
+
+// SetCustomCommandPrefix changes the command prefix to the a custom prefix.
+func (d *MemoryServerData) SetCustomCommandPrefix(value string) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.CommandPrefix = value
+		return nil
+	})
+}
+
+// ResetCommandPrefix resets the prefix to the default value.
+func (d *MemoryServerData) ResetCommandPrefix() error {
+	return d.SetCustomCommandPrefix(consts.DefaultCommandPrefix)
+}
+
+// HasDifferentPrefix returns whether the prefix was changed or not.
+func (d *MemoryServerData) HasDifferentPrefix() bool {
+	return d.config.CommandPrefix != consts.DefaultCommandPrefix
+}
+
+// CommandChannelID is the ID of the channel the bot will exclusively receive commands on.
+func (d *MemoryServerData) CommandChannelID() DiscordID {
+	return d.config.CommandChannelID
+}
+
+// SetCommandChannelID sets a specific command channel.
+func (d *MemoryServerData) SetCommandChannelID(value DiscordID) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.CommandChannelID = value
+		return nil
+	})
+}
+
+// ClearCommandChannelID removes the specific command channel.
+func (d *MemoryServerData) ClearCommandChannelID() error {
+	return d.SetCommandChannelID(DiscordIDNone)
+}
+
+// HasCommandChannelID returns whether the specific command channel is set.
+func (d *MemoryServerData) HasCommandChannelID() bool {
+	return d.config.CommandChannelID != DiscordIDNone
+}
+
+// CustomCommand is a replacement name for the make-temp-channel command name.
+func (d *MemoryServerData) CustomCommand() string {
+	return d.config.CustomCommand
+}
+
+// SetCustomCommand sets the replacement name for the make-temp-channel command.
+func (d *MemoryServerData) SetCustomCommand(value string) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.CustomCommand = value
+		return nil
+	})
+}
+
+// ResetCustomCommand resets the make-temp-channel command name to default.
+func (d *MemoryServerData) ResetCustomCommand() error {
+	return d.SetCustomCommand("")
+}
+
+// HasCustomCommand returns whether the make-temp-channel was assigned an alternative name.
+func (d *MemoryServerData) HasCustomCommand() bool {
+	return d.CustomCommand() != ""
+}
+
+// OrphanChannelPolicy is what the bot does with text channels in the temp category it doesn't track.
+func (d *MemoryServerData) OrphanChannelPolicy() string {
+	return d.config.OrphanChannelPolicy
+}
+
+// SetOrphanChannelPolicy sets the policy for untracked text channels in the temp category.
+func (d *MemoryServerData) SetOrphanChannelPolicy(value string) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.OrphanChannelPolicy = value
+		return nil
+	})
+}
+
+// ResetOrphanChannelPolicy resets the orphan channel policy to the default value.
+func (d *MemoryServerData) ResetOrphanChannelPolicy() error {
+	return d.SetOrphanChannelPolicy(consts.DefaultOrphanPolicy)
+}
+
+// AutoCreate returns whether temp channels are created automatically when users join a voice chat.
+func (d *MemoryServerData) AutoCreate() bool {
+	return d.config.AutoCreate
+}
+
+// SetAutoCreate turns the automatic temp channel creation on or off.
+func (d *MemoryServerData) SetAutoCreate(value bool) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.AutoCreate = value
+		return nil
+	})
+}
+
+// AutoCreateVoiceChannelIDs is the list of voice channels the automatic creation applies to.
+func (d *MemoryServerData) AutoCreateVoiceChannelIDs() []DiscordID {
+	return append([]DiscordID{}, d.config.AutoCreateVoiceChannelIDs...)
+}
+
+// SetAutoCreateVoiceChannelIDs limits the automatic creation to specific voice channels.
+func (d *MemoryServerData) SetAutoCreateVoiceChannelIDs(value []DiscordID) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.AutoCreateVoiceChannelIDs = append([]DiscordID{}, value...)
+		return nil
+	})
+}
+
+// ClearAutoCreateVoiceChannelIDs makes the automatic creation apply to all voice channels.
+func (d *MemoryServerData) ClearAutoCreateVoiceChannelIDs() error {
+	return d.SetAutoCreateVoiceChannelIDs(nil)
+}
+
+// HasAutoCreateVoiceChannelIDs returns whether the automatic creation is limited to specific voice channels.
+func (d *MemoryServerData) HasAutoCreateVoiceChannelIDs() bool {
+	return len(d.config.AutoCreateVoiceChannelIDs) > 0
+}
+
+// ChannelNameTemplate is the template temp channel names are created from.
+func (d *MemoryServerData) ChannelNameTemplate() string {
+	return d.config.ChannelNameTemplate
+}
+
+// SetChannelNameTemplate sets the template temp channel names are created from.
+func (d *MemoryServerData) SetChannelNameTemplate(value string) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.ChannelNameTemplate = value
+		return nil
+	})
+}
+
+// ResetChannelNameTemplate resets the temp channel names to random silly names.
+func (d *MemoryServerData) ResetChannelNameTemplate() error {
+	return d.SetChannelNameTemplate("")
+}
+
+// HasChannelNameTemplate returns whether a custom channel name template was set.
+func (d *MemoryServerData) HasChannelNameTemplate() bool {
+	return d.config.ChannelNameTemplate != ""
+}
+
+// ArchiveFormat is the format temp channel transcripts are archived in before the channels are deleted.
+func (d *MemoryServerData) ArchiveFormat() string {
+	return d.config.ArchiveFormat
+}
+
+// SetArchiveFormat sets the transcript format, and enables archiving.
+func (d *MemoryServerData) SetArchiveFormat(value string) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.ArchiveFormat = value
+		return nil
+	})
+}
+
+// DisableArchive stops archiving temp channel transcripts.
+func (d *MemoryServerData) DisableArchive() error {
+	return d.SetArchiveFormat("")
+}
+
+// ArchiveEnabled returns whether temp channel transcripts are archived.
+func (d *MemoryServerData) ArchiveEnabled() bool {
+	return d.config.ArchiveFormat != ""
+}
+
+// ArchiveChannelID is the ID of the channel transcripts are posted to.
+func (d *MemoryServerData) ArchiveChannelID() DiscordID {
+	return d.config.ArchiveChannelID
+}
+
+// SetArchiveChannelID sets the channel transcripts are posted to.
+func (d *MemoryServerData) SetArchiveChannelID(value DiscordID) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.ArchiveChannelID = value
+		return nil
+	})
+}
+
+// ClearArchiveChannelID makes transcripts be kept in the database instead of posted to a channel.
+func (d *MemoryServerData) ClearArchiveChannelID() error {
+	return d.SetArchiveChannelID(DiscordIDNone)
+}
+
+// HasArchiveChannelID returns whether transcripts are posted to a channel.
+func (d *MemoryServerData) HasArchiveChannelID() bool {
+	return d.config.ArchiveChannelID != DiscordIDNone
+}
+
+// DeletionGracePeriod is how long an empty temp channel is kept before it's deleted.
+func (d *MemoryServerData) DeletionGracePeriod() time.Duration {
+	return d.config.DeletionGracePeriod
+}
+
+// SetDeletionGracePeriod sets how long an empty temp channel is kept before it's deleted.
+// The period is kept in whole seconds, like in the database.
+func (d *MemoryServerData) SetDeletionGracePeriod(value time.Duration) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.DeletionGracePeriod = value
+		return nil
+	})
+}
+
+// ResetDeletionGracePeriod makes empty temp channels be deleted immediately.
+func (d *MemoryServerData) ResetDeletionGracePeriod() error {
+	return d.SetDeletionGracePeriod(0)
+}
+
+// CommandPermissionRules returns the rules of who may run each command.
+func (d *MemoryServerData) CommandPermissionRules() []CommandPermissionRule {
+	return append([]CommandPermissionRule{}, d.commandPermissions...)
+}
+
+// AddCommandPermissionRule allows the rule's target to run the rule's command.
+func (d *MemoryServerData) AddCommandPermissionRule(rule CommandPermissionRule) error {
+	for _, existingRule := range d.commandPermissions {
+		if existingRule == rule {
+			return errors.New("Rule already exists")
+		}
+	}
+
+	d.commandPermissions = append(d.commandPermissions, rule)
+	return nil
+}
+
+// RemoveCommandPermissionRule removes a rule added by AddCommandPermissionRule.
+func (d *MemoryServerData) RemoveCommandPermissionRule(rule CommandPermissionRule) error {
+	for i, existingRule := range d.commandPermissions {
+		if existingRule == rule {
+			d.commandPermissions = append(d.commandPermissions[:i], d.commandPermissions[i+1:]...)
+			return nil
+		}
+	}
+
+	return errors.New("Rule not found")
+}
@@ -0,0 +1,15 @@
+package state_test
+
+import (
+	"testing"
+
+	"github.com/jonathroth/temp-chat/state"
+	"github.com/jonathroth/temp-chat/state/statetest"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestMemoryServersProvider(t *testing.T) {
+	suite.Run(t, &statetest.ProviderSuite{
+		NewProvider: func() state.ServersProvider { return state.NewMemoryServersProvider() },
+	})
+}
@@ -0,0 +1,118 @@
+package state
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	createSchemaMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version						integer			NOT NULL	PRIMARY KEY,
+		name						varchar(100)	NOT NULL,
+		applied_timestamp			timestamp		NOT NULL
+	);`
+	getSchemaVersion   = `SELECT COALESCE(MAX(version), 0) FROM schema_migrations;`
+	addSchemaMigration = `INSERT INTO schema_migrations (version, name, applied_timestamp) VALUES ($1, $2, $3);`
+)
+
+// migration is a single change to the database schema.
+// Migrations are applied in order, each exactly once, and the versions of the applied ones are kept in schema_migrations.
+// Once released, a migration must never be changed, a new one should be appended instead.
+type migration struct {
+	version   int
+	name      string
+	statement string
+}
+
+// latestSchemaVersion returns the version the migrations bring the database schema to.
+func latestSchemaVersion(migrations []migration) int {
+	if len(migrations) == 0 {
+		return 0
+	}
+
+	return migrations[len(migrations)-1].version
+}
+
+// migrate applies the migrations the database is missing, and returns the version its schema is at.
+// It fails without changing anything if the schema is newer than the latest migration, as this build doesn't know it.
+func migrate(db *sql.DB, dialect *sqlDialect) (version int, err error) {
+	ctx := context.Background()
+
+	// The lock is held by the connection, so everything has to run on the same one.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer conn.Close()
+
+	if dialect.lockMigrations != "" {
+		_, err = conn.ExecContext(ctx, dialect.lockMigrations)
+		if err != nil {
+			return 0, fmt.Errorf("Failed locking the schema migrations: %w", err)
+		}
+
+		// The connection returns to the pool when closed, so the lock must be released explicitly.
+		defer func() {
+			_, unlockErr := conn.ExecContext(ctx, dialect.unlockMigrations)
+			if unlockErr != nil && err == nil {
+				err = fmt.Errorf("Failed unlocking the schema migrations: %w", unlockErr)
+			}
+		}()
+	}
+
+	_, err = conn.ExecContext(ctx, createSchemaMigrationsTable)
+	if err != nil {
+		return 0, err
+	}
+
+	err = conn.QueryRowContext(ctx, getSchemaVersion).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	latestVersion := latestSchemaVersion(dialect.migrations)
+	if version > latestVersion {
+		return version, fmt.Errorf("The database schema is at version %v, newer than the latest version this build knows (%v)", version, latestVersion)
+	}
+
+	for _, migration := range dialect.migrations {
+		if migration.version <= version {
+			continue
+		}
+
+		err = applyMigration(ctx, conn, dialect, migration)
+		if err != nil {
+			return version, fmt.Errorf("Failed applying schema migration %v (%v): %w", migration.version, migration.name, err)
+		}
+
+		log.Printf("Applied schema migration %v (%v)", migration.version, migration.name)
+		version = migration.version
+	}
+
+	return version, nil
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, dialect *sqlDialect, migration migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, migration.statement)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, dialect.rebind(addSchemaMigration), migration.version, migration.name, time.Now().UTC())
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,119 @@
+package state
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MigrationsTestSuite struct {
+	suite.Suite
+
+	directory string
+	path      string
+}
+
+func TestMigrationsTestSuite(t *testing.T) {
+	suite.Run(t, &MigrationsTestSuite{})
+}
+
+func (s *MigrationsTestSuite) SetupTest() {
+	var err error
+	s.directory, err = ioutil.TempDir("", "temp-chat")
+	s.Require().NoError(err)
+	s.path = filepath.Join(s.directory, "temp-chat.db")
+}
+
+func (s *MigrationsTestSuite) TearDownTest() {
+	s.NoError(os.RemoveAll(s.directory))
+}
+
+// withMigrations returns the SQLite dialect with more migrations after its own.
+func withMigrations(migrations ...migration) *sqlDialect {
+	dialect := *sqliteDialect
+	dialect.migrations = append(append([]migration{}, sqliteDialect.migrations...), migrations...)
+	return &dialect
+}
+
+func migrationVersions(dialect *sqlDialect) []int {
+	versions := []int{}
+	for _, migration := range dialect.migrations {
+		versions = append(versions, migration.version)
+	}
+
+	return versions
+}
+
+func (s *MigrationsTestSuite) migrate(dialect *sqlDialect) (int, error) {
+	db, err := sql.Open(dialect.driverName, s.path)
+	s.Require().NoError(err)
+	defer db.Close()
+
+	return migrate(db, dialect)
+}
+
+func (s *MigrationsTestSuite) appliedVersions() []int {
+	db, err := sql.Open(sqliteDialect.driverName, s.path)
+	s.Require().NoError(err)
+	defer db.Close()
+
+	rows, err := db.Query("SELECT version FROM schema_migrations ORDER BY version;")
+	s.Require().NoError(err)
+	defer rows.Close()
+
+	versions := []int{}
+	for rows.Next() {
+		var version int
+		s.Require().NoError(rows.Scan(&version))
+		versions = append(versions, version)
+	}
+
+	s.Require().NoError(rows.Err())
+	return versions
+}
+
+func (s *MigrationsTestSuite) TestMigratesNewDatabase() {
+	version, err := s.migrate(sqliteDialect)
+	s.Require().NoError(err)
+	s.Equal(latestSchemaVersion(sqliteDialect.migrations), version)
+	s.Equal(migrationVersions(sqliteDialect), s.appliedVersions())
+
+	version, err = s.migrate(sqliteDialect)
+	s.Require().NoError(err)
+	s.Equal(latestSchemaVersion(sqliteDialect.migrations), version)
+	s.Equal(migrationVersions(sqliteDialect), s.appliedVersions(), "Migrations were applied twice")
+}
+
+func (s *MigrationsTestSuite) TestAppliesOnlyNewMigrations() {
+	_, err := s.migrate(sqliteDialect)
+	s.Require().NoError(err)
+
+	// Applying the first migrations again would fail on the existing column.
+	newer := withMigrations(migration{version: 100, name: "add test column", statement: "ALTER TABLE servers ADD COLUMN test_column integer DEFAULT 0;"})
+	version, err := s.migrate(newer)
+	s.Require().NoError(err)
+	s.Equal(100, version)
+	s.Equal(append(migrationVersions(sqliteDialect), 100), s.appliedVersions())
+
+	version, err = s.migrate(newer)
+	s.Require().NoError(err)
+	s.Equal(100, version)
+}
+
+func (s *MigrationsTestSuite) TestFailedMigrationIsNotRecorded() {
+	_, err := s.migrate(withMigrations(migration{version: 100, name: "broken", statement: "ALTER TABLE missing_table ADD COLUMN test_column integer;"}))
+	s.Error(err)
+	s.Equal(migrationVersions(sqliteDialect), s.appliedVersions())
+}
+
+func (s *MigrationsTestSuite) TestRefusesNewerSchema() {
+	_, err := s.migrate(withMigrations(migration{version: 100, name: "add test column", statement: "ALTER TABLE servers ADD COLUMN test_column integer DEFAULT 0;"}))
+	s.Require().NoError(err)
+
+	_, err = NewSQLiteServersProvider(s.path)
+	s.Error(err, "A build that doesn't know the schema started")
+}
@@ -1,11 +1,7 @@
 package state
 
 import (
-	"database/sql"
 	"fmt"
-	"time"
-
-	"github.com/jonathroth/temp-chat/consts"
 
 	// PostgreSQL package driver, used by sql.Open()
 	_ "github.com/lib/pq"
@@ -21,196 +17,105 @@ const (
 		last_modified_timestamp		timestamp	NOT NULL,
 		insertion_timestamp			timestamp	NOT NULL
 	);`
-	getServers             = `SELECT server_id, command_channel_id, temp_channel_category_id, custom_command, command_prefix FROM servers;`
-	addServer              = `INSERT INTO servers (server_id, temp_channel_category_id, last_modified_timestamp, insertion_timestamp) VALUES ($1, $2, $3, $4);`
-	getServer              = `SELECT server_id, command_channel_id, temp_channel_category_id, custom_command, command_prefix FROM servers WHERE server_id = $1;`
-	updateCategoryID       = `UPDATE servers SET (temp_channel_category_id, last_modified_timestamp) = ($2, $3) WHERE server_id = $1;`
-	updateCustomCommand    = `UPDATE servers SET (custom_command, last_modified_timestamp) = ($2, $3) WHERE server_id = $1;`
-	updateCommandChannelID = `UPDATE servers SET (command_channel_id, last_modified_timestamp) = ($2, $3) WHERE server_id = $1;`
-	updateCommandPrefix    = `UPDATE servers SET (command_prefix, last_modified_timestamp) = ($2, $3) WHERE server_id = $1;`
+	createTempChannelsTable = `CREATE TABLE IF NOT EXISTS temp_channels (
+		channel_id					bigint		NOT NULL	PRIMARY KEY,
+		voice_channel_id			bigint		NOT NULL,
+		server_id					bigint		NOT NULL,
+		members						text		NOT NULL	DEFAULT '',
+		creation_timestamp			timestamp	NOT NULL
+	);`
+	addOrphanChannelPolicyColumn       = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS orphan_channel_policy varchar(16) DEFAULT 'repermission';`
+	addAutoCreateColumn                = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS auto_create boolean DEFAULT false;`
+	addAutoCreateVoiceChannelIDsColumn = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS auto_create_voice_channel_ids text DEFAULT '';`
+	addChannelNameTemplateColumn       = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS channel_name_template varchar(100) DEFAULT '';`
+	addArchiveFormatColumn             = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS archive_format varchar(16) DEFAULT '';`
+	addArchiveChannelIDColumn          = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS archive_channel_id bigint DEFAULT 0;`
+	addDeletionGracePeriodColumn       = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS deletion_grace_period_seconds integer DEFAULT 0;`
+	addTempChannelOwnerColumn          = `ALTER TABLE temp_channels ADD COLUMN IF NOT EXISTS owner_id bigint DEFAULT 0;`
+	addTempChannelLockedColumn         = `ALTER TABLE temp_channels ADD COLUMN IF NOT EXISTS locked boolean DEFAULT false;`
+	addServerRemovedColumn             = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS removed_timestamp timestamp DEFAULT NULL;`
+	addCategoryRoutesColumn            = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS category_routes text DEFAULT '';`
+	addVoiceChannelPoliciesColumn      = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS voice_channel_policies text DEFAULT '';`
+	addHistoryVisibilityColumn         = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS history_visibility varchar(32) DEFAULT 'hidden';`
+	addMaxChannelAgeColumn             = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS max_channel_age_seconds integer DEFAULT 0;`
+	addMaxIdleTimeColumn               = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS max_idle_time_seconds integer DEFAULT 0;`
+	addExpiryActionColumn              = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS expiry_action varchar(16) DEFAULT 'delete';`
+	addMkchCooldownColumn              = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS mkch_cooldown_seconds integer DEFAULT 0;`
+	addMaxTempChannelsColumn           = `ALTER TABLE servers ADD COLUMN IF NOT EXISTS max_temp_channels integer DEFAULT 0;`
+	addTempChannelInvitedColumn        = `ALTER TABLE temp_channels ADD COLUMN IF NOT EXISTS invited text NOT NULL DEFAULT '';`
+	createCommandPermissionsTable      = `CREATE TABLE IF NOT EXISTS command_permissions (
+		server_id					bigint		NOT NULL,
+		command						varchar(32)	NOT NULL,
+		rule_type					varchar(16)	NOT NULL,
+		target_id					bigint		NOT NULL	DEFAULT 0,
+		permissions					bigint		NOT NULL	DEFAULT 0,
+		insertion_timestamp			timestamp	NOT NULL,
+		PRIMARY KEY (server_id, command, rule_type, target_id, permissions)
+	);`
+	createConfigChangesTable = `CREATE TABLE IF NOT EXISTS config_changes (
+		change_id					bigserial	PRIMARY KEY,
+		server_id					bigint		NOT NULL,
+		user_id						bigint		NOT NULL,
+		setting						varchar(32)	NOT NULL,
+		old_value					text		NOT NULL,
+		new_value					text		NOT NULL,
+		change_timestamp			timestamp	NOT NULL
+	);`
+	createConfigChangesIndex = `CREATE INDEX IF NOT EXISTS config_changes_server_id ON config_changes (server_id, change_id);`
+	createTranscriptsTable   = `CREATE TABLE IF NOT EXISTS transcripts (
+		transcript_id				serial		PRIMARY KEY,
+		server_id					bigint		NOT NULL,
+		channel_id					bigint		NOT NULL,
+		voice_channel_id			bigint		NOT NULL,
+		channel_name				varchar(100)	NOT NULL,
+		format						varchar(16)	NOT NULL,
+		content						text		NOT NULL,
+		creation_timestamp			timestamp	NOT NULL
+	);`
 )
 
-// PostgresServersProvider is a ServerProvider implementation over PostgreSQL.
-type PostgresServersProvider struct {
-	address string
-	db      *sql.DB
-}
-
-type sqlScanner interface {
-	Scan(...interface{}) error
-}
-
-// NewPostgresServersProvider initializes a new instance of PostgresServersProvider
-func NewPostgresServersProvider(address string) (*PostgresServersProvider, error) {
-	db, err := sql.Open("postgres", address)
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = db.Exec(createServersTable)
-	if err != nil {
-		return nil, err
-	}
-
-	return &PostgresServersProvider{address: address, db: db}, nil
-}
-
-// Servers returns the list of all servers managed by the bot.
-func (p *PostgresServersProvider) Servers() (ServersData, error) {
-	result := ServersData{}
-
-	rows, err := p.db.Query(getServers)
-	if err != nil {
-		return nil, err
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		serverData, err := p.initializeServer(rows)
-		if err != nil {
-			return nil, err
-		}
-
-		result[serverData.ServerID()] = serverData
-	}
-
-	return result, nil
-}
-
-func (p *PostgresServersProvider) initializeServer(scanner sqlScanner) (ServerData, error) {
-	serverData := NewPostgresServerData(p.db)
-	err := scanner.Scan(&serverData.serverID, &serverData.commandChannelID, &serverData.tempChannelCategoryID, &serverData.customCommand, &serverData.commandPrefix)
-	if err != nil {
-		return nil, err
-	}
-
-	return serverData, nil
-}
-
-// AddServer adds a new server to the store.
-func (p *PostgresServersProvider) AddServer(serverID DiscordID, tempChannelCategoryID DiscordID) (ServerData, error) {
-	currentTime := time.Now().UTC()
-
-	_, err := p.db.Exec(addServer, serverID, tempChannelCategoryID, currentTime, currentTime)
-	if err != nil {
-		return nil, err
-	}
-
-	return p.server(serverID)
-}
-
-func (p *PostgresServersProvider) server(serverID DiscordID) (ServerData, error) {
-	return p.initializeServer(p.db.QueryRow(getServer, serverID))
-}
-
-// PostgresServerData wraps server-specific
-type PostgresServerData struct {
-	serverID              DiscordID
-	commandChannelID      DiscordID
-	tempChannelCategoryID DiscordID
-	customCommand         string
-	commandPrefix         string
-	db                    *sql.DB
-}
-
-// NewPostgresServerData initializes a new instance of PostgresServerData
-func NewPostgresServerData(db *sql.DB) *PostgresServerData {
-	return &PostgresServerData{db: db}
-}
-
-// ServerID returns the ID of the server whose data is saved in this object.
-func (d *PostgresServerData) ServerID() DiscordID {
-	return d.serverID
-}
-
-// TempChannelCategoryID is the category Discord ID of the category to create temporary chat channels in.
-func (d *PostgresServerData) TempChannelCategoryID() DiscordID {
-	return d.tempChannelCategoryID
-}
-
-// SetTempChannelCategoryID sets a new channel category.
-func (d *PostgresServerData) SetTempChannelCategoryID(value DiscordID) error {
-	d.tempChannelCategoryID = value
-	return assertOneChange(d.db.Exec(updateCategoryID, d.serverID, value, time.Now().UTC()))
-}
-
-// CommandPrefix returns the server's specific command prefix.
-func (d *PostgresServerData) CommandPrefix() string {
-	return d.commandPrefix
-}
-
-// SetCustomCommandPrefix changes the command prefix to the a custom prefix.
-func (d *PostgresServerData) SetCustomCommandPrefix(value string) error {
-	d.commandPrefix = value
-	return assertOneChange(d.db.Exec(updateCommandPrefix, d.serverID, value, time.Now().UTC()))
-}
-
-// ResetCommandPrefix resets the prefix to the default value.
-func (d *PostgresServerData) ResetCommandPrefix() error {
-	return d.SetCustomCommandPrefix(consts.DefaultCommandPrefix)
-}
-
-// HasDifferentPrefix returns whether the prefix was changed or not.
-func (d *PostgresServerData) HasDifferentPrefix() bool {
-	return d.commandPrefix != consts.DefaultCommandPrefix
-}
-
-// CommandChannelID is the ID of the channel the bot will exclusively receive commands on.
-func (d *PostgresServerData) CommandChannelID() DiscordID {
-	return d.commandChannelID
-}
-
-// SetCommandChannelID sets a specific command channel.
-func (d *PostgresServerData) SetCommandChannelID(value DiscordID) error {
-	d.commandChannelID = value
-	return assertOneChange(d.db.Exec(updateCommandChannelID, d.serverID, value, time.Now().UTC()))
-}
-
-// ClearCommandChannelID removes the specific command channel.
-func (d *PostgresServerData) ClearCommandChannelID() error {
-	return d.SetCommandChannelID(DiscordIDNone)
-}
-
-// HasCommandChannelID returns whether the specific command channel is set.
-func (d *PostgresServerData) HasCommandChannelID() bool {
-	return d.commandChannelID != DiscordIDNone
-}
-
-// CustomCommand is a replacement name for the make-temp-channel command name.
-func (d *PostgresServerData) CustomCommand() string {
-	return d.customCommand
-}
-
-// SetCustomCommand sets the replacement name for the make-temp-channel command.
-func (d *PostgresServerData) SetCustomCommand(value string) error {
-	d.customCommand = value
-	return assertOneChange(d.db.Exec(updateCustomCommand, d.serverID, value, time.Now().UTC()))
-}
-
-// ResetCustomCommand resets the make-temp-channel command name to default.
-func (d *PostgresServerData) ResetCustomCommand() error {
-	return d.SetCustomCommand("")
-}
-
-// HasCustomCommand returns whether the make-temp-channel was assigned an alternative name.
-func (d *PostgresServerData) HasCustomCommand() bool {
-	return d.CustomCommand() != ""
-}
-
-func assertOneChange(sqlResult sql.Result, err error) error {
-	if err != nil {
-		return err
-	}
-
-	rowsAffected, err := sqlResult.RowsAffected()
-	if err != nil {
-		return err
-	}
-
-	if rowsAffected != 1 {
-		return fmt.Errorf("Expected a single row update, got %v", rowsAffected)
-	}
-
-	return nil
+// postgresMigrationsLockID is the key of the advisory lock that keeps two instances from migrating the database at once.
+const postgresMigrationsLockID = 1776001
+
+// postgresMigrations bring the PostgreSQL tables up to date, in order.
+// The first ones were run on every startup before the migrations were versioned, so they must stay safe to run again.
+var postgresMigrations = []migration{
+	{version: 1, name: "create servers table", statement: createServersTable},
+	{version: 2, name: "create temp channels table", statement: createTempChannelsTable},
+	{version: 3, name: "add orphan channel policy", statement: addOrphanChannelPolicyColumn},
+	{version: 4, name: "add auto create", statement: addAutoCreateColumn},
+	{version: 5, name: "add auto create voice channels", statement: addAutoCreateVoiceChannelIDsColumn},
+	{version: 6, name: "add channel name template", statement: addChannelNameTemplateColumn},
+	{version: 7, name: "add archive format", statement: addArchiveFormatColumn},
+	{version: 8, name: "add archive channel", statement: addArchiveChannelIDColumn},
+	{version: 9, name: "create transcripts table", statement: createTranscriptsTable},
+	{version: 10, name: "add deletion grace period", statement: addDeletionGracePeriodColumn},
+	{version: 11, name: "create command permissions table", statement: createCommandPermissionsTable},
+	{version: 12, name: "add temp channel owner", statement: addTempChannelOwnerColumn},
+	{version: 13, name: "add temp channel locked", statement: addTempChannelLockedColumn},
+	{version: 14, name: "add server removal", statement: addServerRemovedColumn},
+	{version: 15, name: "create config changes table", statement: createConfigChangesTable},
+	{version: 16, name: "index config changes by server", statement: createConfigChangesIndex},
+	{version: 17, name: "add category routes", statement: addCategoryRoutesColumn},
+	{version: 18, name: "add voice channel policies", statement: addVoiceChannelPoliciesColumn},
+	{version: 19, name: "add history visibility", statement: addHistoryVisibilityColumn},
+	{version: 20, name: "add max channel age", statement: addMaxChannelAgeColumn},
+	{version: 21, name: "add max idle time", statement: addMaxIdleTimeColumn},
+	{version: 22, name: "add expiry action", statement: addExpiryActionColumn},
+	{version: 23, name: "add mkch cooldown", statement: addMkchCooldownColumn},
+	{version: 24, name: "add max temp channels", statement: addMaxTempChannelsColumn},
+	{version: 25, name: "add temp channel invited", statement: addTempChannelInvitedColumn},
+}
+
+var postgresDialect = &sqlDialect{
+	driverName:       "postgres",
+	migrations:       postgresMigrations,
+	lockMigrations:   fmt.Sprintf("SELECT pg_advisory_lock(%v);", postgresMigrationsLockID),
+	unlockMigrations: fmt.Sprintf("SELECT pg_advisory_unlock(%v);", postgresMigrationsLockID),
+	rebind:           func(query string) string { return query },
+}
+
+// NewPostgresServersProvider initializes a new instance of SQLServersProvider over PostgreSQL.
+func NewPostgresServersProvider(address string) (*SQLServersProvider, error) {
+	return newSQLServersProvider(postgresDialect, address)
 }
@@ -0,0 +1,41 @@
+package state_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/jonathroth/temp-chat/state"
+	"github.com/jonathroth/temp-chat/state/statetest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// TestPostgresServersProvider runs against the database in POSTGRES_TEST_DATABASE_URL, which it empties before every test.
+func TestPostgresServersProvider(t *testing.T) {
+	address := os.Getenv("POSTGRES_TEST_DATABASE_URL")
+	if address == "" {
+		t.Skip("POSTGRES_TEST_DATABASE_URL isn't set")
+	}
+
+	open := func() state.ServersProvider {
+		provider, err := state.NewPostgresServersProvider(address)
+		require.NoError(t, err)
+		return provider
+	}
+
+	suite.Run(t, &statetest.ProviderSuite{
+		NewProvider: func() state.ServersProvider {
+			provider := open()
+
+			db, err := sql.Open("postgres", address)
+			require.NoError(t, err)
+			defer db.Close()
+			_, err = db.Exec("TRUNCATE servers, temp_channels, command_permissions, transcripts, config_changes")
+			require.NoError(t, err)
+
+			return provider
+		},
+		Reopen: open,
+	})
+}
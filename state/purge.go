@@ -0,0 +1,48 @@
+package state
+
+import (
+	"log"
+	"time"
+)
+
+// StartServerPurge deletes the data of servers the bot was removed from more than retention ago, now and then every interval.
+// Until then, a server that invites the bot back gets its settings back.
+// The purge runs in the background until the returned function is called.
+func StartServerPurge(provider ServersProvider, retention time.Duration, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			purgeExpiredServers(provider, retention)
+
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func purgeExpiredServers(provider ServersProvider, retention time.Duration) {
+	purged, err := provider.PurgeRemovedServers(time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("Failed purging removed servers: %v", err)
+		return
+	}
+
+	if purged > 0 {
+		log.Printf("Purged the data of %v servers the bot was removed from", purged)
+	}
+}
@@ -0,0 +1,794 @@
+package state
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jonathroth/temp-chat/consts"
+)
+
+const (
+	getServers    = `SELECT server_id, command_channel_id, temp_channel_category_id, custom_command, command_prefix, orphan_channel_policy, auto_create, auto_create_voice_channel_ids, channel_name_template, archive_format, archive_channel_id, deletion_grace_period_seconds, max_channel_age_seconds, max_idle_time_seconds, expiry_action, mkch_cooldown_seconds, max_temp_channels, category_routes, history_visibility, voice_channel_policies FROM servers WHERE removed_timestamp IS NULL;`
+	addServer     = `INSERT INTO servers (server_id, temp_channel_category_id, last_modified_timestamp, insertion_timestamp) VALUES ($1, $2, $3, $4);`
+	getServer     = `SELECT server_id, command_channel_id, temp_channel_category_id, custom_command, command_prefix, orphan_channel_policy, auto_create, auto_create_voice_channel_ids, channel_name_template, archive_format, archive_channel_id, deletion_grace_period_seconds, max_channel_age_seconds, max_idle_time_seconds, expiry_action, mkch_cooldown_seconds, max_temp_channels, category_routes, history_visibility, voice_channel_policies FROM servers WHERE server_id = $1;`
+	removeServer  = `UPDATE servers SET (removed_timestamp, last_modified_timestamp) = ($2, $2) WHERE server_id = $1 AND removed_timestamp IS NULL;`
+	restoreServer = `UPDATE servers SET (removed_timestamp, last_modified_timestamp) = (NULL, $2) WHERE server_id = $1 AND removed_timestamp IS NOT NULL;`
+	readdServer   = `UPDATE servers SET (temp_channel_category_id, removed_timestamp, last_modified_timestamp) = ($2, NULL, $3) WHERE server_id = $1 AND removed_timestamp IS NOT NULL;`
+
+	getTempChannels          = `SELECT channel_id, voice_channel_id, server_id, members, creation_timestamp, owner_id, locked, invited FROM temp_channels;`
+	addTempChannel           = `INSERT INTO temp_channels (channel_id, voice_channel_id, server_id, members, creation_timestamp, owner_id, locked, invited) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+	updateTempChannelMembers = `UPDATE temp_channels SET members = $2 WHERE channel_id = $1;`
+	updateTempChannelOwner   = `UPDATE temp_channels SET owner_id = $2 WHERE channel_id = $1;`
+	updateTempChannelLocked  = `UPDATE temp_channels SET locked = $2 WHERE channel_id = $1;`
+	updateTempChannelInvited = `UPDATE temp_channels SET invited = $2 WHERE channel_id = $1;`
+	removeTempChannel        = `DELETE FROM temp_channels WHERE channel_id = $1;`
+
+	getCommandPermissions       = `SELECT server_id, command, rule_type, target_id, permissions FROM command_permissions;`
+	getServerCommandPermissions = `SELECT server_id, command, rule_type, target_id, permissions FROM command_permissions WHERE server_id = $1;`
+	addCommandPermission        = `INSERT INTO command_permissions (server_id, command, rule_type, target_id, permissions, insertion_timestamp) VALUES ($1, $2, $3, $4, $5, $6);`
+	removeCommandPermission     = `DELETE FROM command_permissions WHERE server_id = $1 AND command = $2 AND rule_type = $3 AND target_id = $4 AND permissions = $5;`
+
+	addTranscript = `INSERT INTO transcripts (server_id, channel_id, voice_channel_id, channel_name, format, content, creation_timestamp) VALUES ($1, $2, $3, $4, $5, $6, $7);`
+
+	addConfigChange  = `INSERT INTO config_changes (server_id, user_id, setting, old_value, new_value, change_timestamp) VALUES ($1, $2, $3, $4, $5, $6) RETURNING change_id;`
+	getConfigChanges = `SELECT change_id, server_id, user_id, setting, old_value, new_value, change_timestamp FROM config_changes WHERE server_id = $1 ORDER BY change_id DESC LIMIT $2 OFFSET $3;`
+	getConfigChange  = `SELECT change_id, server_id, user_id, setting, old_value, new_value, change_timestamp FROM config_changes WHERE server_id = $1 AND change_id = $2;`
+
+	// The data of purged servers is deleted before the servers, so an interrupted purge is finished by the next one.
+	purgeRemovedServersCommandPermissions = `DELETE FROM command_permissions WHERE server_id IN (SELECT server_id FROM servers WHERE removed_timestamp < $1);`
+	purgeRemovedServersTempChannels       = `DELETE FROM temp_channels WHERE server_id IN (SELECT server_id FROM servers WHERE removed_timestamp < $1);`
+	purgeRemovedServersTranscripts        = `DELETE FROM transcripts WHERE server_id IN (SELECT server_id FROM servers WHERE removed_timestamp < $1);`
+	purgeRemovedServersConfigChanges      = `DELETE FROM config_changes WHERE server_id IN (SELECT server_id FROM servers WHERE removed_timestamp < $1);`
+	purgeRemovedServers                   = `DELETE FROM servers WHERE removed_timestamp < $1;`
+)
+
+// sqlDialect is what differs between the SQL databases the bot can be stored in.
+// Queries are written for PostgreSQL, and rebound for the other databases.
+type sqlDialect struct {
+	driverName string
+
+	// migrations create and update the tables, applied in order on startup.
+	migrations []migration
+
+	// lockMigrations and unlockMigrations keep other instances from migrating the database at the same time.
+	// They're empty if the database doesn't need it.
+	lockMigrations   string
+	unlockMigrations string
+
+	// rebind converts a query from PostgreSQL's $n placeholders to the database's placeholders.
+	rebind func(query string) string
+
+	// maxOpenConns limits the connections to the database, 0 means unlimited.
+	maxOpenConns int
+}
+
+var postgresPlaceholderRegex = regexp.MustCompile(`\$(\d+)`)
+
+// sqlDB runs the queries of a dialect on its database.
+type sqlDB struct {
+	*sql.DB
+	dialect *sqlDialect
+}
+
+func (db *sqlDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.dialect.rebind(query), args...)
+}
+
+func (db *sqlDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.dialect.rebind(query), args...)
+}
+
+func (db *sqlDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.dialect.rebind(query), args...)
+}
+
+// SQLServersProvider is a ServerProvider implementation over an SQL database, either PostgreSQL or SQLite.
+type SQLServersProvider struct {
+	address       string
+	db            *sqlDB
+	schemaVersion int
+}
+
+type sqlScanner interface {
+	Scan(...interface{}) error
+}
+
+func newSQLServersProvider(dialect *sqlDialect, address string) (*SQLServersProvider, error) {
+	db, err := sql.Open(dialect.driverName, address)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(dialect.maxOpenConns)
+
+	schemaVersion, err := migrate(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLServersProvider{address: address, db: &sqlDB{DB: db, dialect: dialect}, schemaVersion: schemaVersion}, nil
+}
+
+// SchemaVersion returns the version of the database schema, after the migrations were applied.
+func (p *SQLServersProvider) SchemaVersion() int {
+	return p.schemaVersion
+}
+
+// Servers returns the list of all servers managed by the bot.
+func (p *SQLServersProvider) Servers() (ServersData, error) {
+	servers := map[DiscordID]*SQLServerData{}
+
+	rows, err := p.db.Query(getServers)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		serverData, err := p.initializeServer(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		servers[serverData.ServerID()] = serverData
+	}
+
+	err = p.loadCommandPermissions(servers, getCommandPermissions)
+	if err != nil {
+		return nil, err
+	}
+
+	result := ServersData{}
+	for serverID, serverData := range servers {
+		result[serverID] = serverData
+	}
+
+	return result, nil
+}
+
+// loadCommandPermissions fills the command permission rules of the given servers.
+func (p *SQLServersProvider) loadCommandPermissions(servers map[DiscordID]*SQLServerData, query string, args ...interface{}) error {
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var serverID DiscordID
+		rule := CommandPermissionRule{}
+		err := rows.Scan(&serverID, &rule.Command, &rule.Type, &rule.TargetID, &rule.Permissions)
+		if err != nil {
+			return err
+		}
+
+		serverData, found := servers[serverID]
+		if found {
+			serverData.commandPermissions = append(serverData.commandPermissions, rule)
+		}
+	}
+
+	return rows.Err()
+}
+
+func (p *SQLServersProvider) initializeServer(scanner sqlScanner) (*SQLServerData, error) {
+	serverData := newSQLServerData(p.db)
+	config := &serverData.config
+	var autoCreateChannelIDs string
+	var gracePeriodSeconds int
+	var maxAgeSeconds int
+	var maxIdleSeconds int
+	var cooldownSeconds int
+	var categoryRoutes string
+	var historyVisibility string
+	var voiceChannelPolicies string
+	err := scanner.Scan(&serverData.serverID, &config.CommandChannelID, &config.TempChannelCategoryID, &config.CustomCommand, &config.CommandPrefix, &config.OrphanChannelPolicy,
+		&config.AutoCreate, &autoCreateChannelIDs, &config.ChannelNameTemplate,
+		&config.ArchiveFormat, &config.ArchiveChannelID, &gracePeriodSeconds, &maxAgeSeconds, &maxIdleSeconds, &config.ExpiryAction, &cooldownSeconds, &config.MaxTempChannels, &categoryRoutes, &historyVisibility, &voiceChannelPolicies)
+	if err != nil {
+		return nil, err
+	}
+
+	config.AutoCreateVoiceChannelIDs, err = ParseDiscordIDs(autoCreateChannelIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	config.DeletionGracePeriod = time.Duration(gracePeriodSeconds) * time.Second
+	config.MaxChannelAge = time.Duration(maxAgeSeconds) * time.Second
+	config.MaxIdleTime = time.Duration(maxIdleSeconds) * time.Second
+	config.MkchCooldown = time.Duration(cooldownSeconds) * time.Second
+
+	config.CategoryRoutes, err = ParseCategoryRoutes(categoryRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	config.HistoryVisibility, err = ParseHistoryVisibility(historyVisibility)
+	if err != nil {
+		return nil, err
+	}
+
+	config.VoiceChannelPolicies, err = ParseVoiceChannelPolicies(voiceChannelPolicies)
+	if err != nil {
+		return nil, err
+	}
+
+	return serverData, nil
+}
+
+// AddServer adds a new server to the store.
+// A server that was removed and not purged yet gets its settings back, with the new category.
+func (p *SQLServersProvider) AddServer(serverID DiscordID, tempChannelCategoryID DiscordID) (ServerData, error) {
+	currentTime := time.Now().UTC()
+
+	result, err := p.db.Exec(readdServer, serverID, tempChannelCategoryID, currentTime)
+	if err != nil {
+		return nil, err
+	}
+
+	readded, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if readded == 0 {
+		_, err = p.db.Exec(addServer, serverID, tempChannelCategoryID, currentTime, currentTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p.server(serverID)
+}
+
+// RemoveServer marks a server the bot was removed from, its data is kept until it's purged.
+func (p *SQLServersProvider) RemoveServer(serverID DiscordID) error {
+	return assertOneChange(p.db.Exec(removeServer, serverID, time.Now().UTC()))
+}
+
+// RestoreServer returns a removed server to the store, if it wasn't purged yet.
+func (p *SQLServersProvider) RestoreServer(serverID DiscordID) (ServerData, bool, error) {
+	result, err := p.db.Exec(restoreServer, serverID, time.Now().UTC())
+	if err != nil {
+		return nil, false, err
+	}
+
+	restored, err := result.RowsAffected()
+	if err != nil || restored == 0 {
+		return nil, false, err
+	}
+
+	serverData, err := p.server(serverID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return serverData, true, nil
+}
+
+// PurgeRemovedServers deletes all the data of the servers removed before the given time.
+// Returns the number of purged servers.
+func (p *SQLServersProvider) PurgeRemovedServers(removedBefore time.Time) (int, error) {
+	removedBefore = removedBefore.UTC()
+	for _, query := range []string{purgeRemovedServersCommandPermissions, purgeRemovedServersTempChannels, purgeRemovedServersTranscripts, purgeRemovedServersConfigChanges} {
+		_, err := p.db.Exec(query, removedBefore)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := p.db.Exec(purgeRemovedServers, removedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	return int(purged), err
+}
+
+func (p *SQLServersProvider) server(serverID DiscordID) (ServerData, error) {
+	serverData, err := p.initializeServer(p.db.QueryRow(getServer, serverID))
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.loadCommandPermissions(map[DiscordID]*SQLServerData{serverID: serverData}, getServerCommandPermissions, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	return serverData, nil
+}
+
+// TempChannels returns all the temp channels that were active when the bot last ran.
+func (p *SQLServersProvider) TempChannels() ([]*TempChannelData, error) {
+	result := []*TempChannelData{}
+
+	rows, err := p.db.Query(getTempChannels)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		data := &TempChannelData{}
+		var members, invited string
+		err := rows.Scan(&data.ChannelID, &data.VoiceChannelID, &data.ServerID, &members, &data.CreatedAt, &data.OwnerID, &data.Locked, &invited)
+		if err != nil {
+			return nil, err
+		}
+
+		data.Members, err = ParseDiscordIDs(members)
+		if err != nil {
+			return nil, err
+		}
+
+		data.Invited, err = ParseDiscordIDs(invited)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, data)
+	}
+
+	return result, rows.Err()
+}
+
+// AddTempChannel saves a newly created temp channel.
+func (p *SQLServersProvider) AddTempChannel(data *TempChannelData) error {
+	return assertOneChange(p.db.Exec(addTempChannel, data.ChannelID, data.VoiceChannelID, data.ServerID, FormatDiscordIDs(data.Members), data.CreatedAt.UTC(), data.OwnerID, data.Locked, FormatDiscordIDs(data.Invited)))
+}
+
+// SetTempChannelMembers replaces the list of users that have access to the temp channel.
+func (p *SQLServersProvider) SetTempChannelMembers(channelID DiscordID, members []DiscordID) error {
+	return assertOneChange(p.db.Exec(updateTempChannelMembers, channelID, FormatDiscordIDs(members)))
+}
+
+// SetTempChannelOwner changes the user that controls the temp channel.
+func (p *SQLServersProvider) SetTempChannelOwner(channelID DiscordID, ownerID DiscordID) error {
+	return assertOneChange(p.db.Exec(updateTempChannelOwner, channelID, ownerID))
+}
+
+// SetTempChannelLocked changes whether the temp channel gives access to users that join its voice chat.
+func (p *SQLServersProvider) SetTempChannelLocked(channelID DiscordID, locked bool) error {
+	return assertOneChange(p.db.Exec(updateTempChannelLocked, channelID, locked))
+}
+
+// SetTempChannelInvited replaces the list of members the owner invited to the temp channel.
+func (p *SQLServersProvider) SetTempChannelInvited(channelID DiscordID, invited []DiscordID) error {
+	return assertOneChange(p.db.Exec(updateTempChannelInvited, channelID, FormatDiscordIDs(invited)))
+}
+
+// RemoveTempChannel removes a deleted temp channel.
+func (p *SQLServersProvider) RemoveTempChannel(channelID DiscordID) error {
+	_, err := p.db.Exec(removeTempChannel, channelID)
+	return err
+}
+
+// AddTranscript saves the transcript of a deleted temp channel.
+func (p *SQLServersProvider) AddTranscript(data *TranscriptData) error {
+	return assertOneChange(p.db.Exec(addTranscript, data.ServerID, data.ChannelID, data.VoiceChannelID, data.ChannelName, data.Format, data.Content, data.CreatedAt.UTC()))
+}
+
+// AddConfigChange records a change to the settings of a server, and sets its ChangeID.
+func (p *SQLServersProvider) AddConfigChange(change *ConfigChange) error {
+	return p.db.QueryRow(addConfigChange, change.ServerID, change.UserID, change.Setting, change.OldValue, change.NewValue, change.ChangedAt.UTC()).Scan(&change.ChangeID)
+}
+
+// ConfigChanges returns the changes to the settings of a server from newest to oldest, skipping the newest offset changes.
+func (p *SQLServersProvider) ConfigChanges(serverID DiscordID, offset int, limit int) ([]*ConfigChange, error) {
+	result := []*ConfigChange{}
+
+	rows, err := p.db.Query(getConfigChanges, serverID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		change, err := scanConfigChange(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, change)
+	}
+
+	return result, rows.Err()
+}
+
+// ConfigChange returns a specific change to the settings of a server.
+func (p *SQLServersProvider) ConfigChange(serverID DiscordID, changeID int64) (*ConfigChange, bool, error) {
+	change, err := scanConfigChange(p.db.QueryRow(getConfigChange, serverID, changeID))
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	return change, true, nil
+}
+
+func scanConfigChange(scanner sqlScanner) (*ConfigChange, error) {
+	change := &ConfigChange{}
+	err := scanner.Scan(&change.ChangeID, &change.ServerID, &change.UserID, &change.Setting, &change.OldValue, &change.NewValue, &change.ChangedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// SQLServerData wraps server-specific data saved in an SQL database.
+type SQLServerData struct {
+	serverID           DiscordID
+	config             ServerConfig
+	commandPermissions []CommandPermissionRule
+	db                 *sqlDB
+}
+
+func newSQLServerData(db *sqlDB) *SQLServerData {
+	return &SQLServerData{db: db}
+}
+
+// serverConfigColumns are the columns of the servers table that hold the settings, with the values saved in them.
+var serverConfigColumns = []struct {
+	name  string
+	value func(config *ServerConfig) interface{}
+}{
+	{"temp_channel_category_id", func(config *ServerConfig) interface{} { return config.TempChannelCategoryID }},
+	{"command_prefix", func(config *ServerConfig) interface{} { return config.CommandPrefix }},
+	{"command_channel_id", func(config *ServerConfig) interface{} { return config.CommandChannelID }},
+	{"custom_command", func(config *ServerConfig) interface{} { return config.CustomCommand }},
+	{"orphan_channel_policy", func(config *ServerConfig) interface{} { return config.OrphanChannelPolicy }},
+	{"auto_create", func(config *ServerConfig) interface{} { return config.AutoCreate }},
+	{"auto_create_voice_channel_ids", func(config *ServerConfig) interface{} { return FormatDiscordIDs(config.AutoCreateVoiceChannelIDs) }},
+	{"channel_name_template", func(config *ServerConfig) interface{} { return config.ChannelNameTemplate }},
+	{"archive_format", func(config *ServerConfig) interface{} { return config.ArchiveFormat }},
+	{"archive_channel_id", func(config *ServerConfig) interface{} { return config.ArchiveChannelID }},
+	{"deletion_grace_period_seconds", func(config *ServerConfig) interface{} { return int(config.DeletionGracePeriod.Seconds()) }},
+	{"max_channel_age_seconds", func(config *ServerConfig) interface{} { return int(config.MaxChannelAge.Seconds()) }},
+	{"max_idle_time_seconds", func(config *ServerConfig) interface{} { return int(config.MaxIdleTime.Seconds()) }},
+	{"expiry_action", func(config *ServerConfig) interface{} { return config.ExpiryAction }},
+	{"mkch_cooldown_seconds", func(config *ServerConfig) interface{} { return int(config.MkchCooldown.Seconds()) }},
+	{"max_temp_channels", func(config *ServerConfig) interface{} { return config.MaxTempChannels }},
+	{"category_routes", func(config *ServerConfig) interface{} { return FormatCategoryRoutes(config.CategoryRoutes) }},
+	{"history_visibility", func(config *ServerConfig) interface{} { return config.HistoryVisibility.String() }},
+	{"voice_channel_policies", func(config *ServerConfig) interface{} { return FormatVoiceChannelPolicies(config.VoiceChannelPolicies) }},
+}
+
+// ServerID returns the ID of the server whose data is saved in this object.
+func (d *SQLServerData) ServerID() DiscordID {
+	return d.serverID
+}
+
+// Config returns a copy of the server's settings.
+func (d *SQLServerData) Config() ServerConfig {
+	return d.config.copy()
+}
+
+// Update changes several settings at once. The update function changes a copy of the settings,
+// which is checked and saved as a whole only if the function succeeds.
+// All the changed columns are written by a single statement, and the settings are kept in memory only after it succeeds.
+func (d *SQLServerData) Update(update func(config *ServerConfig) error) error {
+	config := d.config.copy()
+	err := update(&config)
+	if err != nil {
+		return err
+	}
+
+	err = config.check()
+	if err != nil {
+		return err
+	}
+
+	assignments := []string{}
+	args := []interface{}{d.serverID}
+	for _, column := range serverConfigColumns {
+		value := column.value(&config)
+		if value == column.value(&d.config) {
+			continue
+		}
+
+		args = append(args, value)
+		assignments = append(assignments, fmt.Sprintf("%v = $%v", column.name, len(args)))
+	}
+
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	args = append(args, time.Now().UTC())
+	assignments = append(assignments, fmt.Sprintf("last_modified_timestamp = $%v", len(args)))
+
+	query := fmt.Sprintf("UPDATE servers SET %v WHERE server_id = $1;", strings.Join(assignments, ", "))
+	err = assertOneChange(d.db.Exec(query, args...))
+	if err != nil {
+		return err
+	}
+
+	d.config = config
+	return nil
+}
+
+// TempChannelCategoryID is the category Discord ID of the category to create temporary chat channels in.
+func (d *SQLServerData) TempChannelCategoryID() DiscordID {
+	return d.config.TempChannelCategoryID
+}
+
+// SetTempChannelCategoryID sets a new channel category.
+func (d *SQLServerData) SetTempChannelCategoryID(value DiscordID) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.TempChannelCategoryID = value
+		return nil
+	})
+}
+
+// CommandPrefix returns the server's specific command prefix.
+func (d *SQLServerData) CommandPrefix() string {
+	return d.config.CommandPrefix
+}
+
+// SetCustomCommandPrefix changes the command prefix to the a custom prefix.
+func (d *SQLServerData) SetCustomCommandPrefix(value string) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.CommandPrefix = value
+		return nil
+	})
+}
+
+// ResetCommandPrefix resets the prefix to the default value.
+func (d *SQLServerData) ResetCommandPrefix() error {
+	return d.SetCustomCommandPrefix(consts.DefaultCommandPrefix)
+}
+
+// HasDifferentPrefix returns whether the prefix was changed or not.
+func (d *SQLServerData) HasDifferentPrefix() bool {
+	return d.config.CommandPrefix != consts.DefaultCommandPrefix
+}
+
+// CommandChannelID is the ID of the channel the bot will exclusively receive commands on.
+func (d *SQLServerData) CommandChannelID() DiscordID {
+	return d.config.CommandChannelID
+}
+
+// SetCommandChannelID sets a specific command channel.
+func (d *SQLServerData) SetCommandChannelID(value DiscordID) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.CommandChannelID = value
+		return nil
+	})
+}
+
+// ClearCommandChannelID removes the specific command channel.
+func (d *SQLServerData) ClearCommandChannelID() error {
+	return d.SetCommandChannelID(DiscordIDNone)
+}
+
+// HasCommandChannelID returns whether the specific command channel is set.
+func (d *SQLServerData) HasCommandChannelID() bool {
+	return d.config.CommandChannelID != DiscordIDNone
+}
+
+// CustomCommand is a replacement name for the make-temp-channel command name.
+func (d *SQLServerData) CustomCommand() string {
+	return d.config.CustomCommand
+}
+
+// SetCustomCommand sets the replacement name for the make-temp-channel command.
+func (d *SQLServerData) SetCustomCommand(value string) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.CustomCommand = value
+		return nil
+	})
+}
+
+// ResetCustomCommand resets the make-temp-channel command name to default.
+func (d *SQLServerData) ResetCustomCommand() error {
+	return d.SetCustomCommand("")
+}
+
+// HasCustomCommand returns whether the make-temp-channel was assigned an alternative name.
+func (d *SQLServerData) HasCustomCommand() bool {
+	return d.CustomCommand() != ""
+}
+
+// OrphanChannelPolicy is what the bot does with text channels in the temp category it doesn't track.
+func (d *SQLServerData) OrphanChannelPolicy() string {
+	return d.config.OrphanChannelPolicy
+}
+
+// SetOrphanChannelPolicy sets the policy for untracked text channels in the temp category.
+func (d *SQLServerData) SetOrphanChannelPolicy(value string) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.OrphanChannelPolicy = value
+		return nil
+	})
+}
+
+// ResetOrphanChannelPolicy resets the orphan channel policy to the default value.
+func (d *SQLServerData) ResetOrphanChannelPolicy() error {
+	return d.SetOrphanChannelPolicy(consts.DefaultOrphanPolicy)
+}
+
+// AutoCreate returns whether temp channels are created automatically when users join a voice chat.
+func (d *SQLServerData) AutoCreate() bool {
+	return d.config.AutoCreate
+}
+
+// SetAutoCreate turns the automatic temp channel creation on or off.
+func (d *SQLServerData) SetAutoCreate(value bool) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.AutoCreate = value
+		return nil
+	})
+}
+
+// AutoCreateVoiceChannelIDs is the list of voice channels the automatic creation applies to.
+func (d *SQLServerData) AutoCreateVoiceChannelIDs() []DiscordID {
+	return append([]DiscordID{}, d.config.AutoCreateVoiceChannelIDs...)
+}
+
+// SetAutoCreateVoiceChannelIDs limits the automatic creation to specific voice channels.
+func (d *SQLServerData) SetAutoCreateVoiceChannelIDs(value []DiscordID) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.AutoCreateVoiceChannelIDs = append([]DiscordID{}, value...)
+		return nil
+	})
+}
+
+// ClearAutoCreateVoiceChannelIDs makes the automatic creation apply to all voice channels.
+func (d *SQLServerData) ClearAutoCreateVoiceChannelIDs() error {
+	return d.SetAutoCreateVoiceChannelIDs(nil)
+}
+
+// HasAutoCreateVoiceChannelIDs returns whether the automatic creation is limited to specific voice channels.
+func (d *SQLServerData) HasAutoCreateVoiceChannelIDs() bool {
+	return len(d.config.AutoCreateVoiceChannelIDs) > 0
+}
+
+// ChannelNameTemplate is the template temp channel names are created from.
+func (d *SQLServerData) ChannelNameTemplate() string {
+	return d.config.ChannelNameTemplate
+}
+
+// SetChannelNameTemplate sets the template temp channel names are created from.
+func (d *SQLServerData) SetChannelNameTemplate(value string) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.ChannelNameTemplate = value
+		return nil
+	})
+}
+
+// ResetChannelNameTemplate resets the temp channel names to random silly names.
+func (d *SQLServerData) ResetChannelNameTemplate() error {
+	return d.SetChannelNameTemplate("")
+}
+
+// HasChannelNameTemplate returns whether a custom channel name template was set.
+func (d *SQLServerData) HasChannelNameTemplate() bool {
+	return d.config.ChannelNameTemplate != ""
+}
+
+// ArchiveFormat is the format temp channel transcripts are archived in before the channels are deleted.
+func (d *SQLServerData) ArchiveFormat() string {
+	return d.config.ArchiveFormat
+}
+
+// SetArchiveFormat sets the transcript format, and enables archiving.
+func (d *SQLServerData) SetArchiveFormat(value string) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.ArchiveFormat = value
+		return nil
+	})
+}
+
+// DisableArchive stops archiving temp channel transcripts.
+func (d *SQLServerData) DisableArchive() error {
+	return d.SetArchiveFormat("")
+}
+
+// ArchiveEnabled returns whether temp channel transcripts are archived.
+func (d *SQLServerData) ArchiveEnabled() bool {
+	return d.config.ArchiveFormat != ""
+}
+
+// ArchiveChannelID is the ID of the channel transcripts are posted to.
+func (d *SQLServerData) ArchiveChannelID() DiscordID {
+	return d.config.ArchiveChannelID
+}
+
+// SetArchiveChannelID sets the channel transcripts are posted to.
+func (d *SQLServerData) SetArchiveChannelID(value DiscordID) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.ArchiveChannelID = value
+		return nil
+	})
+}
+
+// ClearArchiveChannelID makes transcripts be kept in the database instead of posted to a channel.
+func (d *SQLServerData) ClearArchiveChannelID() error {
+	return d.SetArchiveChannelID(DiscordIDNone)
+}
+
+// HasArchiveChannelID returns whether transcripts are posted to a channel.
+func (d *SQLServerData) HasArchiveChannelID() bool {
+	return d.config.ArchiveChannelID != DiscordIDNone
+}
+
+// DeletionGracePeriod is how long an empty temp channel is kept before it's deleted.
+func (d *SQLServerData) DeletionGracePeriod() time.Duration {
+	return d.config.DeletionGracePeriod
+}
+
+// SetDeletionGracePeriod sets how long an empty temp channel is kept before it's deleted.
+// The period is saved in whole seconds.
+func (d *SQLServerData) SetDeletionGracePeriod(value time.Duration) error {
+	return d.Update(func(config *ServerConfig) error {
+		config.DeletionGracePeriod = value
+		return nil
+	})
+}
+
+// ResetDeletionGracePeriod makes empty temp channels be deleted immediately.
+func (d *SQLServerData) ResetDeletionGracePeriod() error {
+	return d.SetDeletionGracePeriod(0)
+}
+
+// CommandPermissionRules returns the rules of who may run each command.
+func (d *SQLServerData) CommandPermissionRules() []CommandPermissionRule {
+	return append([]CommandPermissionRule{}, d.commandPermissions...)
+}
+
+// AddCommandPermissionRule allows the rule's target to run the rule's command.
+func (d *SQLServerData) AddCommandPermissionRule(rule CommandPermissionRule) error {
+	err := assertOneChange(d.db.Exec(addCommandPermission, d.serverID, rule.Command, rule.Type, rule.TargetID, rule.Permissions, time.Now().UTC()))
+	if err != nil {
+		return err
+	}
+
+	d.commandPermissions = append(d.commandPermissions, rule)
+	return nil
+}
+
+// RemoveCommandPermissionRule removes a rule added by AddCommandPermissionRule.
+func (d *SQLServerData) RemoveCommandPermissionRule(rule CommandPermissionRule) error {
+	err := assertOneChange(d.db.Exec(removeCommandPermission, d.serverID, rule.Command, rule.Type, rule.TargetID, rule.Permissions))
+	if err != nil {
+		return err
+	}
+
+	for i, existingRule := range d.commandPermissions {
+		if existingRule == rule {
+			d.commandPermissions = append(d.commandPermissions[:i], d.commandPermissions[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+func assertOneChange(sqlResult sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := sqlResult.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != 1 {
+		return fmt.Errorf("Expected a single row update, got %v", rowsAffected)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package state
+
+import (
+	// SQLite package driver, used by sql.Open()
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// The SQLite tables were created with all of the PostgreSQL columns of the time, as SQLite can't add a column only if it doesn't exist.
+// The first migrations were run on every startup before the migrations were versioned, so they must stay safe to run again.
+const (
+	createSQLiteServersTable = `CREATE TABLE IF NOT EXISTS servers (
+		server_id						bigint			NOT NULL	PRIMARY KEY,
+		command_channel_id				bigint			DEFAULT 0,
+		temp_channel_category_id		bigint			NOT NULL,
+		custom_command					varchar(32)		DEFAULT '',
+		command_prefix					char(1)			DEFAULT '!',
+		last_modified_timestamp			timestamp		NOT NULL,
+		insertion_timestamp				timestamp		NOT NULL,
+		orphan_channel_policy			varchar(16)		DEFAULT 'repermission',
+		auto_create						boolean			DEFAULT false,
+		auto_create_voice_channel_ids	text			DEFAULT '',
+		channel_name_template			varchar(100)	DEFAULT '',
+		archive_format					varchar(16)		DEFAULT '',
+		archive_channel_id				bigint			DEFAULT 0,
+		deletion_grace_period_seconds	integer			DEFAULT 0
+	);`
+	createSQLiteTempChannelsTable = `CREATE TABLE IF NOT EXISTS temp_channels (
+		channel_id					bigint		NOT NULL	PRIMARY KEY,
+		voice_channel_id			bigint		NOT NULL,
+		server_id					bigint		NOT NULL,
+		members						text		NOT NULL	DEFAULT '',
+		creation_timestamp			timestamp	NOT NULL,
+		owner_id					bigint		DEFAULT 0,
+		locked						boolean		DEFAULT false
+	);`
+	createSQLiteTranscriptsTable = `CREATE TABLE IF NOT EXISTS transcripts (
+		transcript_id				integer		PRIMARY KEY	AUTOINCREMENT,
+		server_id					bigint		NOT NULL,
+		channel_id					bigint		NOT NULL,
+		voice_channel_id			bigint		NOT NULL,
+		channel_name				varchar(100)	NOT NULL,
+		format						varchar(16)	NOT NULL,
+		content						text		NOT NULL,
+		creation_timestamp			timestamp	NOT NULL
+	);`
+	addSQLiteServerRemovedColumn   = `ALTER TABLE servers ADD COLUMN removed_timestamp timestamp DEFAULT NULL;`
+	addSQLiteCategoryRoutesColumn  = `ALTER TABLE servers ADD COLUMN category_routes text DEFAULT '';`
+	addSQLiteVoicePoliciesColumn   = `ALTER TABLE servers ADD COLUMN voice_channel_policies text DEFAULT '';`
+	addSQLiteHistoryColumn         = `ALTER TABLE servers ADD COLUMN history_visibility varchar(32) DEFAULT 'hidden';`
+	addSQLiteMaxAgeColumn          = `ALTER TABLE servers ADD COLUMN max_channel_age_seconds integer DEFAULT 0;`
+	addSQLiteMaxIdleColumn         = `ALTER TABLE servers ADD COLUMN max_idle_time_seconds integer DEFAULT 0;`
+	addSQLiteExpiryActionColumn    = `ALTER TABLE servers ADD COLUMN expiry_action varchar(16) DEFAULT 'delete';`
+	addSQLiteMkchCooldownColumn    = `ALTER TABLE servers ADD COLUMN mkch_cooldown_seconds integer DEFAULT 0;`
+	addSQLiteMaxChannelsColumn     = `ALTER TABLE servers ADD COLUMN max_temp_channels integer DEFAULT 0;`
+	addSQLiteInvitedColumn         = `ALTER TABLE temp_channels ADD COLUMN invited text NOT NULL DEFAULT '';`
+	createSQLiteConfigChangesTable = `CREATE TABLE IF NOT EXISTS config_changes (
+		change_id					integer		PRIMARY KEY	AUTOINCREMENT,
+		server_id					bigint		NOT NULL,
+		user_id						bigint		NOT NULL,
+		setting						varchar(32)	NOT NULL,
+		old_value					text		NOT NULL,
+		new_value					text		NOT NULL,
+		change_timestamp			timestamp	NOT NULL
+	);`
+)
+
+var sqliteDialect = &sqlDialect{
+	driverName: "sqlite3",
+	migrations: []migration{
+		{version: 1, name: "create servers table", statement: createSQLiteServersTable},
+		{version: 2, name: "create temp channels table", statement: createSQLiteTempChannelsTable},
+		{version: 3, name: "create transcripts table", statement: createSQLiteTranscriptsTable},
+		{version: 4, name: "create command permissions table", statement: createCommandPermissionsTable},
+		{version: 5, name: "add server removal", statement: addSQLiteServerRemovedColumn},
+		{version: 6, name: "create config changes table", statement: createSQLiteConfigChangesTable},
+		{version: 7, name: "index config changes by server", statement: createConfigChangesIndex},
+		{version: 8, name: "add category routes", statement: addSQLiteCategoryRoutesColumn},
+		{version: 9, name: "add voice channel policies", statement: addSQLiteVoicePoliciesColumn},
+		{version: 10, name: "add history visibility", statement: addSQLiteHistoryColumn},
+		{version: 11, name: "add max channel age", statement: addSQLiteMaxAgeColumn},
+		{version: 12, name: "add max idle time", statement: addSQLiteMaxIdleColumn},
+		{version: 13, name: "add expiry action", statement: addSQLiteExpiryActionColumn},
+		{version: 14, name: "add mkch cooldown", statement: addSQLiteMkchCooldownColumn},
+		{version: 15, name: "add max temp channels", statement: addSQLiteMaxChannelsColumn},
+		{version: 16, name: "add temp channel invited", statement: addSQLiteInvitedColumn},
+	},
+
+	// $n placeholders are named parameters in SQLite, numbered by their order in the query rather than by n.
+	rebind: func(query string) string { return postgresPlaceholderRegex.ReplaceAllString(query, "?$1") },
+
+	// SQLite allows a single writer, and every connection to ":memory:" opens a different database.
+	maxOpenConns: 1,
+}
+
+// NewSQLiteServersProvider initializes a new instance of SQLServersProvider over an SQLite database file.
+// The file is created if it doesn't exist.
+func NewSQLiteServersProvider(path string) (*SQLServersProvider, error) {
+	return newSQLServersProvider(sqliteDialect, path)
+}
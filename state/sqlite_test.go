@@ -0,0 +1,66 @@
+package state_test
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+	"github.com/jonathroth/temp-chat/state/statetest"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSQLiteServersProvider(t *testing.T) {
+	directory, err := ioutil.TempDir("", "temp-chat")
+	require.NoError(t, err)
+	defer os.RemoveAll(directory)
+
+	// Every test gets its own database file.
+	tests := 0
+	open := func() state.ServersProvider {
+		provider, err := state.NewSQLiteServersProvider(filepath.Join(directory, fmt.Sprintf("temp-chat-%v.db", tests)))
+		require.NoError(t, err)
+		return provider
+	}
+
+	suite.Run(t, &statetest.ProviderSuite{
+		NewProvider: func() state.ServersProvider {
+			tests++
+			return open()
+		},
+		Reopen: open,
+	})
+}
+
+func TestSQLiteFailedUpdateKeepsSettings(t *testing.T) {
+	directory, err := ioutil.TempDir("", "temp-chat")
+	require.NoError(t, err)
+	defer os.RemoveAll(directory)
+
+	path := filepath.Join(directory, "temp-chat.db")
+	provider, err := state.NewSQLiteServersProvider(path)
+	require.NoError(t, err)
+	serverData, err := provider.AddServer(1, 2)
+	require.NoError(t, err)
+
+	// Deleting the row behind the provider's back makes the write fail.
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("DELETE FROM servers WHERE server_id = 1;")
+	require.NoError(t, err)
+
+	err = serverData.Update(func(config *state.ServerConfig) error {
+		config.CommandPrefix = "?"
+		config.AutoCreate = true
+		return nil
+	})
+	require.Error(t, err)
+	require.Equal(t, consts.DefaultCommandPrefix, serverData.CommandPrefix(), "The settings changed in memory after a failed write")
+	require.False(t, serverData.AutoCreate(), "The settings changed in memory after a failed write")
+}
@@ -0,0 +1,610 @@
+// Package statetest has the conformance suite every state.ServersProvider implementation should pass.
+package statetest
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jonathroth/temp-chat/consts"
+	"github.com/jonathroth/temp-chat/state"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	testServerID      = state.DiscordID(1)
+	testCategoryID    = state.DiscordID(2)
+	otherTestServerID = state.DiscordID(3)
+)
+
+// ProviderSuite verifies a ServersProvider behaves like the bot expects, run it with suite.Run().
+// Providers that also implement state.TempChannelStore and state.TranscriptStore are checked for those as well.
+type ProviderSuite struct {
+	suite.Suite
+
+	// NewProvider returns an empty provider, it's called before every test.
+	NewProvider func() state.ServersProvider
+
+	// Reopen returns a new provider over the storage of the last one NewProvider returned, as the bot does when it restarts.
+	// It's nil for providers that don't persist anything, in which case the data is read back from the same provider.
+	Reopen func() state.ServersProvider
+
+	provider state.ServersProvider
+}
+
+// setting is a single server setting, changed by set and restored to its default by reset.
+type setting struct {
+	name  string
+	set   func(data state.ServerData) error
+	reset func(data state.ServerData) error
+	// isSet returns whether data has the value set puts, and isDefault whether it has the value of a new server.
+	isSet     func(data state.ServerData) bool
+	isDefault func(data state.ServerData) bool
+}
+
+// update returns a setter of settings that are only changed through ServerData.Update.
+func update(change func(config *state.ServerConfig)) func(data state.ServerData) error {
+	return func(data state.ServerData) error {
+		return data.Update(func(config *state.ServerConfig) error {
+			change(config)
+			return nil
+		})
+	}
+}
+
+// voiceChannelPolicy overrides several settings, its name template has the separator of other lists in it.
+var voiceChannelPolicy = state.VoiceChannelPolicy{
+	VoiceChannelID:      17,
+	MkchDisabled:        true,
+	ChannelNameTemplate: "{voice}, {n}",
+	HistoryVisibility:   state.HistoryVisibility{Mode: consts.HistoryModeRecap, RecapPeriod: 5 * time.Minute},
+}
+
+var settings = []setting{
+	{
+		name:      "TempChannelCategoryID",
+		set:       func(data state.ServerData) error { return data.SetTempChannelCategoryID(10) },
+		isSet:     func(data state.ServerData) bool { return data.TempChannelCategoryID() == 10 },
+		isDefault: func(data state.ServerData) bool { return data.TempChannelCategoryID() == testCategoryID },
+	},
+	{
+		name:  "CustomCommandPrefix",
+		set:   func(data state.ServerData) error { return data.SetCustomCommandPrefix("?") },
+		reset: func(data state.ServerData) error { return data.ResetCommandPrefix() },
+		isSet: func(data state.ServerData) bool { return data.HasDifferentPrefix() && data.CommandPrefix() == "?" },
+		isDefault: func(data state.ServerData) bool {
+			return !data.HasDifferentPrefix() && data.CommandPrefix() == consts.DefaultCommandPrefix
+		},
+	},
+	{
+		name:      "CommandChannelID",
+		set:       func(data state.ServerData) error { return data.SetCommandChannelID(11) },
+		reset:     func(data state.ServerData) error { return data.ClearCommandChannelID() },
+		isSet:     func(data state.ServerData) bool { return data.HasCommandChannelID() && data.CommandChannelID() == 11 },
+		isDefault: func(data state.ServerData) bool { return !data.HasCommandChannelID() },
+	},
+	{
+		name:      "CustomCommand",
+		set:       func(data state.ServerData) error { return data.SetCustomCommand("tmp") },
+		reset:     func(data state.ServerData) error { return data.ResetCustomCommand() },
+		isSet:     func(data state.ServerData) bool { return data.HasCustomCommand() && data.CustomCommand() == "tmp" },
+		isDefault: func(data state.ServerData) bool { return !data.HasCustomCommand() },
+	},
+	{
+		name:      "OrphanChannelPolicy",
+		set:       func(data state.ServerData) error { return data.SetOrphanChannelPolicy(consts.OrphanPolicyAdopt) },
+		reset:     func(data state.ServerData) error { return data.ResetOrphanChannelPolicy() },
+		isSet:     func(data state.ServerData) bool { return data.OrphanChannelPolicy() == consts.OrphanPolicyAdopt },
+		isDefault: func(data state.ServerData) bool { return data.OrphanChannelPolicy() == consts.DefaultOrphanPolicy },
+	},
+	{
+		name:      "AutoCreate",
+		set:       func(data state.ServerData) error { return data.SetAutoCreate(true) },
+		reset:     func(data state.ServerData) error { return data.SetAutoCreate(false) },
+		isSet:     func(data state.ServerData) bool { return data.AutoCreate() },
+		isDefault: func(data state.ServerData) bool { return !data.AutoCreate() },
+	},
+	{
+		name:  "AutoCreateVoiceChannelIDs",
+		set:   func(data state.ServerData) error { return data.SetAutoCreateVoiceChannelIDs([]state.DiscordID{12, 13}) },
+		reset: func(data state.ServerData) error { return data.ClearAutoCreateVoiceChannelIDs() },
+		isSet: func(data state.ServerData) bool {
+			ids := data.AutoCreateVoiceChannelIDs()
+			return data.HasAutoCreateVoiceChannelIDs() && len(ids) == 2 && ids[0] == 12 && ids[1] == 13
+		},
+		isDefault: func(data state.ServerData) bool {
+			return !data.HasAutoCreateVoiceChannelIDs() && len(data.AutoCreateVoiceChannelIDs()) == 0
+		},
+	},
+	{
+		name:  "ChannelNameTemplate",
+		set:   func(data state.ServerData) error { return data.SetChannelNameTemplate("{voice}-chat") },
+		reset: func(data state.ServerData) error { return data.ResetChannelNameTemplate() },
+		isSet: func(data state.ServerData) bool {
+			return data.HasChannelNameTemplate() && data.ChannelNameTemplate() == "{voice}-chat"
+		},
+		isDefault: func(data state.ServerData) bool { return !data.HasChannelNameTemplate() },
+	},
+	{
+		name:      "ArchiveFormat",
+		set:       func(data state.ServerData) error { return data.SetArchiveFormat("html") },
+		reset:     func(data state.ServerData) error { return data.DisableArchive() },
+		isSet:     func(data state.ServerData) bool { return data.ArchiveEnabled() && data.ArchiveFormat() == "html" },
+		isDefault: func(data state.ServerData) bool { return !data.ArchiveEnabled() },
+	},
+	{
+		name:      "ArchiveChannelID",
+		set:       func(data state.ServerData) error { return data.SetArchiveChannelID(14) },
+		reset:     func(data state.ServerData) error { return data.ClearArchiveChannelID() },
+		isSet:     func(data state.ServerData) bool { return data.HasArchiveChannelID() && data.ArchiveChannelID() == 14 },
+		isDefault: func(data state.ServerData) bool { return !data.HasArchiveChannelID() },
+	},
+	{
+		name:      "DeletionGracePeriod",
+		set:       func(data state.ServerData) error { return data.SetDeletionGracePeriod(90 * time.Second) },
+		reset:     func(data state.ServerData) error { return data.ResetDeletionGracePeriod() },
+		isSet:     func(data state.ServerData) bool { return data.DeletionGracePeriod() == 90*time.Second },
+		isDefault: func(data state.ServerData) bool { return data.DeletionGracePeriod() == 0 },
+	},
+	{
+		name:      "MaxChannelAge",
+		set:       update(func(config *state.ServerConfig) { config.MaxChannelAge = 24 * time.Hour }),
+		reset:     update(func(config *state.ServerConfig) { config.MaxChannelAge = 0 }),
+		isSet:     func(data state.ServerData) bool { return data.Config().MaxChannelAge == 24*time.Hour },
+		isDefault: func(data state.ServerData) bool { return data.Config().MaxChannelAge == 0 },
+	},
+	{
+		name:      "MaxIdleTime",
+		set:       update(func(config *state.ServerConfig) { config.MaxIdleTime = 2 * time.Hour }),
+		reset:     update(func(config *state.ServerConfig) { config.MaxIdleTime = 0 }),
+		isSet:     func(data state.ServerData) bool { return data.Config().MaxIdleTime == 2*time.Hour },
+		isDefault: func(data state.ServerData) bool { return data.Config().MaxIdleTime == 0 },
+	},
+	{
+		name:      "ExpiryAction",
+		set:       update(func(config *state.ServerConfig) { config.ExpiryAction = consts.ExpiryActionRotate }),
+		reset:     update(func(config *state.ServerConfig) { config.ExpiryAction = consts.DefaultExpiryAction }),
+		isSet:     func(data state.ServerData) bool { return data.Config().ExpiryAction == consts.ExpiryActionRotate },
+		isDefault: func(data state.ServerData) bool { return data.Config().ExpiryAction == consts.DefaultExpiryAction },
+	},
+	{
+		name:      "MkchCooldown",
+		set:       update(func(config *state.ServerConfig) { config.MkchCooldown = 30 * time.Second }),
+		reset:     update(func(config *state.ServerConfig) { config.MkchCooldown = 0 }),
+		isSet:     func(data state.ServerData) bool { return data.Config().MkchCooldown == 30*time.Second },
+		isDefault: func(data state.ServerData) bool { return data.Config().MkchCooldown == 0 },
+	},
+	{
+		name:      "MaxTempChannels",
+		set:       update(func(config *state.ServerConfig) { config.MaxTempChannels = 10 }),
+		reset:     update(func(config *state.ServerConfig) { config.MaxTempChannels = 0 }),
+		isSet:     func(data state.ServerData) bool { return data.Config().MaxTempChannels == 10 },
+		isDefault: func(data state.ServerData) bool { return data.Config().MaxTempChannels == 0 },
+	},
+	{
+		name: "CategoryRoutes",
+		set: update(func(config *state.ServerConfig) {
+			config.SetCategoryRoute(state.CategoryRoute{SourceID: 15, CategoryID: 16})
+		}),
+		reset: func(data state.ServerData) error {
+			return data.Update(func(config *state.ServerConfig) error { return config.RemoveCategoryRoute(15) })
+		},
+		isSet: func(data state.ServerData) bool {
+			routes := data.Config().CategoryRoutes
+			return len(routes) == 1 && routes[0] == state.CategoryRoute{SourceID: 15, CategoryID: 16}
+		},
+		isDefault: func(data state.ServerData) bool { return len(data.Config().CategoryRoutes) == 0 },
+	},
+	{
+		name: "HistoryVisibility",
+		set: update(func(config *state.ServerConfig) {
+			config.HistoryVisibility = state.HistoryVisibility{Mode: consts.HistoryModeRecap, RecapPeriod: 15 * time.Minute}
+		}),
+		reset: update(func(config *state.ServerConfig) {
+			config.HistoryVisibility = state.HistoryVisibility{Mode: consts.DefaultHistoryMode}
+		}),
+		isSet: func(data state.ServerData) bool {
+			return data.Config().HistoryVisibility == state.HistoryVisibility{Mode: consts.HistoryModeRecap, RecapPeriod: 15 * time.Minute}
+		},
+		isDefault: func(data state.ServerData) bool {
+			return data.Config().HistoryVisibility == state.HistoryVisibility{Mode: consts.DefaultHistoryMode}
+		},
+	},
+	{
+		name: "VoiceChannelPolicies",
+		set:  update(func(config *state.ServerConfig) { config.SetVoiceChannelPolicy(voiceChannelPolicy) }),
+		reset: update(func(config *state.ServerConfig) {
+			config.SetVoiceChannelPolicy(state.VoiceChannelPolicy{VoiceChannelID: 17})
+		}),
+		isSet: func(data state.ServerData) bool {
+			policies := data.Config().VoiceChannelPolicies
+			return len(policies) == 1 && policies[0] == voiceChannelPolicy
+		},
+		isDefault: func(data state.ServerData) bool { return len(data.Config().VoiceChannelPolicies) == 0 },
+	},
+}
+
+// SetupTest creates the provider of the test.
+func (s *ProviderSuite) SetupTest() {
+	s.Require().NotNil(s.NewProvider, "ProviderSuite.NewProvider must be set")
+	s.provider = s.NewProvider()
+}
+
+// reload reads the servers back, from a reopened provider if it persists its data.
+func (s *ProviderSuite) reload() state.ServersData {
+	provider := s.provider
+	if s.Reopen != nil {
+		provider = s.Reopen()
+	}
+
+	servers, err := provider.Servers()
+	s.Require().NoError(err)
+	return servers
+}
+
+func (s *ProviderSuite) reloadServer(serverID state.DiscordID) state.ServerData {
+	servers := s.reload()
+	s.Require().Contains(servers, serverID)
+	return servers[serverID]
+}
+
+func (s *ProviderSuite) addServer(serverID state.DiscordID) state.ServerData {
+	serverData, err := s.provider.AddServer(serverID, testCategoryID)
+	s.Require().NoError(err)
+	return serverData
+}
+
+// TestNoServers checks a new provider is empty.
+func (s *ProviderSuite) TestNoServers() {
+	servers, err := s.provider.Servers()
+	s.Require().NoError(err)
+	s.Empty(servers)
+}
+
+// TestAddServer checks a new server has the default settings, and that adding it again fails.
+func (s *ProviderSuite) TestAddServer() {
+	serverData := s.addServer(testServerID)
+	s.Equal(testServerID, serverData.ServerID())
+
+	for _, data := range []state.ServerData{serverData, s.reloadServer(testServerID)} {
+		s.Equal(testServerID, data.ServerID())
+		s.Empty(data.CommandPermissionRules())
+		for _, setting := range settings {
+			s.True(setting.isDefault(data), "%v of a new server isn't the default", setting.name)
+		}
+	}
+
+	_, err := s.provider.AddServer(testServerID, testCategoryID)
+	s.Error(err, "Adding an existing server should fail")
+}
+
+// TestServers checks Servers returns every added server.
+func (s *ProviderSuite) TestServers() {
+	s.addServer(testServerID)
+	s.addServer(otherTestServerID)
+
+	servers := s.reload()
+	s.Len(servers, 2)
+	s.Contains(servers, testServerID)
+	s.Contains(servers, otherTestServerID)
+}
+
+// TestSettings checks every setting is kept after it's set, and goes back to its default after it's reset.
+func (s *ProviderSuite) TestSettings() {
+	for _, setting := range settings {
+		s.Run(setting.name, func() {
+			s.SetupTest()
+			serverData := s.addServer(testServerID)
+			otherServerData := s.addServer(otherTestServerID)
+
+			s.Require().NoError(setting.set(serverData))
+			s.True(setting.isSet(serverData), "%v wasn't set", setting.name)
+			s.True(setting.isSet(s.reloadServer(testServerID)), "%v wasn't saved", setting.name)
+			s.True(setting.isDefault(otherServerData), "%v changed in another server", setting.name)
+			s.True(setting.isDefault(s.reloadServer(otherTestServerID)), "%v changed in another server", setting.name)
+
+			if setting.reset == nil {
+				return
+			}
+
+			s.Require().NoError(setting.reset(serverData))
+			s.True(setting.isDefault(serverData), "%v wasn't reset", setting.name)
+			s.True(setting.isDefault(s.reloadServer(testServerID)), "%v wasn't reset in the store", setting.name)
+		})
+	}
+}
+
+// TestUpdate checks several settings are changed and saved by a single update.
+func (s *ProviderSuite) TestUpdate() {
+	serverData := s.addServer(testServerID)
+
+	s.Require().NoError(serverData.Update(func(config *state.ServerConfig) error {
+		config.CommandPrefix = "?"
+		config.AutoCreate = true
+		config.AutoCreateVoiceChannelIDs = []state.DiscordID{12, 13}
+		config.DeletionGracePeriod = 90*time.Second + time.Millisecond
+		return nil
+	}))
+
+	for _, data := range []state.ServerData{serverData, s.reloadServer(testServerID)} {
+		s.Equal("?", data.CommandPrefix())
+		s.True(data.AutoCreate())
+		s.Equal([]state.DiscordID{12, 13}, data.AutoCreateVoiceChannelIDs())
+		s.Equal(90*time.Second, data.DeletionGracePeriod())
+		s.Equal(consts.DefaultOrphanPolicy, data.OrphanChannelPolicy(), "An unchanged setting changed")
+	}
+}
+
+// TestConcurrentUpdates checks updates made at the same time through a SyncServerStore aren't lost, run it with -race.
+func (s *ProviderSuite) TestConcurrentUpdates() {
+	s.addServer(testServerID)
+	store, err := state.NewSyncServerStore(s.provider)
+	s.Require().NoError(err)
+	serverData, found := store.Server(testServerID)
+	s.Require().True(found)
+
+	const updates = 20
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serverData.Config()
+			s.NoError(serverData.Update(func(config *state.ServerConfig) error {
+				config.MaxTempChannels++
+				return nil
+			}))
+		}()
+	}
+	wg.Wait()
+
+	s.Equal(updates, serverData.Config().MaxTempChannels)
+	s.Equal(updates, s.reloadServer(testServerID).Config().MaxTempChannels, "An update was lost")
+}
+
+// TestFailedUpdateKeepsSettings checks the settings don't change if the update function or the check of its settings fail.
+func (s *ProviderSuite) TestFailedUpdateKeepsSettings() {
+	serverData := s.addServer(testServerID)
+	before := serverData.Config()
+
+	err := serverData.Update(func(config *state.ServerConfig) error {
+		config.CommandPrefix = "?"
+		return errors.New("Update failed")
+	})
+	s.Error(err)
+
+	err = serverData.Update(func(config *state.ServerConfig) error {
+		config.CommandPrefix = "?"
+		config.OrphanChannelPolicy = "invalid"
+		return nil
+	})
+	s.Error(err, "An invalid orphan channel policy was saved")
+
+	err = serverData.Update(func(config *state.ServerConfig) error {
+		config.AutoCreateVoiceChannelIDs = append(config.AutoCreateVoiceChannelIDs, 12)
+		config.DeletionGracePeriod = -time.Second
+		return nil
+	})
+	s.Error(err, "A negative grace period was saved")
+
+	err = update(func(config *state.ServerConfig) { config.MaxIdleTime = time.Minute })(serverData)
+	s.Error(err, "A maximum idle time shorter than the expiry warning was saved")
+
+	err = update(func(config *state.ServerConfig) { config.MaxTempChannels = -1 })(serverData)
+	s.Error(err, "A negative maximum number of temp channels was saved")
+
+	err = update(func(config *state.ServerConfig) {
+		config.SetVoiceChannelPolicy(state.VoiceChannelPolicy{VoiceChannelID: 12, MkchDisabled: true, ForceAutoCreate: true})
+	})(serverData)
+	s.Error(err, "A voice channel policy that both disables and forces temp channels was saved")
+
+	err = update(func(config *state.ServerConfig) {
+		config.HistoryVisibility = state.HistoryVisibility{Mode: consts.HistoryModeVisible, RecapPeriod: time.Minute}
+	})(serverData)
+	s.Error(err, "A recap period was saved for a history mode without recaps")
+
+	s.Equal(before, serverData.Config())
+	s.Equal(before, s.reloadServer(testServerID).Config())
+}
+
+// TestCommandPermissionRules checks rules are added and removed, and that duplicate and missing rules fail.
+func (s *ProviderSuite) TestCommandPermissionRules() {
+	serverData := s.addServer(testServerID)
+	roleRule := state.CommandPermissionRule{Command: "mkch", Type: consts.PermissionRuleRole, TargetID: 20}
+	permissionRule := state.CommandPermissionRule{Command: "setup", Type: consts.PermissionRulePermission, Permissions: 1 << 4}
+
+	s.Require().NoError(serverData.AddCommandPermissionRule(roleRule))
+	s.Require().NoError(serverData.AddCommandPermissionRule(permissionRule))
+	s.Error(serverData.AddCommandPermissionRule(roleRule), "Adding an existing rule should fail")
+	s.ElementsMatch([]state.CommandPermissionRule{roleRule, permissionRule}, serverData.CommandPermissionRules())
+	s.ElementsMatch([]state.CommandPermissionRule{roleRule, permissionRule}, s.reloadServer(testServerID).CommandPermissionRules())
+
+	s.Require().NoError(serverData.RemoveCommandPermissionRule(roleRule))
+	s.Error(serverData.RemoveCommandPermissionRule(roleRule), "Removing a missing rule should fail")
+	s.Equal([]state.CommandPermissionRule{permissionRule}, serverData.CommandPermissionRules())
+	s.Equal([]state.CommandPermissionRule{permissionRule}, s.reloadServer(testServerID).CommandPermissionRules())
+}
+
+// TestTempChannels checks temp channels are saved, updated and removed.
+func (s *ProviderSuite) TestTempChannels() {
+	store, ok := s.provider.(state.TempChannelStore)
+	if !ok {
+		s.T().Skip("The provider doesn't store temp channels")
+	}
+
+	createdAt := time.Date(2020, 5, 1, 12, 30, 0, 0, time.UTC)
+	s.Require().NoError(store.AddTempChannel(&state.TempChannelData{
+		ChannelID:      30,
+		VoiceChannelID: 31,
+		ServerID:       testServerID,
+		Members:        []state.DiscordID{32},
+		CreatedAt:      createdAt,
+		OwnerID:        32,
+	}))
+	s.Require().NoError(store.AddTempChannel(&state.TempChannelData{ChannelID: 40, VoiceChannelID: 41, ServerID: testServerID, CreatedAt: createdAt}))
+	s.Error(store.AddTempChannel(&state.TempChannelData{ChannelID: 40, VoiceChannelID: 41, ServerID: testServerID, CreatedAt: createdAt}),
+		"Adding an existing temp channel should fail")
+
+	s.Require().NoError(store.SetTempChannelMembers(30, []state.DiscordID{32, 33}))
+	s.Require().NoError(store.SetTempChannelOwner(30, 33))
+	s.Require().NoError(store.SetTempChannelLocked(30, true))
+	s.Require().NoError(store.SetTempChannelInvited(30, []state.DiscordID{34}))
+	s.Require().NoError(store.RemoveTempChannel(40))
+	s.NoError(store.RemoveTempChannel(40), "Removing a missing temp channel should do nothing")
+	s.Error(store.SetTempChannelMembers(40, nil), "Updating a removed temp channel should fail")
+	s.Error(store.SetTempChannelOwner(40, 33), "Updating a removed temp channel should fail")
+	s.Error(store.SetTempChannelLocked(40, true), "Updating a removed temp channel should fail")
+	s.Error(store.SetTempChannelInvited(40, nil), "Updating a removed temp channel should fail")
+
+	reloaded := store
+	if s.Reopen != nil {
+		reloaded = s.Reopen().(state.TempChannelStore)
+	}
+
+	tempChannels, err := reloaded.TempChannels()
+	s.Require().NoError(err)
+	s.Require().Len(tempChannels, 1)
+	s.Equal(state.DiscordID(30), tempChannels[0].ChannelID)
+	s.Equal(state.DiscordID(31), tempChannels[0].VoiceChannelID)
+	s.Equal(testServerID, tempChannels[0].ServerID)
+	s.Equal([]state.DiscordID{32, 33}, tempChannels[0].Members)
+	s.Equal(state.DiscordID(33), tempChannels[0].OwnerID)
+	s.True(tempChannels[0].Locked)
+	s.Equal([]state.DiscordID{34}, tempChannels[0].Invited)
+	s.True(createdAt.Equal(tempChannels[0].CreatedAt), "Expected creation time %v, got %v", createdAt, tempChannels[0].CreatedAt)
+}
+
+// TestAddTranscript checks transcripts are saved, including several of the same channel.
+func (s *ProviderSuite) TestAddTranscript() {
+	store, ok := s.provider.(state.TranscriptStore)
+	if !ok {
+		s.T().Skip("The provider doesn't store transcripts")
+	}
+
+	transcript := &state.TranscriptData{ServerID: testServerID, ChannelID: 30, VoiceChannelID: 31, ChannelName: "silly-name", Format: "text", Content: "hi", CreatedAt: time.Now()}
+	s.NoError(store.AddTranscript(transcript))
+	s.NoError(store.AddTranscript(transcript))
+}
+
+// TestRemoveServer checks a removed server is no longer returned, and that removing a missing server fails.
+func (s *ProviderSuite) TestRemoveServer() {
+	s.addServer(testServerID)
+	s.addServer(otherTestServerID)
+
+	s.Require().NoError(s.provider.RemoveServer(testServerID))
+	servers := s.reload()
+	s.NotContains(servers, testServerID)
+	s.Contains(servers, otherTestServerID)
+
+	s.Error(s.provider.RemoveServer(testServerID), "Removing a removed server should fail")
+	s.Error(s.provider.RemoveServer(state.DiscordID(100)), "Removing a missing server should fail")
+}
+
+// TestRestoreServer checks a removed server gets its settings back.
+func (s *ProviderSuite) TestRestoreServer() {
+	serverData := s.addServer(testServerID)
+	rule := state.CommandPermissionRule{Command: "mkch", Type: consts.PermissionRuleRole, TargetID: 20}
+	s.Require().NoError(serverData.SetCustomCommandPrefix("?"))
+	s.Require().NoError(serverData.AddCommandPermissionRule(rule))
+	s.Require().NoError(s.provider.RemoveServer(testServerID))
+
+	restored, found, err := s.provider.RestoreServer(testServerID)
+	s.Require().NoError(err)
+	s.Require().True(found, "The removed server wasn't restored")
+	for _, data := range []state.ServerData{restored, s.reloadServer(testServerID)} {
+		s.Equal("?", data.CommandPrefix())
+		s.Equal([]state.CommandPermissionRule{rule}, data.CommandPermissionRules())
+	}
+
+	_, found, err = s.provider.RestoreServer(testServerID)
+	s.Require().NoError(err)
+	s.False(found, "A server that wasn't removed was restored")
+}
+
+// TestAddRemovedServer checks adding a removed server again restores its settings, with the new category.
+func (s *ProviderSuite) TestAddRemovedServer() {
+	serverData := s.addServer(testServerID)
+	s.Require().NoError(serverData.SetCustomCommandPrefix("?"))
+	s.Require().NoError(s.provider.RemoveServer(testServerID))
+
+	readded, err := s.provider.AddServer(testServerID, state.DiscordID(10))
+	s.Require().NoError(err)
+	for _, data := range []state.ServerData{readded, s.reloadServer(testServerID)} {
+		s.Equal(state.DiscordID(10), data.TempChannelCategoryID())
+		s.Equal("?", data.CommandPrefix())
+	}
+}
+
+// TestPurgeRemovedServers checks only servers removed before the given time are purged, and can't be restored afterwards.
+func (s *ProviderSuite) TestPurgeRemovedServers() {
+	serverData := s.addServer(testServerID)
+	s.Require().NoError(serverData.SetCustomCommandPrefix("?"))
+	s.Require().NoError(serverData.AddCommandPermissionRule(state.CommandPermissionRule{Command: "mkch", Type: consts.PermissionRuleRole, TargetID: 20}))
+	s.addServer(otherTestServerID)
+	s.Require().NoError(s.provider.RemoveServer(testServerID))
+
+	purged, err := s.provider.PurgeRemovedServers(time.Now().Add(-time.Hour))
+	s.Require().NoError(err)
+	s.Equal(0, purged, "A server removed after the given time was purged")
+
+	purged, err = s.provider.PurgeRemovedServers(time.Now().Add(time.Hour))
+	s.Require().NoError(err)
+	s.Equal(1, purged, "Only the removed server should be purged")
+	s.Contains(s.reload(), otherTestServerID)
+
+	_, found, err := s.provider.RestoreServer(testServerID)
+	s.Require().NoError(err)
+	s.False(found, "A purged server was restored")
+
+	readded := s.addServer(testServerID)
+	s.Equal(consts.DefaultCommandPrefix, readded.CommandPrefix(), "A purged server kept its settings")
+	s.Empty(readded.CommandPermissionRules(), "A purged server kept its rules")
+}
+
+// TestConfigChanges checks changes are returned from newest to oldest, only to their server.
+func (s *ProviderSuite) TestConfigChanges() {
+	store, ok := s.provider.(state.ConfigChangeStore)
+	if !ok {
+		s.T().Skip("The provider doesn't store config changes")
+	}
+
+	changedAt := time.Date(2020, 5, 1, 12, 30, 0, 0, time.UTC)
+	changes := []*state.ConfigChange{
+		{ServerID: testServerID, UserID: 50, Setting: state.SettingCommandPrefix, OldValue: "!", NewValue: "?", ChangedAt: changedAt},
+		{ServerID: otherTestServerID, UserID: 50, Setting: state.SettingCommandPrefix, OldValue: "!", NewValue: "$", ChangedAt: changedAt},
+		{ServerID: testServerID, UserID: 51, Setting: state.SettingCommandChannel, OldValue: "", NewValue: "52", ChangedAt: changedAt},
+		{ServerID: testServerID, UserID: 50, Setting: state.SettingCommandPrefix, OldValue: "?", NewValue: "!", ChangedAt: changedAt},
+	}
+
+	for _, change := range changes {
+		s.Require().NoError(store.AddConfigChange(change))
+	}
+
+	s.Less(changes[0].ChangeID, changes[2].ChangeID, "Change IDs should increase")
+	s.Less(changes[2].ChangeID, changes[3].ChangeID, "Change IDs should increase")
+
+	newest, err := store.ConfigChanges(testServerID, 0, 2)
+	s.Require().NoError(err)
+	s.Require().Len(newest, 2)
+	s.Equal(changes[3].ChangeID, newest[0].ChangeID)
+	s.Equal(changes[2].ChangeID, newest[1].ChangeID)
+
+	oldest, err := store.ConfigChanges(testServerID, 2, 2)
+	s.Require().NoError(err)
+	s.Require().Len(oldest, 1)
+	s.Equal(changes[0].ChangeID, oldest[0].ChangeID)
+
+	change, found, err := store.ConfigChange(testServerID, changes[2].ChangeID)
+	s.Require().NoError(err)
+	s.Require().True(found)
+	s.Equal(testServerID, change.ServerID)
+	s.Equal(state.DiscordID(51), change.UserID)
+	s.Equal(state.SettingCommandChannel, change.Setting)
+	s.Equal("", change.OldValue)
+	s.Equal("52", change.NewValue)
+	s.True(changedAt.Equal(change.ChangedAt), "Expected change time %v, got %v", changedAt, change.ChangedAt)
+
+	_, found, err = store.ConfigChange(testServerID, changes[1].ChangeID)
+	s.Require().NoError(err)
+	s.False(found, "A change of another server was returned")
+}
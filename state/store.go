@@ -1,6 +1,14 @@
 package state
 
-import "strconv"
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonathroth/temp-chat/consts"
+)
 
 // DiscordID is a unique identifier used by the Discord API.
 type DiscordID uint64
@@ -43,6 +51,14 @@ type ServerData interface {
 	// ServerID returns the ID of the server whose data is saved in this object.
 	ServerID() DiscordID
 
+	// Config returns a copy of the server's settings.
+	Config() ServerConfig
+	// Update changes several settings at once. The update function changes a copy of the settings,
+	// which is checked and saved as a whole only if the function succeeds.
+	// The settings are left as they were if the function, the check or the save fails.
+	// Settings without their own methods below, like the routes, the policies and the expiry, are only read and changed through Config and Update.
+	Update(update func(config *ServerConfig) error) error
+
 	// TempChannelCategoryID is the category Discord ID of the category to create temporary chat channels in.
 	TempChannelCategoryID() DiscordID
 	// SetTempChannelCategoryID sets a new channel category.
@@ -74,6 +90,433 @@ type ServerData interface {
 	ResetCustomCommand() error
 	// HasCustomCommand returns whether the make-temp-channel was assigned an alternative name.
 	HasCustomCommand() bool
+
+	// OrphanChannelPolicy is what the bot does with text channels in the temp category it doesn't track.
+	OrphanChannelPolicy() string
+	// SetOrphanChannelPolicy sets the policy for untracked text channels in the temp category.
+	SetOrphanChannelPolicy(value string) error
+	// ResetOrphanChannelPolicy resets the orphan channel policy to the default value.
+	ResetOrphanChannelPolicy() error
+
+	// AutoCreate returns whether temp channels are created automatically when users join a voice chat.
+	AutoCreate() bool
+	// SetAutoCreate turns the automatic temp channel creation on or off.
+	SetAutoCreate(value bool) error
+
+	// AutoCreateVoiceChannelIDs is the list of voice channels the automatic creation applies to.
+	AutoCreateVoiceChannelIDs() []DiscordID
+	// SetAutoCreateVoiceChannelIDs limits the automatic creation to specific voice channels.
+	SetAutoCreateVoiceChannelIDs(value []DiscordID) error
+	// ClearAutoCreateVoiceChannelIDs makes the automatic creation apply to all voice channels.
+	ClearAutoCreateVoiceChannelIDs() error
+	// HasAutoCreateVoiceChannelIDs returns whether the automatic creation is limited to specific voice channels.
+	HasAutoCreateVoiceChannelIDs() bool
+
+	// ChannelNameTemplate is the template temp channel names are created from.
+	ChannelNameTemplate() string
+	// SetChannelNameTemplate sets the template temp channel names are created from.
+	SetChannelNameTemplate(value string) error
+	// ResetChannelNameTemplate resets the temp channel names to random silly names.
+	ResetChannelNameTemplate() error
+	// HasChannelNameTemplate returns whether a custom channel name template was set.
+	HasChannelNameTemplate() bool
+
+	// ArchiveFormat is the format temp channel transcripts are archived in before the channels are deleted.
+	ArchiveFormat() string
+	// SetArchiveFormat sets the transcript format, and enables archiving.
+	SetArchiveFormat(value string) error
+	// DisableArchive stops archiving temp channel transcripts.
+	DisableArchive() error
+	// ArchiveEnabled returns whether temp channel transcripts are archived.
+	ArchiveEnabled() bool
+
+	// ArchiveChannelID is the ID of the channel transcripts are posted to.
+	ArchiveChannelID() DiscordID
+	// SetArchiveChannelID sets the channel transcripts are posted to.
+	SetArchiveChannelID(value DiscordID) error
+	// ClearArchiveChannelID makes transcripts be kept in the database instead of posted to a channel.
+	ClearArchiveChannelID() error
+	// HasArchiveChannelID returns whether transcripts are posted to a channel.
+	HasArchiveChannelID() bool
+
+	// DeletionGracePeriod is how long an empty temp channel is kept before it's deleted.
+	DeletionGracePeriod() time.Duration
+	// SetDeletionGracePeriod sets how long an empty temp channel is kept before it's deleted.
+	SetDeletionGracePeriod(value time.Duration) error
+	// ResetDeletionGracePeriod makes empty temp channels be deleted immediately.
+	ResetDeletionGracePeriod() error
+
+	// CommandPermissionRules returns the rules of who may run each command.
+	// Commands without rules fall back to their default permissions.
+	CommandPermissionRules() []CommandPermissionRule
+	// AddCommandPermissionRule allows the rule's target to run the rule's command.
+	AddCommandPermissionRule(rule CommandPermissionRule) error
+	// RemoveCommandPermissionRule removes a rule added by AddCommandPermissionRule.
+	RemoveCommandPermissionRule(rule CommandPermissionRule) error
+}
+
+// ServerConfig is the settings of a server, as changed by ServerData.Update.
+// Command permission rules aren't part of it, they're changed one rule at a time.
+type ServerConfig struct {
+	TempChannelCategoryID     DiscordID
+	CommandPrefix             string
+	CommandChannelID          DiscordID
+	CustomCommand             string
+	OrphanChannelPolicy       string
+	AutoCreate                bool
+	AutoCreateVoiceChannelIDs []DiscordID
+	ChannelNameTemplate       string
+	ArchiveFormat             string
+	ArchiveChannelID          DiscordID
+	DeletionGracePeriod       time.Duration
+	MaxChannelAge             time.Duration
+	MaxIdleTime               time.Duration
+	ExpiryAction              string
+	MkchCooldown              time.Duration
+	MaxTempChannels           int
+	CategoryRoutes            []CategoryRoute
+	HistoryVisibility         HistoryVisibility
+	VoiceChannelPolicies      []VoiceChannelPolicy
+}
+
+// CategoryRoute creates the temp channels of a voice channel, or of all the voice channels in a category, in a specific category.
+type CategoryRoute struct {
+	// SourceID is the ID of the voice channel or the category of voice channels.
+	SourceID DiscordID
+	// CategoryID is the ID of the category the temp channels are created in.
+	CategoryID DiscordID
+}
+
+// VoiceChannelPolicy overrides the server's settings for the temp channel of a specific voice channel.
+type VoiceChannelPolicy struct {
+	VoiceChannelID DiscordID
+	// MkchDisabled stops temp channels from being created for the voice channel, by mkch or automatically.
+	MkchDisabled bool
+	// ForceAutoCreate creates temp channels automatically for the voice channel, even if the server's automatic creation doesn't apply to it.
+	ForceAutoCreate bool
+	// ChannelNameTemplate replaces the server's name template if it isn't empty.
+	ChannelNameTemplate string
+	// HistoryVisibility replaces the server's history visibility if its mode isn't empty.
+	HistoryVisibility HistoryVisibility
+}
+
+// IsEmpty returns whether the policy doesn't override any of the server's settings.
+func (p VoiceChannelPolicy) IsEmpty() bool {
+	return p == VoiceChannelPolicy{VoiceChannelID: p.VoiceChannelID}
+}
+
+// HistoryVisibility is how members that join a temp channel late see the messages sent before they joined.
+type HistoryVisibility struct {
+	// Mode is one of consts.ValidHistoryModes.
+	Mode string
+	// RecapPeriod is how far back the recap posted for newcomers goes, set only for the recap mode.
+	RecapPeriod time.Duration
+}
+
+// String formats the history visibility as its mode, followed by the recap period for the recap mode, e.g. recap:15m.
+func (v HistoryVisibility) String() string {
+	if v.Mode != consts.HistoryModeRecap {
+		return v.Mode
+	}
+
+	return fmt.Sprintf("%v:%vm", v.Mode, int(v.RecapPeriod/time.Minute))
+}
+
+// ParseHistoryVisibility parses a history visibility formatted by HistoryVisibility.String, and checks it.
+// An empty string is parsed as an empty mode.
+func ParseHistoryVisibility(value string) (HistoryVisibility, error) {
+	if value == "" {
+		return HistoryVisibility{}, nil
+	}
+
+	parts := strings.SplitN(value, ":", 2)
+	visibility := HistoryVisibility{Mode: parts[0]}
+	if len(parts) == 2 {
+		period, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return HistoryVisibility{}, fmt.Errorf("Invalid recap period %q", parts[1])
+		}
+
+		visibility.RecapPeriod = period
+	}
+
+	return visibility, visibility.check()
+}
+
+func (v HistoryVisibility) check() error {
+	if !containsString(consts.ValidHistoryModes, v.Mode) {
+		return fmt.Errorf("Invalid history mode %q", v.Mode)
+	}
+
+	if v.Mode != consts.HistoryModeRecap {
+		if v.RecapPeriod != 0 {
+			return fmt.Errorf("Only the %v history mode has a recap period", consts.HistoryModeRecap)
+		}
+
+		return nil
+	}
+
+	if v.RecapPeriod < time.Minute || v.RecapPeriod > consts.MaxHistoryRecapPeriod || v.RecapPeriod%time.Minute != 0 {
+		return fmt.Errorf("Invalid recap period %v", v.RecapPeriod)
+	}
+
+	return nil
+}
+
+// defaultServerConfig returns the settings of a newly set up server.
+func defaultServerConfig(tempChannelCategoryID DiscordID) ServerConfig {
+	return ServerConfig{
+		TempChannelCategoryID: tempChannelCategoryID,
+		CommandPrefix:         consts.DefaultCommandPrefix,
+		OrphanChannelPolicy:   consts.DefaultOrphanPolicy,
+		ExpiryAction:          consts.DefaultExpiryAction,
+		HistoryVisibility:     HistoryVisibility{Mode: consts.DefaultHistoryMode},
+	}
+}
+
+// copy returns a copy of the settings that doesn't share the voice channel, route and policy lists.
+func (c ServerConfig) copy() ServerConfig {
+	c.AutoCreateVoiceChannelIDs = append([]DiscordID{}, c.AutoCreateVoiceChannelIDs...)
+	c.CategoryRoutes = append([]CategoryRoute{}, c.CategoryRoutes...)
+	c.VoiceChannelPolicies = append([]VoiceChannelPolicy{}, c.VoiceChannelPolicies...)
+	return c
+}
+
+// TempChannelCategoryFor returns the category the temp channel of a voice channel is created in.
+// A route of the voice channel itself is preferred over a route of its category, voice channels without routes use the default category.
+// The voice category ID may be DiscordIDNone for voice channels outside of a category.
+func (c *ServerConfig) TempChannelCategoryFor(voiceChannelID DiscordID, voiceCategoryID DiscordID) DiscordID {
+	if categoryID, found := c.categoryRoute(voiceChannelID); found {
+		return categoryID
+	}
+
+	if categoryID, found := c.categoryRoute(voiceCategoryID); found && voiceCategoryID != DiscordIDNone {
+		return categoryID
+	}
+
+	return c.TempChannelCategoryID
+}
+
+// TempChannelCategoryIDs returns all the categories temp channels are created in, the default category first.
+func (c *ServerConfig) TempChannelCategoryIDs() []DiscordID {
+	ids := []DiscordID{c.TempChannelCategoryID}
+	for _, route := range c.CategoryRoutes {
+		if !containsID(ids, route.CategoryID) {
+			ids = append(ids, route.CategoryID)
+		}
+	}
+
+	return ids
+}
+
+func (c *ServerConfig) categoryRoute(sourceID DiscordID) (DiscordID, bool) {
+	for _, route := range c.CategoryRoutes {
+		if route.SourceID == sourceID {
+			return route.CategoryID, true
+		}
+	}
+
+	return DiscordIDNone, false
+}
+
+// SetCategoryRoute adds a route, or replaces the existing route of its source.
+func (c *ServerConfig) SetCategoryRoute(route CategoryRoute) {
+	for i, existingRoute := range c.CategoryRoutes {
+		if existingRoute.SourceID == route.SourceID {
+			c.CategoryRoutes[i] = route
+			return
+		}
+	}
+
+	c.CategoryRoutes = append(c.CategoryRoutes, route)
+}
+
+// RemoveCategoryRoute removes the route of a voice channel or a voice category.
+func (c *ServerConfig) RemoveCategoryRoute(sourceID DiscordID) error {
+	for i, existingRoute := range c.CategoryRoutes {
+		if existingRoute.SourceID == sourceID {
+			c.CategoryRoutes = append(c.CategoryRoutes[:i], c.CategoryRoutes[i+1:]...)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("No route for %v", sourceID)
+}
+
+// VoiceChannelPolicy returns the policy of a voice channel, a policy that doesn't override anything if it has none.
+func (c *ServerConfig) VoiceChannelPolicy(voiceChannelID DiscordID) VoiceChannelPolicy {
+	for _, policy := range c.VoiceChannelPolicies {
+		if policy.VoiceChannelID == voiceChannelID {
+			return policy
+		}
+	}
+
+	return VoiceChannelPolicy{VoiceChannelID: voiceChannelID}
+}
+
+// ChannelNameTemplateFor returns the name template of the temp channel of a voice channel,
+// the voice channel's own template if its policy overrides the server's.
+func (c *ServerConfig) ChannelNameTemplateFor(voiceChannelID DiscordID) string {
+	policy := c.VoiceChannelPolicy(voiceChannelID)
+	if policy.ChannelNameTemplate != "" {
+		return policy.ChannelNameTemplate
+	}
+
+	return c.ChannelNameTemplate
+}
+
+// HistoryVisibilityFor returns the history visibility of the temp channel of a voice channel,
+// the voice channel's own visibility if its policy overrides the server's.
+func (c *ServerConfig) HistoryVisibilityFor(voiceChannelID DiscordID) HistoryVisibility {
+	policy := c.VoiceChannelPolicy(voiceChannelID)
+	if policy.HistoryVisibility.Mode != "" {
+		return policy.HistoryVisibility
+	}
+
+	return c.HistoryVisibility
+}
+
+// SetVoiceChannelPolicy adds a policy, or replaces the existing policy of its voice channel.
+// Empty policies are removed by check.
+func (c *ServerConfig) SetVoiceChannelPolicy(policy VoiceChannelPolicy) {
+	for i, existingPolicy := range c.VoiceChannelPolicies {
+		if existingPolicy.VoiceChannelID == policy.VoiceChannelID {
+			c.VoiceChannelPolicies[i] = policy
+			return
+		}
+	}
+
+	c.VoiceChannelPolicies = append(c.VoiceChannelPolicies, policy)
+}
+
+// check normalizes the settings the way they're saved, and returns an error if they can't be saved.
+// The values are only checked to be ones the bot can run with, the commands that set them check them further.
+func (c *ServerConfig) check() error {
+	c.DeletionGracePeriod = c.DeletionGracePeriod.Truncate(time.Second)
+	c.MaxChannelAge = c.MaxChannelAge.Truncate(time.Second)
+	c.MaxIdleTime = c.MaxIdleTime.Truncate(time.Second)
+	c.MkchCooldown = c.MkchCooldown.Truncate(time.Second)
+	if len(c.AutoCreateVoiceChannelIDs) == 0 {
+		c.AutoCreateVoiceChannelIDs = nil
+	}
+	if len(c.CategoryRoutes) == 0 {
+		c.CategoryRoutes = nil
+	}
+	policies := []VoiceChannelPolicy{}
+	for _, policy := range c.VoiceChannelPolicies {
+		if !policy.IsEmpty() {
+			policies = append(policies, policy)
+		}
+	}
+	c.VoiceChannelPolicies = nil
+	if len(policies) > 0 {
+		c.VoiceChannelPolicies = policies
+	}
+
+	if c.TempChannelCategoryID == DiscordIDNone {
+		return fmt.Errorf("A temp channel category is required")
+	}
+
+	if len(c.CommandPrefix) != 1 {
+		return fmt.Errorf("Invalid command prefix %q", c.CommandPrefix)
+	}
+
+	if !containsString(consts.ValidOrphanPolicies, c.OrphanChannelPolicy) {
+		return fmt.Errorf("Invalid orphan channel policy %q", c.OrphanChannelPolicy)
+	}
+
+	if c.ArchiveFormat != "" && !containsString(consts.ValidArchiveFormats, c.ArchiveFormat) {
+		return fmt.Errorf("Invalid archive format %q", c.ArchiveFormat)
+	}
+
+	if c.DeletionGracePeriod < 0 || c.DeletionGracePeriod > consts.MaxDeletionGracePeriod {
+		return fmt.Errorf("Invalid deletion grace period %v", c.DeletionGracePeriod)
+	}
+
+	if !isValidChannelExpiry(c.MaxChannelAge) {
+		return fmt.Errorf("Invalid maximum channel age %v", c.MaxChannelAge)
+	}
+
+	if !isValidChannelExpiry(c.MaxIdleTime) {
+		return fmt.Errorf("Invalid maximum idle time %v", c.MaxIdleTime)
+	}
+
+	if !containsString(consts.ValidExpiryActions, c.ExpiryAction) {
+		return fmt.Errorf("Invalid expiry action %q", c.ExpiryAction)
+	}
+
+	if c.MkchCooldown < 0 || c.MkchCooldown > consts.MaxMkchCooldown {
+		return fmt.Errorf("Invalid mkch cooldown %v", c.MkchCooldown)
+	}
+
+	if c.MaxTempChannels < 0 || c.MaxTempChannels > consts.MaxGuildChannels {
+		return fmt.Errorf("Invalid maximum number of temp channels %v", c.MaxTempChannels)
+	}
+
+	if err := c.HistoryVisibility.check(); err != nil {
+		return err
+	}
+
+	sources := map[DiscordID]bool{}
+	for _, route := range c.CategoryRoutes {
+		if route.SourceID == DiscordIDNone || route.CategoryID == DiscordIDNone || sources[route.SourceID] {
+			return fmt.Errorf("Invalid category route %v:%v", route.SourceID, route.CategoryID)
+		}
+
+		sources[route.SourceID] = true
+	}
+
+	voiceChannels := map[DiscordID]bool{}
+	for _, policy := range c.VoiceChannelPolicies {
+		if policy.VoiceChannelID == DiscordIDNone || voiceChannels[policy.VoiceChannelID] {
+			return fmt.Errorf("Invalid voice channel policy for %v", policy.VoiceChannelID)
+		}
+
+		if policy.MkchDisabled && policy.ForceAutoCreate {
+			return fmt.Errorf("The temp channels of voice channel %v can't be both disabled and created automatically", policy.VoiceChannelID)
+		}
+
+		if policy.HistoryVisibility.Mode != "" {
+			if err := policy.HistoryVisibility.check(); err != nil {
+				return err
+			}
+		}
+
+		voiceChannels[policy.VoiceChannelID] = true
+	}
+
+	return nil
+}
+
+func containsID(ids []DiscordID, id DiscordID) bool {
+	for _, existingID := range ids {
+		if existingID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, existingValue := range values {
+		if existingValue == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CommandPermissionRule allows a role, a user, or anyone with specific permissions to run a command.
+type CommandPermissionRule struct {
+	Command string
+	Type    string
+
+	// TargetID is the ID of the role or the user of role and user rules.
+	TargetID DiscordID
+	// Permissions are the permission bits required by permission rules.
+	Permissions int64
 }
 
 // ServersData maps from the server ID to the relevant server data struct.
@@ -86,6 +529,17 @@ type ServerStore interface {
 
 	// AddServer adds a new server to the store.
 	AddServer(serverID DiscordID, tempChannelCategoryID DiscordID) error
+
+	// RemoveServer removes a server the bot was removed from, its settings are kept for a while in case it's invited back.
+	// Servers that aren't in the store are ignored.
+	RemoveServer(serverID DiscordID) error
+
+	// RestoreServer returns the settings of a server the bot was invited back to.
+	// Returns whether the server was restored, servers that weren't removed or were already purged aren't.
+	RestoreServer(serverID DiscordID) (restored bool, err error)
+
+	// ServerIDs returns the IDs of the servers in the store, in no particular order.
+	ServerIDs() []DiscordID
 }
 
 // ServersProvider provides the server data to the store from the database.
@@ -94,5 +548,221 @@ type ServersProvider interface {
 	Servers() (ServersData, error)
 
 	// AddServer adds a new server to the store.
+	// A server that was removed and not purged yet gets its settings back, with the new category.
 	AddServer(serverID DiscordID, tempChannelCategoryID DiscordID) (ServerData, error)
+
+	// RemoveServer marks a server the bot was removed from, its data is kept until it's purged.
+	RemoveServer(serverID DiscordID) error
+
+	// RestoreServer returns a removed server to the store, if it wasn't purged yet.
+	RestoreServer(serverID DiscordID) (data ServerData, restored bool, err error)
+
+	// PurgeRemovedServers deletes all the data of the servers removed before the given time.
+	// Returns the number of purged servers.
+	PurgeRemovedServers(removedBefore time.Time) (int, error)
+}
+
+// TempChannelData is the persisted state of a single temp channel created by the bot.
+type TempChannelData struct {
+	ChannelID      DiscordID
+	VoiceChannelID DiscordID
+	ServerID       DiscordID
+	Members        []DiscordID
+	CreatedAt      time.Time
+
+	// OwnerID is the user that controls the temp channel, DiscordIDNone for channels without an owner.
+	OwnerID DiscordID
+	// Locked temp channels don't give access to users that join their voice chat.
+	Locked bool
+	// Invited are the members the owner invited, who keep their access without being in the voice chat.
+	Invited []DiscordID
+}
+
+// TempChannelStore persists the active temp channels, so they can be re-adopted after the bot restarts.
+type TempChannelStore interface {
+	// TempChannels returns all the temp channels that were active when the bot last ran.
+	TempChannels() ([]*TempChannelData, error)
+
+	// AddTempChannel saves a newly created temp channel.
+	AddTempChannel(data *TempChannelData) error
+	// SetTempChannelMembers replaces the list of users that have access to the temp channel.
+	SetTempChannelMembers(channelID DiscordID, members []DiscordID) error
+	// SetTempChannelOwner changes the user that controls the temp channel.
+	SetTempChannelOwner(channelID DiscordID, ownerID DiscordID) error
+	// SetTempChannelLocked changes whether the temp channel gives access to users that join its voice chat.
+	SetTempChannelLocked(channelID DiscordID, locked bool) error
+	// SetTempChannelInvited replaces the list of members the owner invited to the temp channel.
+	SetTempChannelInvited(channelID DiscordID, invited []DiscordID) error
+	// RemoveTempChannel removes a deleted temp channel.
+	RemoveTempChannel(channelID DiscordID) error
+}
+
+// TranscriptData is the message history of a deleted temp channel.
+type TranscriptData struct {
+	ServerID       DiscordID
+	ChannelID      DiscordID
+	VoiceChannelID DiscordID
+	ChannelName    string
+	Format         string
+	Content        string
+	CreatedAt      time.Time
+}
+
+// TranscriptStore keeps the transcripts of deleted temp channels.
+type TranscriptStore interface {
+	// AddTranscript saves the transcript of a deleted temp channel.
+	AddTranscript(data *TranscriptData) error
+}
+
+// ConfigChangeStore is the audit log of the changes to the settings of servers.
+type ConfigChangeStore interface {
+	// AddConfigChange records a change to the settings of a server, and sets its ChangeID.
+	AddConfigChange(change *ConfigChange) error
+
+	// ConfigChanges returns the changes to the settings of a server from newest to oldest, skipping the newest offset changes.
+	ConfigChanges(serverID DiscordID, offset int, limit int) ([]*ConfigChange, error)
+
+	// ConfigChange returns a specific change to the settings of a server.
+	ConfigChange(serverID DiscordID, changeID int64) (change *ConfigChange, found bool, err error)
+}
+
+// FormatDiscordIDs joins a list of IDs into a single comma separated string, used to store ID lists in a single column.
+func FormatDiscordIDs(ids []DiscordID) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, id.RESTAPIFormat())
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// FormatCategoryRoutes formats a list of routes as text, e.g. for saving in a single column.
+func FormatCategoryRoutes(routes []CategoryRoute) string {
+	parts := make([]string, 0, len(routes))
+	for _, route := range routes {
+		parts = append(parts, route.SourceID.RESTAPIFormat()+":"+route.CategoryID.RESTAPIFormat())
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// ParseCategoryRoutes parses a list of routes formatted by FormatCategoryRoutes.
+func ParseCategoryRoutes(value string) ([]CategoryRoute, error) {
+	routes := []CategoryRoute{}
+	if value == "" {
+		return routes, nil
+	}
+
+	for _, part := range strings.Split(value, ",") {
+		ids := strings.Split(part, ":")
+		if len(ids) != 2 {
+			return nil, fmt.Errorf("Invalid category route %q", part)
+		}
+
+		sourceID, err := ParseDiscordID(ids[0])
+		if err != nil {
+			return nil, err
+		}
+
+		categoryID, err := ParseDiscordID(ids[1])
+		if err != nil {
+			return nil, err
+		}
+
+		routes = append(routes, CategoryRoute{SourceID: sourceID, CategoryID: categoryID})
+	}
+
+	return routes, nil
+}
+
+// voiceChannelPolicyJSON is how a voice channel policy is formatted, as JSON since name templates may contain any character.
+type voiceChannelPolicyJSON struct {
+	VoiceChannelID      string `json:"voice_channel_id"`
+	MkchDisabled        bool   `json:"mkch_disabled,omitempty"`
+	ForceAutoCreate     bool   `json:"force_auto_create,omitempty"`
+	ChannelNameTemplate string `json:"channel_name_template,omitempty"`
+	HistoryVisibility   string `json:"history_visibility,omitempty"`
+}
+
+// FormatVoiceChannelPolicies formats a list of policies as text, e.g. for saving in a single column.
+// An empty list is formatted as an empty string.
+func FormatVoiceChannelPolicies(policies []VoiceChannelPolicy) string {
+	if len(policies) == 0 {
+		return ""
+	}
+
+	formatted := make([]voiceChannelPolicyJSON, 0, len(policies))
+	for _, policy := range policies {
+		formatted = append(formatted, voiceChannelPolicyJSON{
+			VoiceChannelID:      policy.VoiceChannelID.RESTAPIFormat(),
+			MkchDisabled:        policy.MkchDisabled,
+			ForceAutoCreate:     policy.ForceAutoCreate,
+			ChannelNameTemplate: policy.ChannelNameTemplate,
+			HistoryVisibility:   policy.HistoryVisibility.String(),
+		})
+	}
+
+	// Marshaling strings and booleans can't fail
+	value, _ := json.Marshal(formatted)
+	return string(value)
+}
+
+// ParseVoiceChannelPolicies parses a list of policies formatted by FormatVoiceChannelPolicies.
+func ParseVoiceChannelPolicies(value string) ([]VoiceChannelPolicy, error) {
+	policies := []VoiceChannelPolicy{}
+	if value == "" {
+		return policies, nil
+	}
+
+	var formatted []voiceChannelPolicyJSON
+	err := json.Unmarshal([]byte(value), &formatted)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid voice channel policies: %w", err)
+	}
+
+	for _, policy := range formatted {
+		voiceChannelID, err := ParseDiscordID(policy.VoiceChannelID)
+		if err != nil {
+			return nil, err
+		}
+
+		historyVisibility, err := ParseHistoryVisibility(policy.HistoryVisibility)
+		if err != nil {
+			return nil, err
+		}
+
+		policies = append(policies, VoiceChannelPolicy{
+			VoiceChannelID:      voiceChannelID,
+			MkchDisabled:        policy.MkchDisabled,
+			ForceAutoCreate:     policy.ForceAutoCreate,
+			ChannelNameTemplate: policy.ChannelNameTemplate,
+			HistoryVisibility:   historyVisibility,
+		})
+	}
+
+	return policies, nil
+}
+
+// ParseDiscordIDs parses a list of IDs formatted by FormatDiscordIDs.
+func ParseDiscordIDs(value string) ([]DiscordID, error) {
+	ids := []DiscordID{}
+	if value == "" {
+		return ids, nil
+	}
+
+	for _, part := range strings.Split(value, ",") {
+		id, err := ParseDiscordID(part)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// isValidChannelExpiry returns whether the duration is a valid maximum age or idle time, 0 turning the expiry off.
+func isValidChannelExpiry(value time.Duration) bool {
+	return value == 0 || (value >= consts.MinChannelExpiry && value <= consts.MaxChannelExpiry)
 }
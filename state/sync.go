@@ -3,6 +3,7 @@ package state
 import (
 	"fmt"
 	"sync"
+	"time"
 )
 
 // SyncServerStore wraps a server store and sync all access
@@ -19,6 +20,10 @@ func NewSyncServerStore(provider ServersProvider) (*SyncServerStore, error) {
 		return nil, err
 	}
 
+	for serverID, serverData := range servers {
+		servers[serverID] = NewSyncServerData(serverData)
+	}
+
 	store := &SyncServerStore{
 		provider: provider,
 		servers:  servers,
@@ -55,10 +60,61 @@ func (s *SyncServerStore) AddServer(serverID DiscordID, tempChannelCategoryID Di
 		return err
 	}
 
-	s.servers[serverID] = serverData
+	s.servers[serverID] = NewSyncServerData(serverData)
 	return nil
 }
 
+// RemoveServer removes a server the bot was removed from, its settings are kept for a while in case it's invited back.
+// Servers that aren't in the store are ignored.
+func (s *SyncServerStore) RemoveServer(serverID DiscordID) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.servers[serverID]; !found {
+		return nil
+	}
+
+	err := s.provider.RemoveServer(serverID)
+	if err != nil {
+		return err
+	}
+
+	delete(s.servers, serverID)
+	return nil
+}
+
+// RestoreServer returns the settings of a server the bot was invited back to.
+// Returns whether the server was restored, servers that weren't removed or were already purged aren't.
+func (s *SyncServerStore) RestoreServer(serverID DiscordID) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.servers[serverID]; found {
+		return false, nil
+	}
+
+	serverData, restored, err := s.provider.RestoreServer(serverID)
+	if err != nil || !restored {
+		return false, err
+	}
+
+	s.servers[serverID] = NewSyncServerData(serverData)
+	return true, nil
+}
+
+// ServerIDs returns the IDs of the servers in the store, in no particular order.
+func (s *SyncServerStore) ServerIDs() []DiscordID {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	serverIDs := make([]DiscordID, 0, len(s.servers))
+	for serverID := range s.servers {
+		serverIDs = append(serverIDs, serverID)
+	}
+
+	return serverIDs
+}
+
 // SyncServerData synchronizes read/writes to the server data.
 type SyncServerData struct {
 	data  ServerData
@@ -77,6 +133,30 @@ func (d *SyncServerData) ServerID() DiscordID {
 	return d.data.ServerID()
 }
 
+// Config returns a copy of the server's settings.
+func (d *SyncServerData) Config() ServerConfig {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.data.Config()
+}
+
+// Update changes several settings at once. The update function changes a copy of the settings,
+// which is checked and saved as a whole only if the function succeeds.
+// The lock is held for the whole update, so the function shouldn't call the server data.
+func (d *SyncServerData) Update(update func(config *ServerConfig) error) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.Update(update)
+}
+
+// Exclusive runs the function with the wrapped data, while no one else reads or changes it.
+// The function should use the data it's given, calling the synchronized data would deadlock.
+func (d *SyncServerData) Exclusive(run func(data ServerData) error) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return run(d.data)
+}
+
 // TempChannelCategoryID is the category Discord ID of the category to create temporary chat channels in.
 func (d *SyncServerData) TempChannelCategoryID() DiscordID {
 	d.mutex.RLock()
@@ -174,3 +254,192 @@ func (d *SyncServerData) HasCustomCommand() bool {
 	defer d.mutex.RUnlock()
 	return d.data.HasCustomCommand()
 }
+
+// OrphanChannelPolicy is what the bot does with text channels in the temp category it doesn't track.
+func (d *SyncServerData) OrphanChannelPolicy() string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.data.OrphanChannelPolicy()
+}
+
+// SetOrphanChannelPolicy sets the policy for untracked text channels in the temp category.
+func (d *SyncServerData) SetOrphanChannelPolicy(value string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.SetOrphanChannelPolicy(value)
+}
+
+// ResetOrphanChannelPolicy resets the orphan channel policy to the default value.
+func (d *SyncServerData) ResetOrphanChannelPolicy() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.ResetOrphanChannelPolicy()
+}
+
+// AutoCreate returns whether temp channels are created automatically when users join a voice chat.
+func (d *SyncServerData) AutoCreate() bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.data.AutoCreate()
+}
+
+// SetAutoCreate turns the automatic temp channel creation on or off.
+func (d *SyncServerData) SetAutoCreate(value bool) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.SetAutoCreate(value)
+}
+
+// AutoCreateVoiceChannelIDs is the list of voice channels the automatic creation applies to.
+func (d *SyncServerData) AutoCreateVoiceChannelIDs() []DiscordID {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.data.AutoCreateVoiceChannelIDs()
+}
+
+// SetAutoCreateVoiceChannelIDs limits the automatic creation to specific voice channels.
+func (d *SyncServerData) SetAutoCreateVoiceChannelIDs(value []DiscordID) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.SetAutoCreateVoiceChannelIDs(value)
+}
+
+// ClearAutoCreateVoiceChannelIDs makes the automatic creation apply to all voice channels.
+func (d *SyncServerData) ClearAutoCreateVoiceChannelIDs() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.ClearAutoCreateVoiceChannelIDs()
+}
+
+// HasAutoCreateVoiceChannelIDs returns whether the automatic creation is limited to specific voice channels.
+func (d *SyncServerData) HasAutoCreateVoiceChannelIDs() bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.data.HasAutoCreateVoiceChannelIDs()
+}
+
+// ChannelNameTemplate is the template temp channel names are created from.
+func (d *SyncServerData) ChannelNameTemplate() string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.data.ChannelNameTemplate()
+}
+
+// SetChannelNameTemplate sets the template temp channel names are created from.
+func (d *SyncServerData) SetChannelNameTemplate(value string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.SetChannelNameTemplate(value)
+}
+
+// ResetChannelNameTemplate resets the temp channel names to random silly names.
+func (d *SyncServerData) ResetChannelNameTemplate() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.ResetChannelNameTemplate()
+}
+
+// HasChannelNameTemplate returns whether a custom channel name template was set.
+func (d *SyncServerData) HasChannelNameTemplate() bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.data.HasChannelNameTemplate()
+}
+
+// ArchiveFormat is the format temp channel transcripts are archived in before the channels are deleted.
+func (d *SyncServerData) ArchiveFormat() string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.data.ArchiveFormat()
+}
+
+// SetArchiveFormat sets the transcript format, and enables archiving.
+func (d *SyncServerData) SetArchiveFormat(value string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.SetArchiveFormat(value)
+}
+
+// DisableArchive stops archiving temp channel transcripts.
+func (d *SyncServerData) DisableArchive() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.DisableArchive()
+}
+
+// ArchiveEnabled returns whether temp channel transcripts are archived.
+func (d *SyncServerData) ArchiveEnabled() bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.data.ArchiveEnabled()
+}
+
+// ArchiveChannelID is the ID of the channel transcripts are posted to.
+func (d *SyncServerData) ArchiveChannelID() DiscordID {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.data.ArchiveChannelID()
+}
+
+// SetArchiveChannelID sets the channel transcripts are posted to.
+func (d *SyncServerData) SetArchiveChannelID(value DiscordID) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.SetArchiveChannelID(value)
+}
+
+// ClearArchiveChannelID makes transcripts be kept in the database instead of posted to a channel.
+func (d *SyncServerData) ClearArchiveChannelID() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.ClearArchiveChannelID()
+}
+
+// HasArchiveChannelID returns whether transcripts are posted to a channel.
+func (d *SyncServerData) HasArchiveChannelID() bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.data.HasArchiveChannelID()
+}
+
+// DeletionGracePeriod is how long an empty temp channel is kept before it's deleted.
+func (d *SyncServerData) DeletionGracePeriod() time.Duration {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.data.DeletionGracePeriod()
+}
+
+// SetDeletionGracePeriod sets how long an empty temp channel is kept before it's deleted.
+func (d *SyncServerData) SetDeletionGracePeriod(value time.Duration) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.SetDeletionGracePeriod(value)
+}
+
+// ResetDeletionGracePeriod makes empty temp channels be deleted immediately.
+func (d *SyncServerData) ResetDeletionGracePeriod() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.ResetDeletionGracePeriod()
+}
+
+// CommandPermissionRules returns the rules of who may run each command.
+func (d *SyncServerData) CommandPermissionRules() []CommandPermissionRule {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.data.CommandPermissionRules()
+}
+
+// AddCommandPermissionRule allows the rule's target to run the rule's command.
+func (d *SyncServerData) AddCommandPermissionRule(rule CommandPermissionRule) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.AddCommandPermissionRule(rule)
+}
+
+// RemoveCommandPermissionRule removes a rule added by AddCommandPermissionRule.
+func (d *SyncServerData) RemoveCommandPermissionRule(rule CommandPermissionRule) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.data.RemoveCommandPermissionRule(rule)
+}